@@ -0,0 +1,132 @@
+// Command archive exports the blog to a zip archive and restores one into a fresh database.
+//
+// An archive holds every table as JSON, the media files, and a manifest with the format and schema versions
+// and a checksum for each file. Restore validates the whole archive before touching anything and refuses a
+// database that already has data; run the migrations on the new database first.
+//
+//	go run ./cmd/archive export -o blog.zip [-passwords] [-no-blobs]
+//	go run ./cmd/archive restore -i blog.zip [-verify-only]
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	archiveRepo "github.com/KylerJacobson/blog/backend/internal/db/archive"
+	"github.com/KylerJacobson/blog/backend/internal/db/config"
+	"github.com/KylerJacobson/blog/backend/internal/services/archive"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "export":
+		export(os.Args[2:])
+	case "restore":
+		restore(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: archive export -o file.zip [-passwords] [-no-blobs]")
+	fmt.Fprintln(os.Stderr, "       archive restore -i file.zip [-verify-only]")
+	os.Exit(2)
+}
+
+func export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the archive to")
+	passwords := flags.Bool("passwords", false, "include password hashes so users keep their passwords")
+	noBlobs := flags.Bool("no-blobs", false, "leave out media files and keep only their metadata")
+	flags.Parse(args)
+	if *output == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	archiver, close := newArchiver()
+	defer close()
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(file)
+	manifest, err := archiver.Export(w, archive.Options{Passwords: *passwords, Blobs: !*noBlobs})
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		log.Fatal(err)
+	}
+	fmt.Printf("exported %d tables and %d blobs to %s\n", len(manifest.Tables), len(manifest.Blobs), *output)
+}
+
+func restore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := flags.String("i", "", "archive to restore")
+	verifyOnly := flags.Bool("verify-only", false, "validate the archive without restoring it")
+	flags.Parse(args)
+	if *input == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	zr, err := zip.OpenReader(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer zr.Close()
+
+	archiver, close := newArchiver()
+	defer close()
+
+	if *verifyOnly {
+		manifest, err := archiver.Validate(&zr.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s is valid: format %d, schema %d, created %s, %d tables, %d blobs\n", *input,
+			manifest.FormatVersion, manifest.SchemaVersion, manifest.CreatedAt.Format("2006-01-02 15:04:05"), len(manifest.Tables), len(manifest.Blobs))
+		return
+	}
+	manifest, err := archiver.Restore(&zr.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("restored %d tables and %d blobs from %s\n", len(manifest.Tables), len(manifest.Blobs), *input)
+	if !manifest.IncludesPasswords {
+		fmt.Println("the archive has no password hashes; users can't sign in until their passwords are set again")
+	}
+}
+
+func newArchiver() (*archive.Archiver, func()) {
+	zapLogger, err := logger.NewLogger(os.Getenv("ENVIRONMENT"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	dbPool := config.GetDBConn(zapLogger)
+	blobs, err := storage.FromEnv(os.Getenv("SITE_URL"), zapLogger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	archiver := archive.New(archiveRepo.New(dbPool, zapLogger), blobs, zapLogger)
+	return archiver, func() {
+		dbPool.Close()
+		zapLogger.Sync()
+	}
+}
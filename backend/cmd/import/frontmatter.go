@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the subset of Hugo and Jekyll front matter the importer understands
+type frontMatter struct {
+	Title      string
+	Slug       string
+	Date       time.Time
+	Tags       []string
+	Draft      bool
+	Restricted bool
+}
+
+// dateLayouts are the date formats seen in Hugo and Jekyll front matter that YAML doesn't already parse
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// datedFilename matches Jekyll's YYYY-MM-DD-title.md naming
+var datedFilename = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// parseDocument splits a Markdown file into its front matter and body. YAML front matter is fenced by ---
+// and TOML by +++. Missing dates and slugs fall back to a Jekyll style file name.
+func parseDocument(path string, data []byte) (frontMatter, string, error) {
+	doc := strings.ReplaceAll(strings.TrimPrefix(string(data), "\uFEFF"), "\r\n", "\n")
+
+	var fm frontMatter
+	fence, raw, body, err := splitFrontMatter(doc)
+	if err != nil {
+		return fm, "", err
+	}
+	fields := map[string]any{}
+	switch fence {
+	case "---":
+		if err := yaml.Unmarshal([]byte(raw), &fields); err != nil {
+			return fm, "", fmt.Errorf("invalid YAML front matter: %w", err)
+		}
+	case "+++":
+		if _, err := toml.Decode(raw, &fields); err != nil {
+			return fm, "", fmt.Errorf("invalid TOML front matter: %w", err)
+		}
+	}
+
+	fm.Title, _ = fields["title"].(string)
+	fm.Slug, _ = fields["slug"].(string)
+	fm.Draft, _ = fields["draft"].(bool)
+	fm.Restricted, _ = fields["restricted"].(bool)
+	// Jekyll marks drafts with published: false
+	if published, ok := fields["published"].(bool); ok && !published {
+		fm.Draft = true
+	}
+	fm.Tags, err = stringList(fields["tags"])
+	if err != nil {
+		return fm, "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	fileDate := ""
+	if m := datedFilename.FindStringSubmatch(name); m != nil {
+		fileDate, name = m[1], m[2]
+	}
+	if fm.Slug == "" {
+		fm.Slug = name
+	}
+	date, ok := fields["date"]
+	if !ok && fileDate != "" {
+		date, ok = fileDate, true
+	}
+	if !ok {
+		return fm, "", errors.New("no date in front matter or file name")
+	}
+	fm.Date, err = parseDate(date)
+	if err != nil {
+		return fm, "", err
+	}
+	if strings.TrimSpace(fm.Title) == "" {
+		return fm, "", errors.New("front matter has no title")
+	}
+	return fm, strings.TrimLeft(body, "\n"), nil
+}
+
+// splitFrontMatter separates a leading ---/+++ fenced block from the rest of doc. Documents without one
+// come back whole with an empty fence.
+func splitFrontMatter(doc string) (fence, raw, body string, err error) {
+	lines := strings.Split(doc, "\n")
+	fence = strings.TrimSpace(lines[0])
+	if fence != "---" && fence != "+++" {
+		return "", "", doc, nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == fence {
+			return fence, strings.Join(lines[1:i], "\n"), strings.Join(lines[i+1:], "\n"), nil
+		}
+	}
+	return "", "", "", errors.New("unterminated front matter")
+}
+
+func parseDate(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognised date %q", v)
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %v", value)
+}
+
+// stringList accepts tags as a list or, as Jekyll allows, a single space separated string
+func stringList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(v), nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("tag %v is not a string", item)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("tags must be a list, got %T", value)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/media"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/posts"
+	"github.com/KylerJacobson/blog/backend/internal/imaging"
+	"github.com/KylerJacobson/blog/backend/internal/slug"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+// imageRef matches Markdown images, capturing the path and an optional title
+var imageRef = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+// blankLines finds the gaps removed images leave behind
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// Outcome is what the importer did, or in a dry run would do, with one file
+type Outcome string
+
+const (
+	OutcomeCreated   Outcome = "create"
+	OutcomeUpdated   Outcome = "update"
+	OutcomeUnchanged Outcome = "unchanged"
+	OutcomeSkipped   Outcome = "skip"
+	OutcomeFailed    Outcome = "error"
+)
+
+type importer struct {
+	postsRepository posts_repo.PostsRepository
+	mediaRepository media_repo.MediaRepository
+	blobs           media.BlobUploader
+	userId          int
+	staticDir       string
+	dryRun          bool
+	update          bool
+	out             io.Writer
+	// trashed holds the slugs of trashed posts, which would otherwise be imported again under a new slug
+	trashed map[string]bool
+}
+
+// localImage is an image a post references from disk
+type localImage struct {
+	ref         string
+	path        string
+	contentType string
+}
+
+// importDir imports every Markdown file below dir and reports one line per file. Posts are matched to earlier
+// imports by slug, so running it again only creates what is new; changed posts are left alone unless update is set.
+func (im *importer) importDir(dir string) (map[Outcome]int, error) {
+	counts := map[Outcome]int{}
+	trashed, err := im.postsRepository.GetTrashedPosts()
+	if err != nil {
+		return counts, err
+	}
+	im.trashed = map[string]bool{}
+	for _, post := range trashed {
+		im.trashed[post.Slug] = true
+	}
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isMarkdown(path) {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		outcome, detail, err := im.importFile(path)
+		if err != nil {
+			outcome, detail = OutcomeFailed, err.Error()
+		}
+		counts[outcome]++
+		prefix := ""
+		if im.dryRun && outcome != OutcomeFailed {
+			prefix = "would "
+		}
+		fmt.Fprintf(im.out, "%s%-9s %s %s\n", prefix, outcome, rel, detail)
+		return nil
+	})
+	return counts, err
+}
+
+func (im *importer) importFile(path string) (Outcome, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	fm, body, err := parseDocument(path, data)
+	if err != nil {
+		return "", "", err
+	}
+	images, body, err := im.localImages(path, body)
+	if err != nil {
+		return "", "", err
+	}
+
+	post := post_models.PostRequestBody{
+		Title:      strings.TrimSpace(fm.Title),
+		Content:    strings.TrimSpace(body),
+		Restricted: fm.Restricted,
+		Slug:       slug.Make(fm.Slug),
+		Status:     post_models.StatusPublished,
+		Tags:       posts.NormalizeTags(fm.Tags),
+	}
+	// Front matter without tags clears them on update, where a nil list would keep the post's current ones
+	if post.Tags == nil {
+		post.Tags = []string{}
+	}
+	if fm.Draft {
+		post.Status = post_models.StatusDraft
+	}
+	if post.Slug == "" {
+		post.Slug = slug.Make(post.Title)
+	}
+	detail := fmt.Sprintf("(%s, %d image(s))", fm.Date.Format("2006-01-02"), len(images))
+	if im.trashed[post.Slug] {
+		return OutcomeSkipped, "(post " + post.Slug + " is in the trash; restore or purge it first)", nil
+	}
+
+	existing, err := im.postsRepository.GetPostBySlug(post.Slug)
+	if err != nil && !errors.Is(err, v5.ErrNoRows) {
+		return "", "", err
+	}
+	if existing == nil {
+		if im.dryRun {
+			return OutcomeCreated, detail, nil
+		}
+		postId, err := im.postsRepository.ImportPost(post, im.userId, fm.Date)
+		if err != nil {
+			return "", "", err
+		}
+		return OutcomeCreated, detail, im.attach(postId, post.Restricted, images)
+	}
+
+	if unchanged(existing, post) {
+		// Picks up images a previous run failed to upload
+		if !im.dryRun {
+			return OutcomeUnchanged, "", im.attach(existing.PostId, post.Restricted, images)
+		}
+		return OutcomeUnchanged, "", nil
+	}
+	if !im.update {
+		return OutcomeSkipped, "(post " + existing.Slug + " changed since it was imported; use -update to overwrite)", nil
+	}
+	if im.dryRun {
+		return OutcomeUpdated, detail, nil
+	}
+	// Keep whatever slug the post has now so links to it stay put, and the date it was first published elsewhere
+	post.Slug = existing.Slug
+	_, err = im.postsRepository.ReimportPost(post, existing.PostId, im.userId, existing.Version, fm.Date)
+	if err != nil {
+		return "", "", err
+	}
+	return OutcomeUpdated, detail, im.attach(existing.PostId, post.Restricted, images)
+}
+
+// localImages finds the images body references from disk and removes those references, since imported
+// images become post attachments. Remote images are left in place.
+func (im *importer) localImages(path, body string) ([]localImage, string, error) {
+	var images []localImage
+	var missing error
+	body = imageRef.ReplaceAllStringFunc(body, func(ref string) string {
+		target := imageRef.FindStringSubmatch(ref)[1]
+		if u, err := url.Parse(target); err != nil || u.Scheme != "" || u.Host != "" {
+			return ref
+		}
+		target, _ = url.PathUnescape(target)
+		file := filepath.Join(filepath.Dir(path), filepath.FromSlash(target))
+		if strings.HasPrefix(target, "/") {
+			if im.staticDir == "" {
+				return ref
+			}
+			file = filepath.Join(im.staticDir, filepath.FromSlash(target))
+		}
+		contentType, err := sniff(file)
+		if err != nil {
+			missing = fmt.Errorf("image %s: %w", target, err)
+			return ref
+		}
+		if !media.AllowedFileTypes[contentType] {
+			missing = fmt.Errorf("image %s has unsupported type %s", target, contentType)
+			return ref
+		}
+		images = append(images, localImage{ref: target, path: file, contentType: contentType})
+		return ""
+	})
+	if len(images) > 0 {
+		body = blankLines.ReplaceAllString(body, "\n\n")
+	}
+	return images, body, missing
+}
+
+// attach uploads images the post doesn't already have, so re-runs don't duplicate attachments
+func (im *importer) attach(postId int, restricted bool, images []localImage) error {
+	if len(images) == 0 {
+		return nil
+	}
+	existing, err := im.mediaRepository.GetMediaByPostId(postId)
+	if err != nil {
+		return err
+	}
+	attached := map[string]bool{}
+	for _, m := range existing {
+		attached[m.BlobName] = true
+	}
+	for _, image := range images {
+		blobName := media.BlobName(postId, filepath.Base(image.path))
+		if attached[blobName] {
+			continue
+		}
+		metadata, err := im.upload(image, blobName)
+		if err != nil {
+			return fmt.Errorf("uploading %s: %w", image.ref, err)
+		}
+		err = media.Record(im.mediaRepository, postId, blobName, image.contentType, restricted, metadata)
+		if err != nil {
+			return fmt.Errorf("recording %s: %w", image.ref, err)
+		}
+		attached[blobName] = true
+	}
+	return nil
+}
+
+// upload stores an image the way uploads through the API are stored, with its EXIF and XMP stripped
+func (im *importer) upload(image localImage, blobName string) (imaging.Metadata, error) {
+	file, err := os.Open(image.path)
+	if err != nil {
+		return imaging.Metadata{}, err
+	}
+	defer file.Close()
+	return media.Store(im.blobs, file, image.contentType, blobName, false)
+}
+
+// unchanged reports whether importing post over existing would leave it as it is
+func unchanged(existing *post_models.Post, post post_models.PostRequestBody) bool {
+	tags := slices.Clone(post.Tags)
+	slices.Sort(tags)
+	return existing.Title == post.Title &&
+		existing.Content == post.Content &&
+		existing.Restricted == post.Restricted &&
+		existing.Status == post.Status &&
+		slices.Equal(existing.Tags, tags)
+}
+
+// sniff detects a file's content type from its first bytes, as uploads through the API do
+func sniff(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	buf := make([]byte, 512)
+	n, err := file.Read(buf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// commentedPNG is a small PNG with a text comment, which the importer must strip like the API does
+func commentedPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	text := []byte("tEXtComment\x00a secret comment")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)-4))
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(text))
+	// The signature and IHDR are the first 33 bytes
+	return append(append(append([]byte{}, encoded[:33]...), chunk...), encoded[33:]...)
+}
+
+func TestParseDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		doc      string
+		expected frontMatter
+		body     string
+		err      bool
+	}{
+		{
+			name:     "hugo_yaml",
+			path:     "posts/hello.md",
+			doc:      "---\ntitle: Hello\ndate: 2019-05-01T10:00:00Z\ntags: [Go, Web Dev]\ndraft: true\nrestricted: true\n---\n\nBody\n",
+			expected: frontMatter{Title: "Hello", Slug: "hello", Date: time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC), Tags: []string{"Go", "Web Dev"}, Draft: true, Restricted: true},
+			body:     "Body\n",
+		},
+		{
+			name:     "hugo_toml",
+			path:     "hello.md",
+			doc:      "+++\ntitle = \"Hello\"\nslug = \"custom\"\ndate = 2019-05-01T10:00:00Z\ntags = [\"go\"]\n+++\nBody",
+			expected: frontMatter{Title: "Hello", Slug: "custom", Date: time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC), Tags: []string{"go"}},
+			body:     "Body",
+		},
+		{
+			name:     "jekyll_dated_file_name",
+			path:     "_posts/2018-03-04-first-post.markdown",
+			doc:      "---\r\ntitle: First\r\ntags: go web\r\npublished: false\r\n---\r\nBody",
+			expected: frontMatter{Title: "First", Slug: "first-post", Date: time.Date(2018, 3, 4, 0, 0, 0, 0, time.UTC), Tags: []string{"go", "web"}, Draft: true},
+			body:     "Body",
+		},
+		{
+			name:     "jekyll_date_with_offset",
+			path:     "post.md",
+			doc:      "---\ntitle: Offset\ndate: 2018-03-04 09:30:00 -0700\n---\n",
+			expected: frontMatter{Title: "Offset", Slug: "post", Date: time.Date(2018, 3, 4, 16, 30, 0, 0, time.UTC)},
+		},
+		{name: "missing_date", path: "post.md", doc: "---\ntitle: Undated\n---\nBody", err: true},
+		{name: "missing_title", path: "2018-03-04-post.md", doc: "---\ntags: [go]\n---\nBody", err: true},
+		{name: "unterminated", path: "post.md", doc: "---\ntitle: Open\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body, err := parseDocument(tt.path, []byte(tt.doc))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.expected.Date.Equal(fm.Date), "date %v", fm.Date)
+			fm.Date = tt.expected.Date
+			assert.Equal(t, tt.expected, fm)
+			assert.Equal(t, tt.body, body)
+		})
+	}
+}
+
+// stubPostsRepository keeps posts in memory, keyed by slug
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	posts   map[string]*post_models.Post
+	trashed []post_models.Post
+	dates   map[string]time.Time
+	updates int
+}
+
+func (s *stubPostsRepository) GetTrashedPosts() ([]post_models.Post, error) {
+	return s.trashed, nil
+}
+
+func (s *stubPostsRepository) GetPostBySlug(slug string) (*post_models.Post, error) {
+	post, ok := s.posts[slug]
+	if !ok {
+		return nil, v5.ErrNoRows
+	}
+	copied := *post
+	return &copied, nil
+}
+
+func (s *stubPostsRepository) ImportPost(post post_models.PostRequestBody, userId int, createdAt time.Time) (int, error) {
+	postId := len(s.posts) + 1
+	s.store(postId, 1, post)
+	s.dates[post.Slug] = createdAt
+	return postId, nil
+}
+
+func (s *stubPostsRepository) ReimportPost(post post_models.PostRequestBody, postId, userId, version int, createdAt time.Time) (*post_models.PostRequestBody, error) {
+	if version != s.posts[post.Slug].Version {
+		return nil, posts_repo.ErrVersionConflict
+	}
+	s.updates++
+	s.store(postId, version+1, post)
+	s.dates[post.Slug] = createdAt
+	return &post, nil
+}
+
+func (s *stubPostsRepository) store(postId, version int, post post_models.PostRequestBody) {
+	tags := append([]string{}, post.Tags...)
+	s.posts[post.Slug] = &post_models.Post{PostId: postId, Title: post.Title, Content: post.Content, Restricted: post.Restricted, Slug: post.Slug, Status: post.Status, Version: version, Tags: tags}
+}
+
+type stubMediaRepository struct {
+	media_repo.MediaRepository
+	media []media_models.Post
+}
+
+func (s *stubMediaRepository) GetMediaByPostId(postId int) ([]media_models.Post, error) {
+	var media []media_models.Post
+	for _, m := range s.media {
+		if m.PostId == postId {
+			media = append(media, m)
+		}
+	}
+	return media, nil
+}
+
+func (s *stubMediaRepository) UploadMedia(postId int, blobName, contentType string, restricted bool, capturedAt *time.Time, camera string) error {
+	s.media = append(s.media, media_models.Post{PostId: postId, BlobName: blobName, ContentType: contentType, Restricted: restricted})
+	return nil
+}
+
+type stubBlobs struct {
+	uploaded []string
+	data     map[string][]byte
+}
+
+func (s *stubBlobs) UploadBlob(r io.Reader, blobName string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.uploaded = append(s.uploaded, blobName)
+	s.data[blobName] = data
+	return nil
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func TestImportDir(t *testing.T) {
+	dir := t.TempDir()
+	static := t.TempDir()
+	writeFile(t, filepath.Join(dir, "2019-05-01-hello.md"), []byte("---\ntitle: Hello\ntags: [Go]\n---\nIntro\n\n![cover](images/cover.png)\n\n![logo](/logo.png \"Logo\")\n\n![remote](https://example.dev/a.png)\n"))
+	writeFile(t, filepath.Join(dir, "images", "cover.png"), commentedPNG(t))
+	writeFile(t, filepath.Join(static, "logo.png"), commentedPNG(t))
+	writeFile(t, filepath.Join(dir, "draft.md"), []byte("+++\ntitle = \"Draft\"\ndate = 2020-01-02\ndraft = true\n+++\nWork in progress"))
+	writeFile(t, filepath.Join(dir, "gone.md"), []byte("---\ntitle: Gone\ndate: 2020-01-02\n---\nDeleted"))
+	writeFile(t, filepath.Join(dir, "broken.md"), []byte("---\ntitle: Broken\ndate: 2020-01-02\n---\n![missing](nope.png)"))
+	writeFile(t, filepath.Join(dir, "notes.txt"), []byte("not a post"))
+
+	posts := &stubPostsRepository{posts: map[string]*post_models.Post{}, dates: map[string]time.Time{}, trashed: []post_models.Post{{Slug: "gone"}}}
+	media := &stubMediaRepository{}
+	blobs := &stubBlobs{data: map[string][]byte{}}
+	var out bytes.Buffer
+	im := &importer{postsRepository: posts, mediaRepository: media, blobs: blobs, userId: 1, staticDir: static, out: &out}
+
+	// A dry run reports without writing
+	im.dryRun = true
+	counts, err := im.importDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[Outcome]int{OutcomeCreated: 2, OutcomeSkipped: 1, OutcomeFailed: 1}, counts)
+	assert.Contains(t, out.String(), "would create    2019-05-01-hello.md (2019-05-01, 2 image(s))")
+	assert.Empty(t, posts.posts)
+	assert.Empty(t, blobs.uploaded)
+
+	im.dryRun = false
+	counts, err = im.importDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[Outcome]int{OutcomeCreated: 2, OutcomeSkipped: 1, OutcomeFailed: 1}, counts)
+	hello := posts.posts["hello"]
+	assert.Equal(t, "Intro\n\n![remote](https://example.dev/a.png)", hello.Content)
+	assert.Equal(t, []string{"go"}, hello.Tags)
+	assert.Equal(t, post_models.StatusPublished, hello.Status)
+	assert.Equal(t, time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), posts.dates["hello"])
+	assert.Equal(t, post_models.StatusDraft, posts.posts["draft"].Status)
+	assert.ElementsMatch(t, []string{"blog-media/1_cover.png", "blog-media/1_logo.png"}, blobs.uploaded)
+	for blobName, data := range blobs.data {
+		assert.NotContains(t, string(data), "a secret comment", blobName)
+	}
+
+	// Running again changes nothing
+	counts, err = im.importDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[Outcome]int{OutcomeUnchanged: 2, OutcomeSkipped: 1, OutcomeFailed: 1}, counts)
+	assert.Len(t, blobs.uploaded, 2)
+	assert.Len(t, posts.posts, 2)
+
+	// Edited posts are only overwritten when asked to
+	posts.posts["draft"].Content = "edited in the app"
+	counts, err = im.importDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, counts[OutcomeSkipped], "the edited post and the trashed one")
+	assert.Equal(t, 0, posts.updates)
+
+	im.update = true
+	counts, err = im.importDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, counts[OutcomeUpdated])
+	assert.Equal(t, "Work in progress", posts.posts["draft"].Content)
+	assert.Equal(t, 2, posts.posts["draft"].Version)
+
+	// Publishing a draft keeps the date in its front matter
+	delete(posts.dates, "draft")
+	writeFile(t, filepath.Join(dir, "draft.md"), []byte("+++\ntitle = \"Draft\"\ndate = 2020-01-02\n+++\nWork in progress"))
+	counts, err = im.importDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, counts[OutcomeUpdated])
+	assert.Equal(t, post_models.StatusPublished, posts.posts["draft"].Status)
+	assert.True(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Equal(posts.dates["draft"]), "date %v", posts.dates["draft"])
+}
@@ -0,0 +1,69 @@
+// Command import loads a directory of Hugo or Jekyll Markdown posts into the blog.
+//
+// Each .md file needs YAML (---) or TOML (+++) front matter with at least a title and a date; tags, draft,
+// restricted and slug are optional. Posts keep their original dates. Local images the posts reference are
+// uploaded as post attachments and their inline references removed. Posts are matched to earlier runs by
+// slug, so importing the same directory twice only adds what is new.
+//
+//	go run ./cmd/import -dir ./content/posts -user 1 -dry-run
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/KylerJacobson/blog/backend/internal/db/config"
+	mediaRepo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	postsRepo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+func main() {
+	dir := flag.String("dir", "", "directory of Markdown posts to import")
+	userId := flag.Int("user", 0, "id of the user the posts are attributed to")
+	staticDir := flag.String("static", "", "directory that site-absolute image paths such as /images/a.png resolve against")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing anything")
+	update := flag.Bool("update", false, "overwrite posts that changed since they were imported")
+	flag.Parse()
+	if *dir == "" || *userId == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	zapLogger, err := logger.NewLogger(os.Getenv("ENVIRONMENT"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer zapLogger.Sync()
+
+	dbPool := config.GetDBConn(zapLogger)
+	defer dbPool.Close()
+
+	blobs, err := storage.FromEnv(os.Getenv("SITE_URL"), zapLogger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	im := &importer{
+		postsRepository: postsRepo.New(dbPool, zapLogger),
+		mediaRepository: mediaRepo.New(dbPool, zapLogger),
+		blobs:           blobs,
+		userId:          *userId,
+		staticDir:       *staticDir,
+		dryRun:          *dryRun,
+		update:          *update,
+		out:             os.Stdout,
+	}
+	counts, err := im.importDir(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d created, %d updated, %d unchanged, %d skipped, %d failed\n",
+		counts[OutcomeCreated], counts[OutcomeUpdated], counts[OutcomeUnchanged], counts[OutcomeSkipped], counts[OutcomeFailed])
+	if counts[OutcomeFailed] > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,27 +1,46 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/KylerJacobson/blog/backend/internal/authorization"
 	"github.com/KylerJacobson/blog/backend/internal/middleware"
-	"github.com/KylerJacobson/blog/backend/internal/services/azure"
+	federation "github.com/KylerJacobson/blog/backend/internal/services/activitypub"
+	archiver "github.com/KylerJacobson/blog/backend/internal/services/archive"
+	"github.com/KylerJacobson/blog/backend/internal/services/digest"
 	"github.com/KylerJacobson/blog/backend/internal/services/emailer"
 	"github.com/KylerJacobson/blog/backend/internal/services/notifications"
+	"github.com/KylerJacobson/blog/backend/internal/services/publisher"
+	"github.com/KylerJacobson/blog/backend/internal/services/purger"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/KylerJacobson/blog/backend/internal/services/variants"
 
+	activityPubRepo "github.com/KylerJacobson/blog/backend/internal/db/activitypub"
 	analyticsRepo "github.com/KylerJacobson/blog/backend/internal/db/analytics"
+	archiveRepo "github.com/KylerJacobson/blog/backend/internal/db/archive"
+	commentsRepo "github.com/KylerJacobson/blog/backend/internal/db/comments"
 	"github.com/KylerJacobson/blog/backend/internal/db/config"
+	groupsRepo "github.com/KylerJacobson/blog/backend/internal/db/groups"
 
 	mediaRepo "github.com/KylerJacobson/blog/backend/internal/db/media"
 	postsRepo "github.com/KylerJacobson/blog/backend/internal/db/posts"
 	usersRepo "github.com/KylerJacobson/blog/backend/internal/db/users"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/activitypub"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/analytics"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/archive"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/comments"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/feeds"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/groups"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/media"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/pages"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/posts"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/sitemap"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/users"
 	"github.com/KylerJacobson/blog/backend/logger"
 )
@@ -39,9 +58,6 @@ func main() {
 	dbPool := config.GetDBConn(zapLogger)
 	defer dbPool.Close()
 
-	// Setup Azure client
-	azureClient := azure.NewAzureClient(zapLogger)
-
 	// Setup SendGrid client
 	apiKey := os.Getenv("SENDGRID_API_KEY")
 	if apiKey == "" {
@@ -54,6 +70,28 @@ func main() {
 	emailer := emailer.NewEmailerService(sendGridClient, "kyler@kylerjacobson.dev", "Kyler Jacobson")
 	notifier := notifications.NewNotificationsService(emailer)
 
+	// Public origin used for absolute links in feeds and the sitemap
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "https://kylerjacobson.dev"
+	}
+
+	// Media storage backend, chosen by STORAGE_BACKEND
+	blobStore, err := storage.FromEnv(siteURL, zapLogger)
+	if err != nil {
+		zapLogger.Sugar().Fatalf("error setting up media storage: %v", err)
+	}
+
+	// Deleted posts are purged from the trash after TRASH_RETENTION_DAYS
+	trashRetention := purger.DefaultRetention
+	if days := os.Getenv("TRASH_RETENTION_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			zapLogger.Sugar().Fatalf("TRASH_RETENTION_DAYS must be a positive integer, got %q", days)
+		}
+		trashRetention = time.Duration(n) * 24 * time.Hour
+	}
+
 	// Setup session manager
 	session.Init()
 
@@ -62,7 +100,7 @@ func main() {
 
 	// Setup Middleware
 	authService := authorization.NewAuthService(zapLogger)
-	am := middleware.NewAuthMiddleware(authService, zapLogger)
+	am := middleware.NewAuthMiddleware(authService, blobStore.Origin(), zapLogger)
 	rl := middleware.NewRateLimiter(zapLogger)
 
 	// Setup API handlers
@@ -70,73 +108,62 @@ func main() {
 	postsRepo := postsRepo.New(dbPool, zapLogger)
 	analyticsRepo := analyticsRepo.New(dbPool, zapLogger)
 	mediaRepo := mediaRepo.New(dbPool, zapLogger)
+	commentsRepo := commentsRepo.New(dbPool, zapLogger)
+	groupsRepo := groupsRepo.New(dbPool, zapLogger)
+	archiveRepo := archiveRepo.New(dbPool, zapLogger)
+	activityPubRepo := activityPubRepo.New(dbPool, zapLogger)
+
+	// The blog's fediverse actor is @blog@<site host>
+	federator, err := federation.New(postsRepo, activityPubRepo, &http.Client{Timeout: 10 * time.Second}, siteURL,
+		federation.Profile{Username: "blog", Name: pages.SiteName, Summary: pages.SiteDescription}, zapLogger)
+	if err != nil {
+		zapLogger.Sugar().Fatalf("error setting up federation: %v", err)
+	}
 
 	analyticsApi := analytics.New(analyticsRepo, zapLogger)
 	usersApi := users.New(usersRepo, authService, zapLogger)
-	postsApi := posts.New(postsRepo, usersRepo, notifier, authService, zapLogger)
+	postsApi := posts.New(postsRepo, usersRepo, notifier, federator, blobStore, authService, zapLogger)
 	sessionApi := session.New(usersRepo, zapLogger)
-	mediaApi := media.New(mediaRepo, authService, zapLogger, azureClient)
-
-	// ---------------------------- Posts ----------------------------
-	mux.HandleFunc("GET /api/posts", am.SecurityHeaders(am.EnableCORS(rl.Limit(postsApi.GetPosts))))
-	mux.HandleFunc("GET /api/posts/recent", am.SecurityHeaders(am.EnableCORS(rl.Limit(postsApi.GetRecentPosts))))
-	mux.HandleFunc("GET /api/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(postsApi.GetPostById))))
-	mux.HandleFunc("DELETE /api/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(postsApi.DeletePostById)))))
-	mux.HandleFunc("POST /api/posts", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(postsApi.CreatePost)))))
-	mux.HandleFunc("PUT /api/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(postsApi.UpdatePost)))))
-
-	// ---------------------------- Users ----------------------------
-	mux.HandleFunc("POST /api/user", am.SecurityHeaders(am.EnableCORS(rl.Limit(usersApi.CreateUser))))
-	mux.HandleFunc("GET /api/user", am.SecurityHeaders(am.EnableCORS(rl.Limit(usersApi.GetUserFromSession))))
-	mux.HandleFunc("GET /api/user/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(usersApi.GetUserById))))
-	mux.HandleFunc("PUT /api/user/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(usersApi.UpdateUser))))
-	mux.HandleFunc("DELETE /api/user/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(usersApi.DeleteUserById))))
-
-	// ---------------------------- Admin ----------------------------
-	mux.HandleFunc("GET /api/user/list", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(usersApi.ListUsers)))))
-
-	// ---------------------------- Session ----------------------------
-	mux.HandleFunc("POST /api/session", am.SecurityHeaders(am.EnableCORS(rl.StrictLimit(sessionApi.CreateSession))))
-	mux.HandleFunc("DELETE /api/session", am.SecurityHeaders(am.EnableCORS(rl.Limit(sessionApi.DeleteSession))))
-
-	// ---------------------------- Media ----------------------------
-	mux.HandleFunc("POST /api/media", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(mediaApi.UploadMedia)))))
-	mux.HandleFunc("GET /api/media/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(mediaApi.GetMediaByPostId))))
-	mux.HandleFunc("DELETE /api/media/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(mediaApi.DeleteMediaByPostId)))))
-
-	// ---------------------------- Analytics ----------------------------
-	// Route for recording page views (doesn't need authentication)
-	mux.HandleFunc("POST /api/analytics/pageview", am.SecurityHeaders(am.EnableCORS(rl.Limit(analyticsApi.RecordPageView))))
-
-	// Route for getting analytics summary (admin only)
-	mux.HandleFunc("GET /api/analytics/summary", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(analyticsApi.GetSummary)))))
-
-	// Add a route for data retention/cleanup (admin only)
-	mux.HandleFunc("POST /api/analytics/purge", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(analyticsApi.PurgeOldData)))))
-
-	// Add a route for removing admin data (admin only)
-	mux.HandleFunc("POST /api/analytics/admin-purge", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(analyticsApi.PurgeAdminData)))))
-
-	// Serve static files from the React build directory
-	fs := http.FileServer(http.Dir("public"))
-
-	// Handle all non-API routes by serving the React app
-	mux.HandleFunc("/", am.SecurityHeaders(am.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
-		// Don't handle API routes here
-		if strings.HasPrefix(r.URL.Path, "/api/") {
-			http.NotFound(w, r)
-			return
-		}
-
-		// Serve index.html for all routes to support React Router
-		if !strings.Contains(r.URL.Path, ".") {
-			http.ServeFile(w, r, "public/index.html")
-			return
-		}
-
-		// Serve static files (CSS, JS, images, etc.)
-		fs.ServeHTTP(w, r)
-	})))
+	// Uploads wake the processor; the interval catches anything a failed run left behind
+	variantProcessor := variants.New(mediaRepo, blobStore, 15*time.Minute, zapLogger)
+	mediaApi := media.New(mediaRepo, postsRepo, authService, zapLogger, blobStore, variantProcessor)
+	commentsApi := comments.New(commentsRepo, postsRepo, authService, zapLogger)
+	groupsApi := groups.New(groupsRepo, zapLogger)
+	feedsApi := feeds.New(postsRepo, siteURL, zapLogger)
+	sitemapApi := sitemap.New(postsRepo, siteURL, zapLogger)
+	archiveApi := archive.New(archiver.New(archiveRepo, blobStore, zapLogger), zapLogger)
+	activityPubApi := activitypub.New(federator, zapLogger)
+	pagesApi := pages.New(postsRepo, mediaRepo, authService, siteURL, "public/index.html", zapLogger)
+
+	// Setup scheduled post publisher
+	postPublisher := publisher.New(postsRepo, postsApi.NotifyOnNewPost, time.Minute, zapLogger)
+	go postPublisher.Run(context.Background())
+
+	// Setup trash purger
+	trashPurger := purger.New(postsRepo, blobStore, trashRetention, time.Hour, zapLogger)
+	go trashPurger.Run(context.Background())
+
+	// Setup digest emails
+	digester := digest.New(usersRepo, postsRepo, notifier, time.Hour, zapLogger)
+	go digester.Run(context.Background())
+
+	// Setup responsive image variants
+	go variantProcessor.Run(context.Background())
+
+	registerRoutes(mux, am, rl, blobStore, apis{
+		analytics:   analyticsApi,
+		users:       usersApi,
+		posts:       postsApi,
+		session:     sessionApi,
+		media:       mediaApi,
+		comments:    commentsApi,
+		groups:      groupsApi,
+		feeds:       feedsApi,
+		sitemap:     sitemapApi,
+		archive:     archiveApi,
+		activityPub: activityPubApi,
+		pages:       pagesApi,
+	})
 
 	zapLogger.Sugar().Infof("Logging level set to %s", env)
 	zapLogger.Sugar().Infof("listening on port: %d", 8080)
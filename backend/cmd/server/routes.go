@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/KylerJacobson/blog/backend/internal/handlers/activitypub"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/analytics"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/archive"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/comments"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/feeds"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/groups"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/media"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/pages"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/sitemap"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/users"
+	"github.com/KylerJacobson/blog/backend/internal/middleware"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+)
+
+// apis holds every handler the server routes to
+type apis struct {
+	analytics   analytics.AnalyticsApi
+	users       users.UsersApi
+	posts       posts.PostsApi
+	session     session.SessionApi
+	media       media.MediaApi
+	comments    comments.CommentsApi
+	groups      groups.GroupsApi
+	feeds       feeds.FeedsApi
+	sitemap     sitemap.SitemapApi
+	archive     archive.ArchiveApi
+	activityPub activitypub.ActivityPubApi
+	pages       pages.PagesApi
+}
+
+// registerRoutes registers every route the server answers on mux. ServeMux panics on patterns that overlap
+// without one being more specific, so building the mux in a test catches conflicting routes.
+func registerRoutes(mux *http.ServeMux, am *middleware.AuthMiddleware, rl *middleware.RateLimiter, blobStore storage.BlobStore, h apis) {
+	// ---------------------------- Posts ----------------------------
+	mux.HandleFunc("GET /api/posts", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.GetPosts))))
+	mux.HandleFunc("GET /api/posts/search", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.SearchPosts))))
+	mux.HandleFunc("GET /api/posts/recent", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.GetRecentPosts))))
+	mux.HandleFunc("GET /api/slugs/{slug}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.GetPostBySlug))))
+	mux.HandleFunc("GET /api/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.GetPostById))))
+	mux.HandleFunc("DELETE /api/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.DeletePostById)))))
+	mux.HandleFunc("GET /api/posts/trash", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.GetTrash)))))
+	mux.HandleFunc("POST /api/posts/trash/{id}/restore", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.RestorePost)))))
+	mux.HandleFunc("DELETE /api/posts/trash/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.PurgePost)))))
+	mux.HandleFunc("POST /api/posts", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.CreatePost)))))
+	mux.HandleFunc("PUT /api/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.UpdatePost)))))
+	mux.HandleFunc("GET /api/posts/{id}/revisions", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.GetRevisions)))))
+	mux.HandleFunc("GET /api/posts/{id}/revisions/diff", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.DiffRevisions)))))
+	mux.HandleFunc("GET /api/posts/{id}/revisions/{revisionId}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.GetRevision)))))
+	mux.HandleFunc("POST /api/posts/{id}/revisions/{revisionId}/restore", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.RestoreRevision)))))
+	mux.HandleFunc("GET /api/posts/{id}/grants", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.GetPostGrants)))))
+	mux.HandleFunc("PUT /api/posts/{id}/grants", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.SetPostGrants)))))
+	mux.HandleFunc("GET /api/posts/{id}/translations", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.GetTranslations)))))
+	mux.HandleFunc("PUT /api/posts/{id}/translations/{locale}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.SetTranslation)))))
+	mux.HandleFunc("DELETE /api/posts/{id}/translations/{locale}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.posts.DeleteTranslation)))))
+
+	// ---------------------------- Comments ----------------------------
+	mux.HandleFunc("GET /api/posts/{id}/comments", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.comments.GetComments))))
+	mux.HandleFunc("POST /api/posts/{id}/comments", am.SecurityHeaders(am.EnableCORS(rl.StrictLimit(am.RequireAuth(h.comments.CreateComment)))))
+	mux.HandleFunc("PUT /api/comments/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAuth(h.comments.UpdateComment)))))
+	mux.HandleFunc("DELETE /api/comments/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAuth(h.comments.DeleteComment)))))
+	mux.HandleFunc("GET /api/comments/pending", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.comments.GetModerationQueue)))))
+	mux.HandleFunc("POST /api/comments/{id}/approve", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.comments.ApproveComment)))))
+	mux.HandleFunc("POST /api/comments/{id}/reject", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.comments.RejectComment)))))
+
+	// ---------------------------- Groups ----------------------------
+	mux.HandleFunc("GET /api/groups", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.groups.GetGroups)))))
+	mux.HandleFunc("POST /api/groups", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.groups.CreateGroup)))))
+	mux.HandleFunc("DELETE /api/groups/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.groups.DeleteGroup)))))
+	mux.HandleFunc("GET /api/groups/{id}/members", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.groups.GetMembers)))))
+	mux.HandleFunc("PUT /api/groups/{id}/members/{userId}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.groups.AddMember)))))
+	mux.HandleFunc("DELETE /api/groups/{id}/members/{userId}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.groups.RemoveMember)))))
+
+	// ---------------------------- Tags ----------------------------
+	mux.HandleFunc("GET /api/tags", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.GetTags))))
+	mux.HandleFunc("GET /api/tags/{tag}/posts", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.posts.GetPostsByTag))))
+
+	// ---------------------------- Users ----------------------------
+	mux.HandleFunc("POST /api/user", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.users.CreateUser))))
+	mux.HandleFunc("GET /api/user", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.users.GetUserFromSession))))
+	mux.HandleFunc("GET /api/user/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.users.GetUserById))))
+	mux.HandleFunc("PUT /api/user/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.users.UpdateUser))))
+	mux.HandleFunc("DELETE /api/user/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.users.DeleteUserById))))
+
+	// ---------------------------- Admin ----------------------------
+	mux.HandleFunc("GET /api/user/list", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.users.ListUsers)))))
+
+	// ---------------------------- Session ----------------------------
+	mux.HandleFunc("POST /api/session", am.SecurityHeaders(am.EnableCORS(rl.StrictLimit(h.session.CreateSession))))
+	mux.HandleFunc("DELETE /api/session", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.session.DeleteSession))))
+
+	// ---------------------------- Media ----------------------------
+	mux.HandleFunc("POST /api/media", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.media.UploadMedia)))))
+	mux.HandleFunc("GET /api/media/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.media.GetMediaByPostId))))
+	mux.HandleFunc("GET /api/media/file/{mediaId}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.media.GetMediaFile))))
+	mux.HandleFunc("GET /api/media/file/{mediaId}/{variant}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.media.GetMediaFile))))
+	mux.HandleFunc("DELETE /api/media/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.media.DeleteMediaByPostId)))))
+	if localStore, ok := blobStore.(*storage.LocalStore); ok {
+		mux.HandleFunc("GET "+storage.LocalPath+"{name...}", am.SecurityHeaders(am.EnableCORS(rl.Limit(localStore.ServeBlob))))
+	}
+
+	// ---------------------------- Archive ----------------------------
+	mux.HandleFunc("GET /api/export", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.archive.Export)))))
+
+	// ---------------------------- Analytics ----------------------------
+	// Route for recording page views (doesn't need authentication)
+	mux.HandleFunc("POST /api/analytics/pageview", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.analytics.RecordPageView))))
+
+	// Route for getting analytics summary (admin only)
+	mux.HandleFunc("GET /api/analytics/summary", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.analytics.GetSummary)))))
+
+	// Add a route for data retention/cleanup (admin only)
+	mux.HandleFunc("POST /api/analytics/purge", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.analytics.PurgeOldData)))))
+
+	// Add a route for removing admin data (admin only)
+	mux.HandleFunc("POST /api/analytics/admin-purge", am.SecurityHeaders(am.EnableCORS(rl.Limit(am.RequireAdmin(h.analytics.PurgeAdminData)))))
+
+	// ---------------------------- Feeds ----------------------------
+	// Registered explicitly so the SPA catch-all below never serves index.html for them
+	mux.HandleFunc("GET /feed.xml", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.feeds.GetRSS))))
+	mux.HandleFunc("GET /atom.xml", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.feeds.GetAtom))))
+	mux.HandleFunc("GET /feed.json", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.feeds.GetJSONFeed))))
+
+	// ---------------------------- Crawlers ----------------------------
+	mux.HandleFunc("GET /sitemap.xml", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.sitemap.GetSitemap))))
+	mux.HandleFunc("GET /sitemaps/{page}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.sitemap.GetSitemapPage))))
+	mux.HandleFunc("GET /robots.txt", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.sitemap.GetRobots))))
+
+	// ---------------------------- Federation ----------------------------
+	mux.HandleFunc("GET /.well-known/webfinger", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.activityPub.WebFinger))))
+	mux.HandleFunc("GET /ap/actor", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.activityPub.GetActor))))
+	mux.HandleFunc("GET /ap/outbox", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.activityPub.GetOutbox))))
+	mux.HandleFunc("GET /ap/followers", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.activityPub.GetFollowers))))
+	mux.HandleFunc("GET /ap/posts/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.activityPub.GetObject))))
+	mux.HandleFunc("POST /ap/inbox", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.activityPub.PostInbox))))
+
+	// Post pages carry their own link preview metadata; the app itself is the same index.html
+	mux.HandleFunc("GET /post/{id}", am.SecurityHeaders(am.EnableCORS(rl.Limit(h.pages.GetPostPage))))
+
+	// Serve static files from the React build directory
+	fs := http.FileServer(http.Dir("public"))
+
+	// Handle all non-API routes by serving the React app
+	mux.HandleFunc("/", am.SecurityHeaders(am.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
+		// Don't handle API routes here
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Serve index.html for all routes to support React Router
+		if !strings.Contains(r.URL.Path, ".") {
+			http.ServeFile(w, r, "public/index.html")
+			return
+		}
+
+		// Serve static files (CSS, JS, images, etc.)
+		fs.ServeHTTP(w, r)
+	})))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/activitypub"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/analytics"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/archive"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/comments"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/feeds"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/groups"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/media"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/pages"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/sitemap"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/users"
+	"github.com/KylerJacobson/blog/backend/internal/middleware"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// testMux registers every route the way main does. Nothing is served, so the handlers need no dependencies.
+func testMux(t *testing.T) *http.ServeMux {
+	logger := zap.NewNop()
+	// The local store adds the route for its own blobs
+	blobStore, err := storage.NewLocalStore(t.TempDir(), "http://localhost", nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authService := authorization.NewAuthService(logger)
+	mux := http.NewServeMux()
+	registerRoutes(mux, middleware.NewAuthMiddleware(authService, blobStore.Origin(), logger), middleware.NewRateLimiter(logger), blobStore, apis{
+		analytics:   analytics.New(nil, logger),
+		users:       users.New(nil, authService, logger),
+		posts:       posts.New(nil, nil, nil, nil, blobStore, authService, logger),
+		session:     session.New(nil, logger),
+		media:       media.New(nil, nil, authService, logger, blobStore, nil),
+		comments:    comments.New(nil, nil, authService, logger),
+		groups:      groups.New(nil, logger),
+		feeds:       feeds.New(nil, "http://localhost", logger),
+		sitemap:     sitemap.New(nil, "http://localhost", logger),
+		archive:     archive.New(nil, logger),
+		activityPub: activitypub.New(nil, logger),
+		pages:       pages.New(nil, nil, authService, "http://localhost", "public/index.html", logger),
+	})
+	return mux
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	// Registering panics when two patterns overlap without one being more specific
+	mux := testMux(t)
+
+	tests := []struct {
+		method  string
+		path    string
+		pattern string
+	}{
+		{method: http.MethodGet, path: "/api/slugs/revisions", pattern: "GET /api/slugs/{slug}"},
+		{method: http.MethodGet, path: "/api/posts/7", pattern: "GET /api/posts/{id}"},
+		{method: http.MethodGet, path: "/api/posts/trash", pattern: "GET /api/posts/trash"},
+		{method: http.MethodGet, path: "/api/posts/7/revisions", pattern: "GET /api/posts/{id}/revisions"},
+		{method: http.MethodGet, path: "/api/posts/7/revisions/diff", pattern: "GET /api/posts/{id}/revisions/diff"},
+		{method: http.MethodGet, path: "/api/posts/7/revisions/3", pattern: "GET /api/posts/{id}/revisions/{revisionId}"},
+		{method: http.MethodPost, path: "/api/posts/7/revisions/3/restore", pattern: "POST /api/posts/{id}/revisions/{revisionId}/restore"},
+		{method: http.MethodGet, path: "/api/posts/7/comments", pattern: "GET /api/posts/{id}/comments"},
+		{method: http.MethodPost, path: "/api/posts/7/comments", pattern: "POST /api/posts/{id}/comments"},
+		{method: http.MethodGet, path: "/api/comments/pending", pattern: "GET /api/comments/pending"},
+		{method: http.MethodPost, path: "/api/comments/4/approve", pattern: "POST /api/comments/{id}/approve"},
+		{method: http.MethodGet, path: "/api/posts/7/grants", pattern: "GET /api/posts/{id}/grants"},
+		{method: http.MethodPut, path: "/api/posts/7/grants", pattern: "PUT /api/posts/{id}/grants"},
+		{method: http.MethodGet, path: "/api/posts/7/translations", pattern: "GET /api/posts/{id}/translations"},
+		{method: http.MethodPut, path: "/api/posts/7/translations/es", pattern: "PUT /api/posts/{id}/translations/{locale}"},
+		{method: http.MethodDelete, path: "/api/posts/7/translations/es", pattern: "DELETE /api/posts/{id}/translations/{locale}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			_, pattern := mux.Handler(httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.pattern, pattern)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// ContentType is the media type ActivityPub documents are served and delivered as
+	ContentType = "application/activity+json"
+	// Public addresses an activity to everyone
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Context is the JSON-LD context of every document the blog serves
+var Context = []string{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	Id                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type PublicKey struct {
+	Id           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Article is how a post federates; Mastodon shows its name as the title and links to url
+type Article struct {
+	Context      any       `json:"@context,omitempty"`
+	Id           string    `json:"id"`
+	Type         string    `json:"type"`
+	AttributedTo string    `json:"attributedTo"`
+	Name         string    `json:"name"`
+	Summary      string    `json:"summary,omitempty"`
+	Content      string    `json:"content"`
+	URL          string    `json:"url"`
+	Published    time.Time `json:"published"`
+	To           []string  `json:"to"`
+	Cc           []string  `json:"cc"`
+	Tag          []Tag     `json:"tag,omitempty"`
+}
+
+type Tag struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// Activity is an activity the blog sends
+type Activity struct {
+	Context   any        `json:"@context,omitempty"`
+	Id        string     `json:"id"`
+	Type      string     `json:"type"`
+	Actor     string     `json:"actor"`
+	Published *time.Time `json:"published,omitempty"`
+	To        []string   `json:"to,omitempty"`
+	Cc        []string   `json:"cc,omitempty"`
+	Object    any        `json:"object"`
+}
+
+// IncomingActivity is an activity delivered to the inbox. Its object is either an id or an embedded object,
+// so it is left raw until the type says which.
+type IncomingActivity struct {
+	Id     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	Id           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	First        string `json:"first,omitempty"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any    `json:"@context,omitempty"`
+	Id           string `json:"id"`
+	Type         string `json:"type"`
+	PartOf       string `json:"partOf"`
+	Next         string `json:"next,omitempty"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+// WebFinger is the JSON Resource Descriptor that maps acct:user@host to the actor
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// Follower is a remote actor that follows the blog
+type Follower struct {
+	FollowerId  int       `json:"follower_id" db:"follower_id"`
+	ActorId     string    `json:"actor_id" db:"actor_id"`
+	Inbox       string    `json:"inbox" db:"inbox"`
+	SharedInbox *string   `json:"shared_inbox,omitempty" db:"shared_inbox"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,37 @@
+package archive
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Manifest describes an archive and is stored in it as manifest.json. Restore trusts nothing else in the
+// archive until it checks against the manifest.
+type Manifest struct {
+	// FormatVersion changes whenever the layout of the archive does
+	FormatVersion int `json:"format_version"`
+	// SchemaVersion is the number of the last database migration the archive was taken with
+	SchemaVersion     int            `json:"schema_version"`
+	CreatedAt         time.Time      `json:"created_at"`
+	IncludesPasswords bool           `json:"includes_passwords"`
+	IncludesBlobs     bool           `json:"includes_blobs"`
+	Tables            []ManifestFile `json:"tables"`
+	Blobs             []ManifestFile `json:"blobs"`
+}
+
+// ManifestFile is one file in the archive. Rows is only set for tables.
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Rows   int    `json:"rows,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Table is the contents of one database table as a JSON array of row objects
+type Table struct {
+	Name string
+	Rows json.RawMessage
+	// Count is the number of rows in Rows
+	Count int
+}
@@ -0,0 +1,27 @@
+package comments
+
+import "time"
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+type Comment struct {
+	CommentId  int       `json:"comment_id" db:"comment_id"`
+	PostId     int       `json:"post_id" db:"post_id"`
+	ParentId   *int      `json:"parent_id,omitempty" db:"parent_id"`
+	UserId     int       `json:"userId" db:"user_id"`
+	AuthorName string    `json:"author_name" db:"author_name"`
+	Body       string    `json:"body" db:"body"`
+	Status     string    `json:"status" db:"status"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+	Replies    []Comment `json:"replies,omitempty" db:"-"`
+}
+
+type CommentRequestBody struct {
+	Body     string `json:"body"`
+	ParentId *int   `json:"parent_id,omitempty"`
+}
@@ -0,0 +1,14 @@
+package groups
+
+import "time"
+
+type Group struct {
+	GroupId     int       `json:"group_id" db:"group_id"`
+	Name        string    `json:"name" db:"name"`
+	MemberCount int       `json:"member_count" db:"member_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type GroupRequestBody struct {
+	Name string `json:"name"`
+}
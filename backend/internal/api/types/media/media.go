@@ -5,9 +5,24 @@ import (
 )
 
 type Post struct {
+	MediaId     int       `json:"mediaId" db:"media_id"`
 	PostId      int       `json:"postId" db:"post_id"`
 	BlobName    string    `json:"blobName" db:"blob_name"`
 	ContentType string    `json:"contentType" db:"content_type"`
 	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
 	Restricted  bool      `json:"restricted" db:"restricted"`
+	// CapturedAt and Camera come from the upload's EXIF and are only shown to admins
+	CapturedAt *time.Time `json:"capturedAt,omitempty" db:"captured_at"`
+	Camera     *string    `json:"camera,omitempty" db:"camera"`
+}
+
+// Variant is a resized rendition of an image attachment, or its square thumbnail
+type Variant struct {
+	MediaId     int       `json:"mediaId" db:"media_id"`
+	Name        string    `json:"name" db:"name"`
+	BlobName    string    `json:"blobName" db:"blob_name"`
+	Width       int       `json:"width" db:"width"`
+	Height      int       `json:"height" db:"height"`
+	ContentType string    `json:"contentType" db:"content_type"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
 }
@@ -1,28 +1,163 @@
 package posts
 
-import (
-	"time"
+import "time"
+
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
 )
 
 type Post struct {
-	PostId     int       `json:"post_id" db:"post_id"`
-	Title      string    `json:"title" db:"title"`
-	Content    string    `json:"content" db:"content"`
-	UserId     int       `json:"userId" db:"user_id"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
-	Restricted bool      `json:"restricted" db:"restricted"`
+	PostId     int        `json:"post_id" db:"post_id"`
+	Title      string     `json:"title" db:"title"`
+	Content    string     `json:"content" db:"content"`
+	UserId     int        `json:"userId" db:"user_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updatedAt" db:"updated_at"`
+	Restricted bool       `json:"restricted" db:"restricted"`
+	Slug       string     `json:"slug" db:"slug"`
+	Status     string     `json:"status" db:"status"`
+	PublishAt  *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Version    int        `json:"version" db:"version"`
+	Tags       []string   `json:"tags" db:"tags"`
+	// Locale is the language Title and Content are in; Locales lists every language the post can be read in,
+	// the one it was written in first
+	Locale  string   `json:"locale" db:"locale"`
+	Locales []string `json:"locales" db:"locales"`
+	// ContentHTML and TOC are only filled in when the caller asks for rendered content
+	ContentHTML string    `json:"content_html,omitempty" db:"-"`
+	TOC         []Heading `json:"toc,omitempty" db:"-"`
+}
+
+// Heading is one entry of a post's table of contents; Id is the anchor the rendered heading carries
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Id    string `json:"id"`
 }
 
 type FrontendPostRequest struct {
 	PostRequestBody `json:"postData"`
 }
 type PostRequestBody struct {
-	Title      string `json:"title" db:"title"`
-	Content    string `json:"content" db:"content"`
-	Restricted bool   `json:"restricted" db:"restricted"`
+	Title      string     `json:"title" db:"title"`
+	Content    string     `json:"content" db:"content"`
+	Restricted bool       `json:"restricted" db:"restricted"`
+	Slug       string     `json:"slug,omitempty" db:"slug"`
+	Status     string     `json:"status,omitempty" db:"status"`
+	PublishAt  *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	Version    int        `json:"version,omitempty" db:"version"`
+	// Locale is the language the post is written in; leaving it out keeps the current one, English for new posts
+	Locale string `json:"locale,omitempty" db:"locale"`
+	// Tags replaces the post's tags when present; leaving it out keeps the current ones
+	Tags []string `json:"tags,omitempty" db:"-"`
+	// Grants replaces who the post is shared with when present; leaving it out keeps the current grants
+	Grants *PostGrants `json:"grants,omitempty" db:"-"`
+}
+
+// PostGrants opens a post to individual users and to every member of the listed groups
+type PostGrants struct {
+	Groups []int `json:"groups"`
+	Users  []int `json:"users"`
+}
+
+// PostTranslation is a post in a language other than the one it was written in
+type PostTranslation struct {
+	PostId    int       `json:"post_id" db:"post_id"`
+	Locale    string    `json:"locale" db:"locale"`
+	Title     string    `json:"title" db:"title"`
+	Content   string    `json:"content" db:"content"`
+	Slug      string    `json:"slug" db:"slug"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 type CreatedPost struct {
 	PostId int `json:"post_id" db:"post_id"`
 }
+
+// PostCursor identifies the last post of a page so the next page can resume after it
+type PostCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	PostId    int       `json:"post_id"`
+}
+
+// Viewer is who is asking to read posts; the zero Viewer is an anonymous visitor who only sees public posts
+type Viewer struct {
+	UserId     int
+	Privileged bool
+	Admin      bool
+}
+
+// PostFilter narrows a paginated post listing
+type PostFilter struct {
+	Limit      int
+	After      *PostCursor
+	From       *time.Time
+	To         *time.Time
+	Restricted *bool
+	Viewer     Viewer
+	// Status narrows the listing to one status; only honored together with IncludeUnpublished
+	Status             string
+	IncludeUnpublished bool
+	Tag                string
+	// PublishedAfter and PublishedUntil bound when posts went live, rather than when they were written
+	PublishedAfter *time.Time
+	PublishedUntil *time.Time
+	// Locales swaps in the first translation the reader prefers over the language each post was written in
+	Locales []string
+}
+
+type PostPage struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// SearchResult is a post matched by full-text search with highlighted excerpts
+type SearchResult struct {
+	Post
+	Rank           float32 `json:"rank" db:"rank"`
+	TitleHighlight string  `json:"title_highlight" db:"title_highlight"`
+	Snippet        string  `json:"snippet" db:"snippet"`
+}
+
+// PostRevision is a full snapshot of a post as saved by UserId at CreatedAt
+type PostRevision struct {
+	RevisionId int       `json:"revision_id" db:"revision_id"`
+	PostId     int       `json:"post_id" db:"post_id"`
+	Title      string    `json:"title" db:"title"`
+	Content    string    `json:"content" db:"content"`
+	Restricted bool      `json:"restricted" db:"restricted"`
+	Slug       string    `json:"slug" db:"slug"`
+	Status     string    `json:"status" db:"status"`
+	UserId     int       `json:"userId" db:"user_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type RevisionDiff struct {
+	From    int        `json:"from"`
+	To      int        `json:"to"`
+	Title   []DiffLine `json:"title"`
+	Content []DiffLine `json:"content"`
+}
+
+// DiffLine is one line of a revision diff; Op is "equal", "insert" or "delete"
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// PostSummary carries just enough of a post to link to it
+type PostSummary struct {
+	PostId    int       `json:"post_id" db:"post_id"`
+	Slug      string    `json:"slug" db:"slug"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type Tag struct {
+	Name      string `json:"name" db:"name"`
+	PostCount int    `json:"post_count" db:"post_count"`
+}
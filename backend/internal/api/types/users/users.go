@@ -2,6 +2,22 @@ package users
 
 import "time"
 
+// How often a member is emailed about new posts
+const (
+	FrequencyImmediate = "immediate"
+	FrequencyDaily     = "daily"
+	FrequencyWeekly    = "weekly"
+	FrequencyOff       = "off"
+)
+
+// NotificationFrequencies lists the accepted notification frequencies
+var NotificationFrequencies = map[string]bool{
+	FrequencyImmediate: true,
+	FrequencyDaily:     true,
+	FrequencyWeekly:    true,
+	FrequencyOff:       true,
+}
+
 type FullUser struct {
 	Id                string    `json:"id" db:"id"`
 	FirstName         string    `json:"firstName" db:"first_name"`
@@ -14,12 +30,21 @@ type FullUser struct {
 	EmailNotification bool      `json:"emailNotification" db:"email_notification"`
 }
 type User struct {
-	Id                string `json:"id" db:"id"`
-	FirstName         string `json:"firstName" db:"first_name"`
-	LastName          string `json:"lastName" db:"last_name"`
-	Email             string `json:"email" db:"email"`
-	Role              int    `json:"role" db:"role"`
-	EmailNotification bool   `json:"emailNotification" db:"email_notification"`
+	Id                    string `json:"id" db:"id"`
+	FirstName             string `json:"firstName" db:"first_name"`
+	LastName              string `json:"lastName" db:"last_name"`
+	Email                 string `json:"email" db:"email"`
+	Role                  int    `json:"role" db:"role"`
+	EmailNotification     bool   `json:"emailNotification" db:"email_notification"`
+	NotificationFrequency string `json:"notificationFrequency" db:"notification_frequency"`
+	// Locale is the language the member reads posts in, used to pick translations for their emails
+	Locale string `json:"locale" db:"locale"`
+}
+
+// DigestSubscriber is a member who gets new posts as a digest
+type DigestSubscriber struct {
+	User
+	LastDigestAt *time.Time `db:"last_digest_at"`
 }
 
 type AccountCreationRequest struct {
@@ -41,6 +66,10 @@ type UserUpdate struct {
 	Email             string `json:"email" db:"email"`
 	Role              int    `json:"role" db:"role"`
 	EmailNotification bool   `json:"emailNotification" db:"email_notification"`
+	// NotificationFrequency is optional; without it EmailNotification turns notifications off or back on
+	NotificationFrequency string `json:"notificationFrequency,omitempty" db:"notification_frequency"`
+	// Locale is optional; leaving it out keeps the member's current one
+	Locale string `json:"locale,omitempty" db:"locale"`
 }
 
 type UserLoginForm struct {
@@ -52,11 +81,13 @@ type UserLogin struct {
 }
 
 type FrontendUser struct {
-	Id                string    `json:"id" db:"id"`
-	FirstName         string    `json:"firstName" db:"first_name"`
-	LastName          string    `json:"lastName" db:"last_name"`
-	Email             string    `json:"email" db:"email"`
-	CreatedAt         time.Time `json:"createdAt" db:"created_at"`
-	Role              int       `json:"role" db:"role"`
-	EmailNotification bool      `json:"emailNotification" db:"email_notification"`
+	Id                    string    `json:"id" db:"id"`
+	FirstName             string    `json:"firstName" db:"first_name"`
+	LastName              string    `json:"lastName" db:"last_name"`
+	Email                 string    `json:"email" db:"email"`
+	CreatedAt             time.Time `json:"createdAt" db:"created_at"`
+	Role                  int       `json:"role" db:"role"`
+	EmailNotification     bool      `json:"emailNotification" db:"email_notification"`
+	NotificationFrequency string    `json:"notificationFrequency" db:"notification_frequency"`
+	Locale                string    `json:"locale" db:"locale"`
 }
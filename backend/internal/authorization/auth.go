@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
 	"github.com/KylerJacobson/blog/backend/logger"
 	"github.com/golang-jwt/jwt/v5"
@@ -39,6 +40,20 @@ func NewAuthService(logger logger.Logger) *AuthService {
 	}
 }
 
+// NewViewer describes a user for post access checks
+func NewViewer(userId, role int) post_models.Viewer {
+	return post_models.Viewer{
+		UserId:     userId,
+		Privileged: role == RoleAdmin || role == RolePrivileged,
+		Admin:      role == RoleAdmin,
+	}
+}
+
+// Viewer describes the signed in user, or an anonymous visitor, for post access checks
+func (a *AuthService) Viewer(r *http.Request) post_models.Viewer {
+	return NewViewer(session.Manager.GetInt(r.Context(), "user_id"), session.Manager.GetInt(r.Context(), "user_role"))
+}
+
 func (a *AuthService) CheckPrivilege(r *http.Request) bool {
 	role := session.Manager.GetInt(r.Context(), "user_role")
 
@@ -47,3 +62,12 @@ func (a *AuthService) CheckPrivilege(r *http.Request) bool {
 	}
 	return false
 }
+
+// IsAuthenticated reports whether the request carries a signed in session
+func (a *AuthService) IsAuthenticated(r *http.Request) bool {
+	return session.Manager.GetInt(r.Context(), "user_id") != 0 || session.Manager.GetInt(r.Context(), "user_role") != 0
+}
+
+func (a *AuthService) IsAdmin(r *http.Request) bool {
+	return session.Manager.GetInt(r.Context(), "user_role") == RoleAdmin
+}
@@ -0,0 +1,110 @@
+package activitypub
+
+import (
+	"context"
+
+	ap_models "github.com/KylerJacobson/blog/backend/internal/api/types/activitypub"
+	"github.com/KylerJacobson/blog/backend/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ActorKeyId names the key of the blog's one actor
+const ActorKeyId = "main"
+
+type ActivityPubRepository interface {
+	GetActorKey() (string, error)
+	CreateActorKey(privateKeyPem string) error
+	GetFollowers() ([]ap_models.Follower, error)
+	CountFollowers() (int, error)
+	AddFollower(follower ap_models.Follower) error
+	RemoveFollower(actorId string) error
+}
+
+type activityPubRepository struct {
+	conn   *pgxpool.Pool
+	logger logger.Logger
+}
+
+func New(conn *pgxpool.Pool, logger logger.Logger) *activityPubRepository {
+	return &activityPubRepository{
+		conn:   conn,
+		logger: logger,
+	}
+}
+
+// GetActorKey returns the actor's PEM encoded private key, or pgx.ErrNoRows before one has been created
+func (repository *activityPubRepository) GetActorKey() (string, error) {
+	var key string
+	err := repository.conn.QueryRow(
+		context.TODO(), `SELECT private_key FROM activitypub_keys WHERE key_id = $1`, ActorKeyId,
+	).Scan(&key)
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// CreateActorKey stores the actor's key unless another server instance got there first; read it back with
+// GetActorKey to use whichever won
+func (repository *activityPubRepository) CreateActorKey(privateKeyPem string) error {
+	_, err := repository.conn.Exec(
+		context.TODO(), `INSERT INTO activitypub_keys (key_id, private_key) VALUES ($1, $2) ON CONFLICT (key_id) DO NOTHING`,
+		ActorKeyId, privateKeyPem,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error creating the actor key: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (repository *activityPubRepository) GetFollowers() ([]ap_models.Follower, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT follower_id, actor_id, inbox, shared_inbox, created_at FROM followers ORDER BY follower_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followers, err := pgx.CollectRows(rows, pgx.RowToStructByName[ap_models.Follower])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting followers from the database: %v", err)
+		return nil, err
+	}
+	return followers, nil
+}
+
+func (repository *activityPubRepository) CountFollowers() (int, error) {
+	var count int
+	err := repository.conn.QueryRow(context.TODO(), `SELECT count(*) FROM followers`).Scan(&count)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error counting followers: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// AddFollower records a follow; following again only refreshes the inboxes
+func (repository *activityPubRepository) AddFollower(follower ap_models.Follower) error {
+	_, err := repository.conn.Exec(
+		context.TODO(), `INSERT INTO followers (actor_id, inbox, shared_inbox) VALUES ($1, $2, $3)
+		ON CONFLICT (actor_id) DO UPDATE SET inbox = excluded.inbox, shared_inbox = excluded.shared_inbox`,
+		follower.ActorId, follower.Inbox, follower.SharedInbox,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error adding follower %s: %v", follower.ActorId, err)
+		return err
+	}
+	return nil
+}
+
+func (repository *activityPubRepository) RemoveFollower(actorId string) error {
+	_, err := repository.conn.Exec(context.TODO(), `DELETE FROM followers WHERE actor_id = $1`, actorId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error removing follower %s: %v", actorId, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+
+	archive_models "github.com/KylerJacobson/blog/backend/internal/api/types/archive"
+	"github.com/KylerJacobson/blog/backend/logger"
+	pgxV5 "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// table is one table the archive carries. Tables are listed parents first so a restore can insert them in
+// order, and rows are exported in key order so self references such as comment replies come after their parent.
+type table struct {
+	name    string
+	orderBy string
+	// source replaces the table itself when the archive keeps something other than its rows
+	source string
+}
+
+// tables leaves out sessions, which are worthless on another server, raw page views, which the archive only
+// keeps as daily totals, and the ActivityPub key, which a restored server generates again
+var tables = []table{
+	{name: "users", orderBy: "id"},
+	{name: "posts", orderBy: "post_id"},
+	{name: "post_slug_redirects", orderBy: "slug"},
+	{name: "post_translations", orderBy: "post_id, locale"},
+	{name: "post_revisions", orderBy: "revision_id"},
+	{name: "tags", orderBy: "tag_id"},
+	{name: "post_tags", orderBy: "post_id, tag_id"},
+	{name: "media", orderBy: "media_id"},
+	{name: "media_variants", orderBy: "media_id, name"},
+	{name: "comments", orderBy: "comment_id"},
+	{name: "groups", orderBy: "group_id"},
+	{name: "group_members", orderBy: "group_id, user_id"},
+	{name: "post_grants", orderBy: "grant_id"},
+	{name: "followers", orderBy: "follower_id"},
+	{name: "page_view_totals", orderBy: "day, path", source: `
+		SELECT day, path, sum(views)::integer AS views, sum(visitors)::integer AS visitors
+		FROM (
+			SELECT timestamp::date AS day, path, count(*) AS views, count(DISTINCT visitor_id) AS visitors
+			FROM page_views
+			GROUP BY 1, 2
+			UNION ALL
+			SELECT day, path, views, visitors FROM page_view_totals
+		) v
+		GROUP BY day, path`},
+}
+
+// TableNames lists the tables an archive holds in the order they are restored
+func TableNames() []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.name
+	}
+	return names
+}
+
+type ArchiveRepository interface {
+	ExportTables(includePasswords bool) ([]archive_models.Table, error)
+	IsEmpty() (bool, error)
+	RestoreTables(tables []archive_models.Table, includesPasswords bool) error
+}
+
+type archiveRepository struct {
+	conn   *pgxpool.Pool
+	logger logger.Logger
+}
+
+func New(conn *pgxpool.Pool, logger logger.Logger) *archiveRepository {
+	return &archiveRepository{
+		conn:   conn,
+		logger: logger,
+	}
+}
+
+// ExportTables reads every archived table from one snapshot so the tables agree with each other. Generated
+// columns are left out since a restore can't write them, and so are password hashes unless includePasswords.
+func (repository *archiveRepository) ExportTables(includePasswords bool) ([]archive_models.Table, error) {
+	ctx := context.TODO()
+	tx, err := repository.conn.BeginTx(ctx, pgxV5.TxOptions{IsoLevel: pgxV5.RepeatableRead, AccessMode: pgxV5.ReadOnly})
+	if err != nil {
+		repository.logger.Sugar().Errorf("error starting the export transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	generated, err := generatedColumns(ctx, tx)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting generated columns: %v", err)
+		return nil, err
+	}
+	exported := make([]archive_models.Table, 0, len(tables))
+	for _, t := range tables {
+		// A nil list would be sent as NULL, and subtracting NULL from a row blanks it
+		omit := append([]string{}, generated[t.name]...)
+		if t.name == "users" && !includePasswords {
+			omit = append(omit, "password")
+		}
+		source := t.name
+		if t.source != "" {
+			source = "(" + t.source + ")"
+		}
+		table := archive_models.Table{Name: t.name}
+		err := tx.QueryRow(ctx, fmt.Sprintf(
+			`SELECT count(*), coalesce(jsonb_agg(to_jsonb(t) - $1::text[] ORDER BY %s), '[]') FROM %s t`, t.orderBy, source,
+		), omit).Scan(&table.Count, &table.Rows)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error exporting table %s: %v", t.name, err)
+			return nil, err
+		}
+		exported = append(exported, table)
+	}
+	return exported, nil
+}
+
+// IsEmpty reports whether none of the archived tables have any rows, which is the only state a restore accepts
+func (repository *archiveRepository) IsEmpty() (bool, error) {
+	for _, t := range tables {
+		var exists bool
+		err := repository.conn.QueryRow(context.TODO(), fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s)`, t.name)).Scan(&exists)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error checking whether table %s is empty: %v", t.name, err)
+			return false, err
+		}
+		if exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RestoreTables loads exported tables into an empty database in one transaction and moves every id sequence
+// past the restored rows. Users restored without password hashes get a random
+// one, so they can't sign in until their password is set again.
+func (repository *archiveRepository) RestoreTables(restored []archive_models.Table, includesPasswords bool) error {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error starting the restore transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	byName := make(map[string]archive_models.Table, len(restored))
+	for _, t := range restored {
+		byName[t.Name] = t
+	}
+	generated, err := generatedColumns(ctx, tx)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting generated columns: %v", err)
+		return err
+	}
+	for _, t := range tables {
+		table, ok := byName[t.name]
+		if !ok {
+			return fmt.Errorf("archive has no table %s", t.name)
+		}
+		defaults := "{}"
+		if t.name == "users" && !includesPasswords {
+			defaults = `{"password": ""}`
+		}
+		columns, err := insertableColumns(ctx, tx, t.name, generated[t.name])
+		if err != nil {
+			repository.logger.Sugar().Errorf("error getting the columns of table %s: %v", t.name, err)
+			return err
+		}
+		tag, err := tx.Exec(ctx, fmt.Sprintf(
+			`INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM jsonb_array_elements($1::jsonb) r, jsonb_populate_record(null::%[1]s, $2::jsonb || r) p`,
+			t.name, columns,
+		), string(table.Rows), defaults)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error restoring table %s: %v", t.name, err)
+			return err
+		}
+		if int(tag.RowsAffected()) != table.Count {
+			return fmt.Errorf("restored %d rows into %s, expected %d", tag.RowsAffected(), t.name, table.Count)
+		}
+	}
+	if !includesPasswords {
+		_, err = tx.Exec(ctx, `UPDATE users SET password = crypt(encode(gen_random_bytes(32), 'hex'), gen_salt('bf', 8)) WHERE password = ''`)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error setting placeholder passwords: %v", err)
+			return err
+		}
+	}
+	err = resetSequences(ctx, tx)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error resetting sequences: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// generatedColumns maps each table to its generated columns
+func generatedColumns(ctx context.Context, tx pgxV5.Tx) (map[string][]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND is_generated = 'ALWAYS'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	generated := map[string][]string{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		generated[table] = append(generated[table], column)
+	}
+	return generated, rows.Err()
+}
+
+// insertableColumns lists the columns of table a restore writes, quoted and comma separated
+func insertableColumns(ctx context.Context, tx pgxV5.Tx, table string, generated []string) (string, error) {
+	var columns string
+	err := tx.QueryRow(ctx, `
+		SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position) FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_name <> ALL($2::text[])`,
+		table, append([]string{}, generated...),
+	).Scan(&columns)
+	return columns, err
+}
+
+// resetSequences moves every serial column's sequence past the largest id restored into it
+func resetSequences(ctx context.Context, tx pgxV5.Tx) error {
+	rows, err := tx.Query(ctx, `
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_default LIKE 'nextval(%'`)
+	if err != nil {
+		return err
+	}
+	type serial struct{ table, column string }
+	var serials []serial
+	for rows.Next() {
+		var s serial
+		if err := rows.Scan(&s.table, &s.column); err != nil {
+			rows.Close()
+			return err
+		}
+		serials = append(serials, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, s := range serials {
+		_, err := tx.Exec(ctx, fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence($1, $2), coalesce(max(%s), 0) + 1, false) FROM %s`,
+			pgxV5.Identifier{s.column}.Sanitize(), pgxV5.Identifier{s.table}.Sanitize(),
+		), s.table, s.column)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
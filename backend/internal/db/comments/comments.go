@@ -0,0 +1,139 @@
+package comments
+
+import (
+	"context"
+
+	comment_models "github.com/KylerJacobson/blog/backend/internal/api/types/comments"
+	"github.com/KylerJacobson/blog/backend/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const commentColumns = `c.comment_id, c.post_id, c.parent_id, c.user_id, trim(u.first_name || ' ' || u.last_name) AS author_name,
+	c.body, c.status, c.created_at, c.updated_at`
+
+const commentsFrom = ` FROM comments c JOIN users u ON u.id = c.user_id`
+
+type CommentsRepository interface {
+	GetCommentsByPostId(postId, viewerId int, includeUnapproved bool) ([]comment_models.Comment, error)
+	GetCommentById(commentId int) (*comment_models.Comment, error)
+	GetPendingComments() ([]comment_models.Comment, error)
+	CreateComment(postId int, parentId *int, userId int, body, status string) (*comment_models.Comment, error)
+	UpdateCommentBody(commentId int, body, status string) (*comment_models.Comment, error)
+	SetCommentStatus(commentId int, status string) error
+	DeleteComment(commentId int) error
+}
+
+type commentsRepository struct {
+	conn   *pgxpool.Pool
+	logger logger.Logger
+}
+
+func New(conn *pgxpool.Pool, logger logger.Logger) *commentsRepository {
+	return &commentsRepository{
+		conn:   conn,
+		logger: logger,
+	}
+}
+
+// GetCommentsByPostId returns the post's comments oldest first. Readers see approved comments plus their own
+// pending ones; includeUnapproved returns everything for moderators.
+func (repository *commentsRepository) GetCommentsByPostId(postId, viewerId int, includeUnapproved bool) ([]comment_models.Comment, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT `+commentColumns+commentsFrom+`
+		WHERE c.post_id = $1 AND ($3 OR c.status = 'approved' OR (c.status = 'pending' AND c.user_id = $2))
+		ORDER BY c.created_at, c.comment_id`, postId, viewerId, includeUnapproved,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments, err := pgx.CollectRows(rows, pgx.RowToStructByName[comment_models.Comment])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting comments for post %d from the database: %v", postId, err)
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (repository *commentsRepository) GetCommentById(commentId int) (*comment_models.Comment, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT `+commentColumns+commentsFrom+` WHERE c.comment_id = $1`, commentId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comment, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[comment_models.Comment])
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetPendingComments is the moderation queue, oldest first
+func (repository *commentsRepository) GetPendingComments() ([]comment_models.Comment, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT `+commentColumns+commentsFrom+` WHERE c.status = 'pending' ORDER BY c.created_at, c.comment_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments, err := pgx.CollectRows(rows, pgx.RowToStructByName[comment_models.Comment])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting pending comments from the database: %v", err)
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (repository *commentsRepository) CreateComment(postId int, parentId *int, userId int, body, status string) (*comment_models.Comment, error) {
+	var commentId int
+	err := repository.conn.QueryRow(
+		context.TODO(), `INSERT INTO comments (post_id, parent_id, user_id, body, status) VALUES ($1, $2, $3, $4, $5) RETURNING comment_id`,
+		postId, parentId, userId, body, status,
+	).Scan(&commentId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error creating comment on post %d : %v", postId, err)
+		return nil, err
+	}
+	return repository.GetCommentById(commentId)
+}
+
+func (repository *commentsRepository) UpdateCommentBody(commentId int, body, status string) (*comment_models.Comment, error) {
+	_, err := repository.conn.Exec(
+		context.TODO(), `UPDATE comments SET body = $1, status = $2, updated_at = now() WHERE comment_id = $3`, body, status, commentId,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error updating comment %d : %v", commentId, err)
+		return nil, err
+	}
+	return repository.GetCommentById(commentId)
+}
+
+func (repository *commentsRepository) SetCommentStatus(commentId int, status string) error {
+	tag, err := repository.conn.Exec(
+		context.TODO(), `UPDATE comments SET status = $1 WHERE comment_id = $2`, status, commentId,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error setting status of comment %d to %s : %v", commentId, status, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (repository *commentsRepository) DeleteComment(commentId int) error {
+	_, err := repository.conn.Exec(context.TODO(), `DELETE FROM comments WHERE comment_id = $1`, commentId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error deleting comment %d : %v", commentId, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package groups
+
+import (
+	"context"
+	"errors"
+
+	group_models "github.com/KylerJacobson/blog/backend/internal/api/types/groups"
+	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
+	"github.com/KylerJacobson/blog/backend/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres error codes the repository translates
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// ErrDuplicateName means another group already uses the name
+var ErrDuplicateName = errors.New("group name already exists")
+
+// memberColumns are the users columns GetMembers reads; they must cover every field of user_models.User
+const memberColumns = `u.id, u.first_name, u.last_name, u.email, u.role, u.email_notification, u.notification_frequency, u.locale`
+
+const groupColumns = `g.group_id, g.name, g.created_at,
+	(SELECT count(*) FROM group_members m WHERE m.group_id = g.group_id) AS member_count`
+
+type GroupsRepository interface {
+	GetGroups() ([]group_models.Group, error)
+	CreateGroup(name string) (*group_models.Group, error)
+	DeleteGroup(groupId int) error
+	GetMembers(groupId int) ([]user_models.User, error)
+	AddMember(groupId, userId int) error
+	RemoveMember(groupId, userId int) error
+}
+
+type groupsRepository struct {
+	conn   *pgxpool.Pool
+	logger logger.Logger
+}
+
+func New(conn *pgxpool.Pool, logger logger.Logger) *groupsRepository {
+	return &groupsRepository{
+		conn:   conn,
+		logger: logger,
+	}
+}
+
+func (repository *groupsRepository) GetGroups() ([]group_models.Group, error) {
+	rows, err := repository.conn.Query(context.TODO(), `SELECT `+groupColumns+` FROM groups g ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups, err := pgx.CollectRows(rows, pgx.RowToStructByName[group_models.Group])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting groups from the database: %v", err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+// CreateGroup adds an empty group, returning ErrDuplicateName when the name is taken
+func (repository *groupsRepository) CreateGroup(name string) (*group_models.Group, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `WITH g AS (INSERT INTO groups (name) VALUES ($1) RETURNING group_id, name, created_at)
+		SELECT g.group_id, g.name, g.created_at, 0::bigint AS member_count FROM g`, name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	group, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[group_models.Group])
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return nil, ErrDuplicateName
+	}
+	if err != nil {
+		repository.logger.Sugar().Errorf("error creating group %s: %v", name, err)
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup removes a group; its memberships and post grants go with it
+func (repository *groupsRepository) DeleteGroup(groupId int) error {
+	tag, err := repository.conn.Exec(context.TODO(), `DELETE FROM groups WHERE group_id = $1`, groupId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error deleting group %d: %v", groupId, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// GetMembers lists a group's users by name; an unknown group is pgx.ErrNoRows
+func (repository *groupsRepository) GetMembers(groupId int) ([]user_models.User, error) {
+	var exists bool
+	err := repository.conn.QueryRow(context.TODO(), `SELECT true FROM groups WHERE group_id = $1`, groupId).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT `+memberColumns+`
+		FROM group_members m JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = $1 ORDER BY u.last_name, u.first_name, u.id`, groupId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members, err := collectMembers(rows)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting members of group %d from the database: %v", groupId, err)
+		return nil, err
+	}
+	return members, nil
+}
+
+func collectMembers(rows pgx.Rows) ([]user_models.User, error) {
+	return pgx.CollectRows(rows, pgx.RowToStructByName[user_models.User])
+}
+
+// AddMember puts a user in a group; adding an existing member is a no-op. An unknown group or user is pgx.ErrNoRows.
+func (repository *groupsRepository) AddMember(groupId, userId int) error {
+	_, err := repository.conn.Exec(
+		context.TODO(), `INSERT INTO group_members (group_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, groupId, userId,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return pgx.ErrNoRows
+	}
+	if err != nil {
+		repository.logger.Sugar().Errorf("error adding user %d to group %d: %v", userId, groupId, err)
+		return err
+	}
+	return nil
+}
+
+func (repository *groupsRepository) RemoveMember(groupId, userId int) error {
+	tag, err := repository.conn.Exec(context.TODO(), `DELETE FROM group_members WHERE group_id = $1 AND user_id = $2`, groupId, userId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error removing user %d from group %d: %v", userId, groupId, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
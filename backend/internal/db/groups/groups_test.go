@@ -0,0 +1,53 @@
+package groups
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRows returns rows of values for the named columns, standing in for a query result
+type fakeRows struct {
+	pgx.Rows
+	fields []pgconn.FieldDescription
+	rows   [][]any
+	next   int
+}
+
+func newFakeRows(columns string, rows ...[]any) *fakeRows {
+	var fields []pgconn.FieldDescription
+	for _, column := range strings.Split(columns, ",") {
+		_, name, _ := strings.Cut(strings.TrimSpace(column), ".")
+		fields = append(fields, pgconn.FieldDescription{Name: name})
+	}
+	return &fakeRows{fields: fields, rows: rows}
+}
+
+func (f *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return f.fields }
+func (f *fakeRows) Next() bool                                   { f.next++; return f.next <= len(f.rows) }
+func (f *fakeRows) Err() error                                   { return nil }
+func (f *fakeRows) Close()                                       {}
+
+func (f *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(f.rows[f.next-1][i]))
+	}
+	return nil
+}
+
+func TestCollectMembers(t *testing.T) {
+	rows := newFakeRows(memberColumns, []any{"3", "Ada", "Lovelace", "ada@example.com", 2, true, "weekly", "en"})
+
+	members, err := collectMembers(rows)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []user_models.User{{
+		Id: "3", FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Role: 2,
+		EmailNotification: true, NotificationFrequency: "weekly", Locale: "en",
+	}}, members)
+}
@@ -3,6 +3,7 @@ package media
 import (
 	"context"
 	"errors"
+	"time"
 
 	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
 	"github.com/KylerJacobson/blog/backend/logger"
@@ -12,8 +13,13 @@ import (
 
 type MediaRepository interface {
 	GetMediaByPostId(postId int) ([]media_models.Post, error)
-	UploadMedia(postId int, blobName, contentType string, restricted bool) error
+	GetMediaById(mediaId int) (*media_models.Post, error)
+	UploadMedia(postId int, blobName, contentType string, restricted bool, capturedAt *time.Time, camera string) error
 	DeleteMediaByPostId(postId int) error
+	GetUnprocessedMedia(contentTypes []string, limit int) ([]media_models.Post, error)
+	SaveVariants(mediaId int, variants []media_models.Variant) error
+	GetVariantsByPostId(postId int) ([]media_models.Variant, error)
+	GetVariant(mediaId int, name string) (*media_models.Variant, error)
 }
 
 type mediaRepository struct {
@@ -30,7 +36,7 @@ func New(conn *pgxpool.Pool, logger logger.Logger) *mediaRepository {
 
 func (repository *mediaRepository) GetMediaByPostId(postId int) ([]media_models.Post, error) {
 	rows, err := repository.conn.Query(
-		context.TODO(), `SELECT post_id, blob_name, content_type, created_at, restricted FROM media WHERE post_id = $1`, postId,
+		context.TODO(), `SELECT media_id, post_id, blob_name, content_type, created_at, restricted, captured_at, camera FROM media WHERE post_id = $1 AND deleted_at IS NULL ORDER BY media_id`, postId,
 	)
 	if err != nil {
 		return nil, err
@@ -50,9 +56,30 @@ func (repository *mediaRepository) GetMediaByPostId(postId int) ([]media_models.
 	return media, nil
 }
 
-func (repository *mediaRepository) UploadMedia(postId int, blobName, contentType string, restricted bool) error {
+// GetMediaById returns pgx.ErrNoRows for attachments that don't exist or are in the trash
+func (repository *mediaRepository) GetMediaById(mediaId int) (*media_models.Post, error) {
 	rows, err := repository.conn.Query(
-		context.TODO(), `INSERT INTO media (post_id, blob_name, content_type, restricted) VALUES ($1, $2, $3, $4) RETURNING *`, postId, blobName, contentType, restricted,
+		context.TODO(), `SELECT media_id, post_id, blob_name, content_type, created_at, restricted, captured_at, camera FROM media WHERE media_id = $1 AND deleted_at IS NULL`, mediaId,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting media %d: %v", mediaId, err)
+		return nil, err
+	}
+	media, err := pgxV5.CollectOneRow(rows, pgxV5.RowToStructByName[media_models.Post])
+	if err != nil {
+		if !errors.Is(err, pgxV5.ErrNoRows) {
+			repository.logger.Sugar().Errorf("error getting media %d: %v", mediaId, err)
+		}
+		return nil, err
+	}
+	return &media, nil
+}
+
+// UploadMedia records an attachment; capturedAt and camera come from its EXIF, with nil and "" for unknown
+func (repository *mediaRepository) UploadMedia(postId int, blobName, contentType string, restricted bool, capturedAt *time.Time, camera string) error {
+	rows, err := repository.conn.Query(
+		context.TODO(), `INSERT INTO media (post_id, blob_name, content_type, restricted, captured_at, camera) VALUES ($1, $2, $3, $4, $5, nullif($6::text, '')) RETURNING *`,
+		postId, blobName, contentType, restricted, capturedAt, camera,
 	)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error creating adding media to post %d : %v", postId, err)
@@ -62,9 +89,10 @@ func (repository *mediaRepository) UploadMedia(postId int, blobName, contentType
 	return nil
 }
 
+// DeleteMediaByPostId leaves attachments in the trash alone, since purging the post cleans up their blobs
 func (repository *mediaRepository) DeleteMediaByPostId(postId int) error {
 	rows, err := repository.conn.Query(
-		context.TODO(), `DELETE FROM media WHERE post_id = $1`, postId,
+		context.TODO(), `DELETE FROM media WHERE post_id = $1 AND deleted_at IS NULL`, postId,
 	)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error deleting media for post %d: %v", postId, err)
@@ -73,3 +101,91 @@ func (repository *mediaRepository) DeleteMediaByPostId(postId int) error {
 	defer rows.Close()
 	return nil
 }
+
+// GetUnprocessedMedia returns the oldest attachments of the given types that still need variants made
+func (repository *mediaRepository) GetUnprocessedMedia(contentTypes []string, limit int) ([]media_models.Post, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT media_id, post_id, blob_name, content_type, created_at, restricted, captured_at, camera FROM media
+		WHERE variants_processed_at IS NULL AND deleted_at IS NULL AND content_type = ANY($1)
+		ORDER BY media_id LIMIT $2`, contentTypes, limit,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting media waiting for variants: %v", err)
+		return nil, err
+	}
+	media, err := pgxV5.CollectRows(rows, pgxV5.RowToStructByName[media_models.Post])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting media waiting for variants: %v", err)
+		return nil, err
+	}
+	return media, nil
+}
+
+// SaveVariants replaces an attachment's variants and marks it processed; no variants marks an attachment that
+// has none to make
+func (repository *mediaRepository) SaveVariants(mediaId int, variants []media_models.Variant) error {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error starting the transaction for variants of media %d: %v", mediaId, err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM media_variants WHERE media_id = $1`, mediaId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error clearing variants of media %d: %v", mediaId, err)
+		return err
+	}
+	for _, variant := range variants {
+		_, err = tx.Exec(ctx, `INSERT INTO media_variants (media_id, name, blob_name, width, height, content_type) VALUES ($1, $2, $3, $4, $5, $6)`,
+			mediaId, variant.Name, variant.BlobName, variant.Width, variant.Height, variant.ContentType)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error saving variant %s of media %d: %v", variant.Name, mediaId, err)
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, `UPDATE media SET variants_processed_at = now() WHERE media_id = $1`, mediaId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error marking media %d processed: %v", mediaId, err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetVariantsByPostId returns the variants of a post's attachments, narrowest first
+func (repository *mediaRepository) GetVariantsByPostId(postId int) ([]media_models.Variant, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT v.media_id, v.name, v.blob_name, v.width, v.height, v.content_type, v.created_at
+		FROM media_variants v JOIN media m ON m.media_id = v.media_id
+		WHERE m.post_id = $1 AND m.deleted_at IS NULL ORDER BY v.media_id, v.width`, postId,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting media variants for post %d: %v", postId, err)
+		return nil, err
+	}
+	variants, err := pgxV5.CollectRows(rows, pgxV5.RowToStructByName[media_models.Variant])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting media variants for post %d: %v", postId, err)
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (repository *mediaRepository) GetVariant(mediaId int, name string) (*media_models.Variant, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT media_id, name, blob_name, width, height, content_type, created_at FROM media_variants WHERE media_id = $1 AND name = $2`, mediaId, name,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting variant %s of media %d: %v", name, mediaId, err)
+		return nil, err
+	}
+	variant, err := pgxV5.CollectOneRow(rows, pgxV5.RowToStructByName[media_models.Variant])
+	if err != nil {
+		if !errors.Is(err, pgxV5.ErrNoRows) {
+			repository.logger.Sugar().Errorf("error getting variant %s of media %d: %v", name, mediaId, err)
+		}
+		return nil, err
+	}
+	return &variant, nil
+}
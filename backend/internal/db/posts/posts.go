@@ -2,20 +2,97 @@ package posts
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/slug"
 	"github.com/KylerJacobson/blog/backend/logger"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// postColumns selects a post as it was written
+var postColumns = selectPost("title", "content", "slug", "locale")
+
+// translatedPostColumns selects a post with the text of the translation joined by translated, when there is one
+var translatedPostColumns = selectPost("coalesce(tr_title, title)", "coalesce(tr_content, content)", "coalesce(tr_slug, slug)", "coalesce(tr_locale, locale)")
+
+// selectPost lists the columns of post_models.Post. title, content, slug and locale are SQL expressions so a
+// translation can stand in for the post's own text.
+func selectPost(title, content, slug, locale string) string {
+	return fmt.Sprintf(`post_id, %s AS title, %s AS content, user_id, created_at, updated_at, restricted, %s AS slug, status, publish_at, deleted_at, version,
+	%s AS locale,
+	array_prepend(posts.locale, coalesce((SELECT array_agg(pl.locale ORDER BY pl.locale) FROM post_translations pl WHERE pl.post_id = posts.post_id), '{}')) AS locales,
+	coalesce((SELECT array_agg(t.name ORDER BY t.name) FROM post_tags pt JOIN tags t ON t.tag_id = pt.tag_id WHERE pt.post_id = posts.post_id), '{}') AS tags`,
+		title, content, slug, locale)
+}
+
+// translated joins the translation of each post into the first of locales, a SQL text[] expression, as the tr_
+// columns translatedPostColumns reads. Posts written in a language the reader prefers keep their own text.
+func translated(locales string) string {
+	return fmt.Sprintf(`LEFT JOIN LATERAL (
+		SELECT t.title AS tr_title, t.content AS tr_content, t.slug AS tr_slug, t.locale AS tr_locale
+		FROM post_translations t
+		WHERE t.post_id = posts.post_id AND t.locale = ANY(%[1]s)
+			AND array_position(%[1]s, t.locale) < coalesce(array_position(%[1]s, posts.locale), 2147483647)
+		ORDER BY array_position(%[1]s, t.locale)
+		LIMIT 1) tr ON true`, locales)
+}
+
+// foreignKeyViolation is the Postgres error code for a reference to a missing row
+const foreignKeyViolation = "23503"
+
+// ErrVersionConflict means the post changed since the caller read it
+var ErrVersionConflict = errors.New("post was modified by someone else")
+
+// visibleTo is the one access rule for reading posts, shared by every query that lists or checks them. A post
+// that is neither restricted nor shared is public, restricted posts are open to privileged members and grants
+// open a post to the listed users and to members of the listed groups. A shared post whose grants were all
+// deleted along with their users or groups is open to nobody but admins. Admins skip the rule altogether.
+// table names the posts row being tested; userId and privileged are SQL expressions, usually placeholders.
+func visibleTo(table, userId, privileged string) string {
+	return fmt.Sprintf(`((NOT %[1]s.restricted AND NOT %[1]s.shared)
+		OR (%[1]s.restricted AND %[3]s)
+		OR EXISTS (SELECT 1 FROM post_grants g WHERE g.post_id = %[1]s.post_id
+			AND (g.user_id = %[2]s OR g.group_id IN (SELECT m.group_id FROM group_members m WHERE m.user_id = %[2]s))))`,
+		table, userId, privileged)
+}
+
+// publicOnly is visibleTo for an anonymous visitor
+var publicOnly = visibleTo("posts", "0", "false")
+
 type PostsRepository interface {
-	GetRecentPosts() ([]post_models.Post, error)
 	GetRecentPublicPosts() ([]post_models.Post, error)
+	GetPosts(filter post_models.PostFilter) ([]post_models.Post, error)
 	GetPostById(postId int) (*post_models.Post, error)
+	GetPostBySlug(slug string) (*post_models.Post, error)
+	SearchPosts(query string, locales []string, viewer post_models.Viewer, limit int) ([]post_models.SearchResult, error)
+	CanView(postId int, viewer post_models.Viewer) (bool, error)
+	GetPostGrants(postId int) (*post_models.PostGrants, error)
+	SetPostGrants(postId int, grants post_models.PostGrants) error
 	DeletePostById(postId int) error
+	GetTrashedPosts() ([]post_models.Post, error)
+	RestorePost(postId int) error
+	PurgePost(postId int) ([]string, error)
+	PurgeTrashedBefore(cutoff time.Time) (int, []string, error)
 	CreatePost(post post_models.PostRequestBody, userId int) (int, error)
-	UpdatePost(post post_models.PostRequestBody, postId, userId int) (*post_models.PostRequestBody, error)
+	ImportPost(post post_models.PostRequestBody, userId int, createdAt time.Time) (int, error)
+	UpdatePost(post post_models.PostRequestBody, postId, userId, version int) (*post_models.PostRequestBody, error)
+	ReimportPost(post post_models.PostRequestBody, postId, userId, version int, createdAt time.Time) (*post_models.PostRequestBody, error)
+	PublishDuePosts(now time.Time) ([]post_models.Post, error)
+	GetTranslations(postId int) ([]post_models.PostTranslation, error)
+	GetTranslation(postId int, locale string) (*post_models.PostTranslation, error)
+	SetTranslation(translation post_models.PostTranslation) (*post_models.PostTranslation, error)
+	DeleteTranslation(postId int, locale string) error
+	GetRevisions(postId int) ([]post_models.PostRevision, error)
+	GetRevision(postId, revisionId int) (*post_models.PostRevision, error)
+	GetTags(viewer post_models.Viewer, includeUnpublished bool) ([]post_models.Tag, error)
+	CountPublicPosts() (int, error)
+	GetPublicPostSummaries(offset, limit int) ([]post_models.PostSummary, error)
 }
 
 type postsRepository struct {
@@ -30,9 +107,9 @@ func New(conn *pgxpool.Pool, logger logger.Logger) *postsRepository {
 	}
 }
 
-func (repository *postsRepository) GetRecentPosts() ([]post_models.Post, error) {
+func (repository *postsRepository) GetRecentPublicPosts() ([]post_models.Post, error) {
 	rows, err := repository.conn.Query(
-		context.TODO(), `SELECT post_id, title, content, user_id, created_at, updated_at, restricted FROM posts ORDER BY created_at DESC LIMIT 10;`,
+		context.TODO(), `SELECT `+postColumns+` FROM posts WHERE `+publicOnly+` AND status = 'published' AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 10`,
 	)
 	if err != nil {
 		return nil, err
@@ -41,16 +118,64 @@ func (repository *postsRepository) GetRecentPosts() ([]post_models.Post, error)
 
 	posts, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.Post])
 	if err != nil {
-		repository.logger.Sugar().Errorf("error getting recent posts from the database: %v", err)
+		repository.logger.Sugar().Errorf("error getting recent public posts from the database: %v ", err)
 		return nil, err
 	}
 	return posts, nil
 }
 
-func (repository *postsRepository) GetRecentPublicPosts() ([]post_models.Post, error) {
-	rows, err := repository.conn.Query(
-		context.TODO(), `SELECT post_id, title, content, user_id, created_at, updated_at, restricted FROM posts WHERE restricted = false ORDER BY created_at DESC LIMIT 10`,
-	)
+// GetPosts returns up to filter.Limit posts ordered newest first, starting after filter.After
+func (repository *postsRepository) GetPosts(filter post_models.PostFilter) ([]post_models.Post, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []any
+	if !filter.Viewer.Admin {
+		args = append(args, filter.Viewer.UserId, filter.Viewer.Privileged)
+		conditions = append(conditions, visibleTo("posts", fmt.Sprintf("$%d::int", len(args)-1), fmt.Sprintf("$%d::boolean", len(args))))
+	}
+	if !filter.IncludeUnpublished {
+		conditions = append(conditions, "status = 'published'")
+	} else if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.Restricted != nil {
+		args = append(args, *filter.Restricted)
+		conditions = append(conditions, fmt.Sprintf("restricted = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.PublishedAfter != nil {
+		args = append(args, *filter.PublishedAfter)
+		conditions = append(conditions, fmt.Sprintf("publish_at > $%d", len(args)))
+	}
+	if filter.PublishedUntil != nil {
+		args = append(args, *filter.PublishedUntil)
+		conditions = append(conditions, fmt.Sprintf("publish_at <= $%d", len(args)))
+	}
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.tag_id = pt.tag_id WHERE pt.post_id = posts.post_id AND t.name = $%d)", len(args)))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.PostId)
+		conditions = append(conditions, fmt.Sprintf("(created_at, post_id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + postColumns + ` FROM posts WHERE ` + strings.Join(conditions, " AND ")
+	if len(filter.Locales) > 0 {
+		args = append(args, filter.Locales)
+		query = `SELECT ` + translatedPostColumns + ` FROM posts ` + translated(fmt.Sprintf("$%d::text[]", len(args))) + ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, post_id DESC LIMIT $%d", len(args))
+
+	rows, err := repository.conn.Query(context.TODO(), query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -58,7 +183,7 @@ func (repository *postsRepository) GetRecentPublicPosts() ([]post_models.Post, e
 
 	posts, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.Post])
 	if err != nil {
-		repository.logger.Sugar().Errorf("error getting recent public posts from the database: %v ", err)
+		repository.logger.Sugar().Errorf("error getting posts from the database: %v", err)
 		return nil, err
 	}
 	return posts, nil
@@ -67,7 +192,7 @@ func (repository *postsRepository) GetRecentPublicPosts() ([]post_models.Post, e
 func (repository *postsRepository) GetPostById(id int) (*post_models.Post, error) {
 
 	rows, err := repository.conn.Query(
-		context.TODO(), `SELECT post_id, title, content, user_id, created_at, updated_at, restricted FROM posts WHERE post_id = $1`, id,
+		context.TODO(), `SELECT `+postColumns+` FROM posts WHERE post_id = $1 AND deleted_at IS NULL`, id,
 	)
 	if err != nil {
 		return nil, err
@@ -81,20 +206,271 @@ func (repository *postsRepository) GetPostById(id int) (*post_models.Post, error
 	return &post, nil
 }
 
-func (repository *postsRepository) DeletePostById(id int) error {
+// SearchPosts ranks the posts viewer may read against a web-style search query, highlighting matches with <mark>.
+// Translations are searched alongside the posts, each stemmed in its own language, and a post matching in
+// several languages comes back once, in the first of locales it matched in.
+func (repository *postsRepository) SearchPosts(query string, locales []string, viewer post_models.Viewer, limit int) ([]post_models.SearchResult, error) {
 	rows, err := repository.conn.Query(
-		context.TODO(), `DELETE FROM posts WHERE post_id = $1`, id,
+		context.TODO(), `WITH matches AS (
+			SELECT DISTINCT ON (d.post_id) d.post_id AS match_post_id, d.title AS match_title, d.content AS match_content,
+				d.slug AS match_slug, d.locale AS match_locale,
+				ts_rank_cd(d.search_vector, query) AS rank,
+				ts_headline(locale_search_config(d.locale), d.title, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS title_highlight,
+				ts_headline(locale_search_config(d.locale), d.content, query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10') AS snippet
+			FROM (
+				SELECT post_id, locale, title, content, slug, search_vector FROM posts
+				UNION ALL
+				SELECT post_id, locale, title, content, slug, search_vector FROM post_translations
+			) d, websearch_to_tsquery(locale_search_config(d.locale), $1) query
+			WHERE d.search_vector @@ query
+			ORDER BY d.post_id, coalesce(array_position($2::text[], d.locale), 2147483647), rank DESC
+		)
+		SELECT `+selectPost("match_title", "match_content", "match_slug", "match_locale")+`, rank, title_highlight, snippet
+		FROM matches JOIN posts ON posts.post_id = match_post_id
+		WHERE status = 'published' AND deleted_at IS NULL AND ($3 OR `+visibleTo("posts", "$4::int", "$5::boolean")+`)
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $6`, query, locales, viewer.Admin, viewer.UserId, viewer.Privileged, limit,
 	)
 	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.SearchResult])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error searching posts for %q: %v", query, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// CanView reports whether viewer may read the post. Trashed and missing posts can't be read by anyone.
+func (repository *postsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	var visible bool
+	err := repository.conn.QueryRow(
+		context.TODO(), `SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = $1 AND deleted_at IS NULL
+			AND ($2 OR `+visibleTo("posts", "$3::int", "$4::boolean")+`))`,
+		postId, viewer.Admin, viewer.UserId, viewer.Privileged,
+	).Scan(&visible)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error checking access to post %d for user %d: %v", postId, viewer.UserId, err)
+		return false, err
+	}
+	return visible, nil
+}
+
+// GetPostGrants lists the users and groups a post is shared with
+func (repository *postsRepository) GetPostGrants(postId int) (*post_models.PostGrants, error) {
+	grants := post_models.PostGrants{Groups: []int{}, Users: []int{}}
+	err := repository.conn.QueryRow(
+		context.TODO(), `SELECT
+			coalesce((SELECT array_agg(group_id ORDER BY group_id) FROM post_grants WHERE post_id = $1 AND group_id IS NOT NULL), '{}'),
+			coalesce((SELECT array_agg(user_id ORDER BY user_id) FROM post_grants WHERE post_id = $1 AND user_id IS NOT NULL), '{}')
+		FROM posts WHERE post_id = $1 AND deleted_at IS NULL`, postId,
+	).Scan(&grants.Groups, &grants.Users)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting grants for post %d: %v", postId, err)
+		return nil, err
+	}
+	return &grants, nil
+}
+
+// SetPostGrants replaces who a post is shared with. Unknown users or groups come back as pgx.ErrNoRows.
+func (repository *postsRepository) SetPostGrants(postId int, grants post_models.PostGrants) error {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `SELECT true FROM posts WHERE post_id = $1 AND deleted_at IS NULL FOR UPDATE`, postId).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	err = setPostGrants(ctx, tx, postId, grants)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return pgx.ErrNoRows
+	}
+	if err != nil {
+		repository.logger.Sugar().Errorf("error setting grants for post %d: %v", postId, err)
+		return err
+	}
+	// Who can read the post changed, so its ETag and any cached copy must too
+	err = bumpVersion(ctx, tx, postId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error bumping the version of post %d: %v", postId, err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeletePostById moves a post and its media to the trash. Trashed posts are invisible everywhere but the trash
+// listing until they are restored or purged.
+func (repository *postsRepository) DeletePostById(id int) error {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `UPDATE posts SET deleted_at = now() WHERE post_id = $1 AND deleted_at IS NULL RETURNING deleted_at`, id).Scan(&deletedAt)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			repository.logger.Sugar().Errorf("error moving post %d to the trash: %v", id, err)
+		}
+		return err
+	}
+	// Media shares the post's timestamp so a restore brings back exactly what was trashed with it
+	_, err = tx.Exec(ctx, `UPDATE media SET deleted_at = $2 WHERE post_id = $1 AND deleted_at IS NULL`, id, deletedAt)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error moving media of post %d to the trash: %v", id, err)
 		return err
 	}
+	return tx.Commit(ctx)
+}
+
+// GetTrashedPosts lists the posts in the trash, most recently deleted first
+func (repository *postsRepository) GetTrashedPosts() ([]post_models.Post, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT `+postColumns+` FROM posts WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, post_id DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
-	return nil
+
+	posts, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.Post])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting trashed posts from the database: %v", err)
+		return nil, err
+	}
+	return posts, nil
+}
+
+// RestorePost takes a post out of the trash along with the media that went in with it
+func (repository *postsRepository) RestorePost(postId int) error {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `SELECT deleted_at FROM posts WHERE post_id = $1 AND deleted_at IS NOT NULL FOR UPDATE`, postId).Scan(&deletedAt)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			repository.logger.Sugar().Errorf("error restoring post %d from the trash: %v", postId, err)
+		}
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE posts SET deleted_at = NULL WHERE post_id = $1`, postId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error restoring post %d from the trash: %v", postId, err)
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE media SET deleted_at = NULL WHERE post_id = $1 AND deleted_at = $2`, postId, deletedAt)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error restoring media of post %d from the trash: %v", postId, err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// PurgePost permanently deletes a trashed post and its media rows. It returns the blobs of the post's media and
+// their variants, which the caller deletes from storage once the rows are gone.
+func (repository *postsRepository) PurgePost(postId int) ([]string, error) {
+	purged, blobNames, err := repository.purge(`post_id = $1`, postId)
+	if err != nil {
+		return nil, err
+	}
+	if purged == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return blobNames, nil
+}
+
+// PurgeTrashedBefore permanently deletes every post trashed before cutoff and returns how many went, along with
+// the blobs their media leave behind
+func (repository *postsRepository) PurgeTrashedBefore(cutoff time.Time) (int, []string, error) {
+	return repository.purge(`deleted_at < $1`, cutoff)
+}
+
+// purge hard deletes the trashed posts matching condition, whose only placeholder is $1, and returns the blob
+// names of their media and media variants
+func (repository *postsRepository) purge(condition string, arg any) (int, []string, error) {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	trashed := `SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND ` + condition
+	rows, err := tx.Query(ctx, `SELECT blob_name FROM media WHERE post_id IN (`+trashed+`)
+		UNION ALL
+		SELECT v.blob_name FROM media_variants v JOIN media m ON m.media_id = v.media_id WHERE m.post_id IN (`+trashed+`)`, arg)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting the blobs of trashed posts: %v", err)
+		return 0, nil, err
+	}
+	blobNames, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting the blobs of trashed posts: %v", err)
+		return 0, nil, err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM media WHERE post_id IN (`+trashed+`)`, arg)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error purging media of trashed posts: %v", err)
+		return 0, nil, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM posts WHERE deleted_at IS NOT NULL AND `+condition, arg)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error purging trashed posts: %v", err)
+		return 0, nil, err
+	}
+	return int(tag.RowsAffected()), blobNames, tx.Commit(ctx)
 }
 
 func (repository *postsRepository) CreatePost(post post_models.PostRequestBody, id int) (int, error) {
-	rows, err := repository.conn.Query(
-		context.TODO(), `INSERT INTO posts (title, content, restricted, user_id) VALUES ($1, $2, $3, $4) RETURNING post_id`, post.Title, post.Content, post.Restricted, id,
+	return repository.createPost(post, id, nil)
+}
+
+// ImportPost creates a post that was first published elsewhere, keeping createdAt as its creation and publish time
+func (repository *postsRepository) ImportPost(post post_models.PostRequestBody, userId int, createdAt time.Time) (int, error) {
+	return repository.createPost(post, userId, &createdAt)
+}
+
+// createPost inserts a post dated createdAt, or now when createdAt is nil
+func (repository *postsRepository) createPost(post post_models.PostRequestBody, id int, createdAt *time.Time) (int, error) {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	base := post.Slug
+	if base == "" {
+		base = post.Title
+	}
+	postSlug, err := uniqueSlug(ctx, tx, base, 0, "")
+	if err != nil {
+		repository.logger.Sugar().Errorf("error generating slug for post(%s) : %v", post.Title, err)
+		return 0, err
+	}
+
+	rows, err := tx.Query(
+		ctx, `INSERT INTO posts (title, content, restricted, user_id, slug, status, publish_at, created_at, updated_at, locale)
+		VALUES ($1, $2, $3, $4, $5, $6,
+			CASE WHEN $6 = 'published' THEN coalesce($8::timestamptz, now()) WHEN $6 = 'scheduled' THEN $7::timestamptz END,
+			coalesce($8::timestamptz, now()), coalesce($8::timestamptz, now()), coalesce(nullif($9, ''), 'en'))
+		RETURNING post_id`, post.Title, post.Content, post.Restricted, id, postSlug, post.Status, post.PublishAt, createdAt, post.Locale,
 	)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error creating post(%s) : %v", post.Title, err)
@@ -105,23 +481,477 @@ func (repository *postsRepository) CreatePost(post post_models.PostRequestBody,
 		repository.logger.Sugar().Errorf("error returning postId for post(%s) : %v", post.Title, err)
 		return 0, err
 	}
-	defer rows.Close()
+	err = setPostTags(ctx, tx, newPost[0].PostId, post.Tags)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error tagging post(%s) : %v", post.Title, err)
+		return 0, err
+	}
+	if post.Grants != nil {
+		err = setPostGrants(ctx, tx, newPost[0].PostId, *post.Grants)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error granting access to post(%s) : %v", post.Title, err)
+			return 0, err
+		}
+	}
+	err = recordRevision(ctx, tx, newPost[0].PostId, id)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error recording revision for post(%s) : %v", post.Title, err)
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
 	return newPost[0].PostId, nil
 }
 
-func (repository *postsRepository) UpdatePost(post post_models.PostRequestBody, postId, userId int) (*post_models.PostRequestBody, error) {
-	rows, err := repository.conn.Query(
-		context.TODO(), `UPDATE posts SET title = $1, content = $2, restricted = $3, user_id = $4 WHERE post_id = $5 RETURNING title, content, restricted`, post.Title, post.Content, post.Restricted, userId, postId,
+// UpdatePost overwrites a post. The slug follows post.Slug when given, otherwise it is regenerated when the
+// title changes; a replaced slug is kept as a redirect so existing links still resolve. The update only goes
+// ahead while the post is still at version, otherwise ErrVersionConflict is returned; a version of 0 skips the check.
+func (repository *postsRepository) UpdatePost(post post_models.PostRequestBody, postId, userId, version int) (*post_models.PostRequestBody, error) {
+	return repository.updatePost(post, postId, userId, version, nil)
+}
+
+// ReimportPost overwrites an imported post like UpdatePost, keeping createdAt as its creation and publish time
+func (repository *postsRepository) ReimportPost(post post_models.PostRequestBody, postId, userId, version int, createdAt time.Time) (*post_models.PostRequestBody, error) {
+	return repository.updatePost(post, postId, userId, version, &createdAt)
+}
+
+// updatePost overwrites a post, dating it createdAt when given and otherwise now if this publishes it
+func (repository *postsRepository) updatePost(post post_models.PostRequestBody, postId, userId, version int, createdAt *time.Time) (*post_models.PostRequestBody, error) {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var currentTitle, currentSlug string
+	var currentVersion int
+	err = tx.QueryRow(ctx, `SELECT title, slug, version FROM posts WHERE post_id = $1 AND deleted_at IS NULL FOR UPDATE`, postId).Scan(&currentTitle, &currentSlug, &currentVersion)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error updating post %s - %v", post.Title, err)
+		return nil, err
+	}
+	// The row lock holds until commit, so nobody can slip an update in between this check and ours
+	if version != 0 && version != currentVersion {
+		return nil, ErrVersionConflict
+	}
+
+	newSlug := currentSlug
+	if post.Slug != "" && slug.Make(post.Slug) != currentSlug {
+		newSlug, err = uniqueSlug(ctx, tx, post.Slug, postId, "")
+	} else if post.Slug == "" && post.Title != currentTitle {
+		newSlug, err = uniqueSlug(ctx, tx, post.Title, postId, "")
+	}
+	if err != nil {
+		repository.logger.Sugar().Errorf("error generating slug for post %s - %v", post.Title, err)
+		return nil, err
+	}
+	if newSlug != currentSlug {
+		_, err = tx.Exec(ctx, `DELETE FROM post_slug_redirects WHERE slug = $1`, newSlug)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO post_slug_redirects (slug, post_id) VALUES ($1, $2) ON CONFLICT (slug) DO UPDATE SET post_id = EXCLUDED.post_id`, currentSlug, postId)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error recording slug redirect for post %d - %v", postId, err)
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(
+		ctx, `UPDATE posts SET title = $1, content = $2, restricted = $3, user_id = $4, slug = $5, status = $7,
+			publish_at = CASE
+				WHEN $7 = 'published' AND $10::timestamptz IS NOT NULL THEN $10::timestamptz
+				WHEN $7 = 'published' AND status = 'published' THEN publish_at
+				WHEN $7 = 'published' THEN now()
+				WHEN $7 = 'scheduled' THEN $8::timestamptz
+			END,
+			created_at = CASE
+				WHEN $10::timestamptz IS NOT NULL THEN $10::timestamptz
+				WHEN $7 = 'published' AND status <> 'published' THEN now()
+				ELSE created_at
+			END,
+			updated_at = now(),
+			version = version + 1,
+			locale = coalesce(nullif($9, ''), locale)
+		WHERE post_id = $6 RETURNING title, content, restricted, slug, status, publish_at, version, locale`, post.Title, post.Content, post.Restricted, userId, newSlug, postId, post.Status, post.PublishAt, post.Locale, createdAt,
 	)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error updating post %s - %v", post.Title, err)
 		return nil, err
 	}
-	defer rows.Close()
 	updatedPost, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.PostRequestBody])
 	if err != nil {
 		repository.logger.Sugar().Errorf("error updating post %s - %v", post.Title, err)
 		return nil, err
 	}
+	if post.Tags != nil {
+		err = setPostTags(ctx, tx, postId, post.Tags)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error tagging post %d - %v", postId, err)
+			return nil, err
+		}
+	}
+	if post.Grants != nil {
+		err = setPostGrants(ctx, tx, postId, *post.Grants)
+		if err != nil {
+			repository.logger.Sugar().Errorf("error granting access to post %d - %v", postId, err)
+			return nil, err
+		}
+	}
+	err = tx.QueryRow(ctx, `SELECT coalesce(array_agg(t.name ORDER BY t.name), '{}') FROM post_tags pt JOIN tags t ON t.tag_id = pt.tag_id WHERE pt.post_id = $1`, postId).Scan(&updatedPost[0].Tags)
+	if err != nil {
+		return nil, err
+	}
+	err = recordRevision(ctx, tx, postId, userId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error recording revision for post %d - %v", postId, err)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	return &updatedPost[0], nil
 }
+
+// PublishDuePosts flips every scheduled post whose publish_at has passed to published and returns them.
+// created_at moves to the publish time so the post sorts and displays as new.
+func (repository *postsRepository) PublishDuePosts(now time.Time) ([]post_models.Post, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `UPDATE posts SET status = 'published', created_at = publish_at, updated_at = now(), version = version + 1
+		WHERE status = 'scheduled' AND publish_at <= $1 AND deleted_at IS NULL
+		RETURNING `+postColumns, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.Post])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error publishing scheduled posts: %v", err)
+		return nil, err
+	}
+	return posts, nil
+}
+
+// GetTranslations lists the translations of a post by locale
+func (repository *postsRepository) GetTranslations(postId int) ([]post_models.PostTranslation, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT post_id, locale, title, content, slug, created_at, updated_at
+		FROM post_translations WHERE post_id = $1 ORDER BY locale`, postId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.PostTranslation])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting translations for post %d: %v", postId, err)
+		return nil, err
+	}
+	return translations, nil
+}
+
+func (repository *postsRepository) GetTranslation(postId int, locale string) (*post_models.PostTranslation, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT post_id, locale, title, content, slug, created_at, updated_at
+		FROM post_translations WHERE post_id = $1 AND locale = $2`, postId, locale,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translation, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[post_models.PostTranslation])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting the %s translation of post %d: %v", locale, postId, err)
+		return nil, err
+	}
+	return &translation, nil
+}
+
+// SetTranslation creates or replaces the translation of a post into translation.Locale. The slug follows
+// translation.Slug when given and is otherwise made from the title once, when the translation is created. The
+// post's version moves with every change to its translations so cached copies and pending edits notice.
+func (repository *postsRepository) SetTranslation(translation post_models.PostTranslation) (*post_models.PostTranslation, error) {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = bumpVersion(ctx, tx, translation.PostId)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			repository.logger.Sugar().Errorf("error translating post %d - %v", translation.PostId, err)
+		}
+		return nil, err
+	}
+
+	var currentSlug string
+	err = tx.QueryRow(ctx, `SELECT slug FROM post_translations WHERE post_id = $1 AND locale = $2`, translation.PostId, translation.Locale).Scan(&currentSlug)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	newSlug := currentSlug
+	if translation.Slug != "" && slug.Make(translation.Slug) != currentSlug {
+		newSlug, err = uniqueSlug(ctx, tx, translation.Slug, translation.PostId, translation.Locale)
+	} else if currentSlug == "" {
+		newSlug, err = uniqueSlug(ctx, tx, translation.Title, translation.PostId, translation.Locale)
+	}
+	if err != nil {
+		repository.logger.Sugar().Errorf("error generating slug for the %s translation of post %d - %v", translation.Locale, translation.PostId, err)
+		return nil, err
+	}
+
+	rows, err := tx.Query(
+		ctx, `INSERT INTO post_translations (post_id, locale, title, content, slug) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (post_id, locale) DO UPDATE SET title = EXCLUDED.title, content = EXCLUDED.content, slug = EXCLUDED.slug, updated_at = now()
+		RETURNING post_id, locale, title, content, slug, created_at, updated_at`,
+		translation.PostId, translation.Locale, translation.Title, translation.Content, newSlug,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error saving the %s translation of post %d - %v", translation.Locale, translation.PostId, err)
+		return nil, err
+	}
+	saved, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[post_models.PostTranslation])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error saving the %s translation of post %d - %v", translation.Locale, translation.PostId, err)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteTranslation removes the translation of a post into locale; a missing post or translation is pgx.ErrNoRows
+func (repository *postsRepository) DeleteTranslation(postId int, locale string) error {
+	ctx := context.TODO()
+	tx, err := repository.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM post_translations WHERE post_id = $1 AND locale = $2`, postId, locale)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error deleting the %s translation of post %d: %v", locale, postId, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	err = bumpVersion(ctx, tx, postId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetRevisions lists every saved version of a post, oldest first
+func (repository *postsRepository) GetRevisions(postId int) ([]post_models.PostRevision, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT revision_id, post_id, title, content, restricted, slug, status, user_id, created_at
+		FROM post_revisions WHERE post_id = $1 ORDER BY revision_id`, postId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.PostRevision])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting revisions for post %d: %v", postId, err)
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (repository *postsRepository) GetRevision(postId, revisionId int) (*post_models.PostRevision, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT revision_id, post_id, title, content, restricted, slug, status, user_id, created_at
+		FROM post_revisions WHERE post_id = $1 AND revision_id = $2`, postId, revisionId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revision, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[post_models.PostRevision])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting revision %d for post %d: %v", revisionId, postId, err)
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetTags lists tags with the number of posts the caller may see carrying each one
+func (repository *postsRepository) GetTags(viewer post_models.Viewer, includeUnpublished bool) ([]post_models.Tag, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT t.name, count(*)::int AS post_count
+		FROM tags t
+		JOIN post_tags pt ON pt.tag_id = t.tag_id
+		JOIN posts p ON p.post_id = pt.post_id
+		WHERE p.deleted_at IS NULL AND ($1 OR `+visibleTo("p", "$2::int", "$3::boolean")+`) AND ($4 OR p.status = 'published')
+		GROUP BY t.name
+		ORDER BY post_count DESC, t.name`, viewer.Admin, viewer.UserId, viewer.Privileged, includeUnpublished,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.Tag])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting tags from the database: %v", err)
+		return nil, err
+	}
+	return tags, nil
+}
+
+// CountPublicPosts counts the published, unrestricted posts anyone can read
+func (repository *postsRepository) CountPublicPosts() (int, error) {
+	var count int
+	err := repository.conn.QueryRow(
+		context.TODO(), `SELECT count(*) FROM posts WHERE `+publicOnly+` AND status = 'published' AND deleted_at IS NULL`,
+	).Scan(&count)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error counting public posts: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetPublicPostSummaries pages through published, unrestricted posts oldest first so page boundaries stay stable
+func (repository *postsRepository) GetPublicPostSummaries(offset, limit int) ([]post_models.PostSummary, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT post_id, slug, created_at, updated_at FROM posts
+		WHERE `+publicOnly+` AND status = 'published' AND deleted_at IS NULL
+		ORDER BY created_at, post_id
+		OFFSET $1 LIMIT $2`, offset, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries, err := pgx.CollectRows(rows, pgx.RowToStructByName[post_models.PostSummary])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting public post summaries: %v", err)
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// setPostTags replaces the tags on postId, creating any tag names that don't exist yet
+func setPostTags(ctx context.Context, tx pgx.Tx, postId int, tags []string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, tags)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM post_tags WHERE post_id = $1`, postId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO post_tags (post_id, tag_id) SELECT $1, tag_id FROM tags WHERE name = ANY($2::text[])`, postId, tags)
+	return err
+}
+
+// setPostGrants replaces the users and groups postId is shared with. The post is marked shared while it has any,
+// and only an empty list clears the mark; grants that cascade away with their user or group leave it set.
+func setPostGrants(ctx context.Context, tx pgx.Tx, postId int, grants post_models.PostGrants) error {
+	_, err := tx.Exec(ctx, `DELETE FROM post_grants WHERE post_id = $1`, postId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE posts SET shared = $2 WHERE post_id = $1`, postId, len(grants.Groups)+len(grants.Users) > 0)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO post_grants (post_id, group_id) SELECT DISTINCT $1::int, unnest($2::int[])`, postId, grants.Groups)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO post_grants (post_id, user_id) SELECT DISTINCT $1::int, unnest($2::int[])`, postId, grants.Users)
+	return err
+}
+
+// bumpVersion marks a post as changed without touching its own columns, for edits to what hangs off it
+func bumpVersion(ctx context.Context, tx pgx.Tx, postId int) error {
+	var version int
+	return tx.QueryRow(ctx, `UPDATE posts SET version = version + 1, updated_at = now() WHERE post_id = $1 AND deleted_at IS NULL RETURNING version`, postId).Scan(&version)
+}
+
+// recordRevision snapshots the current row of postId, attributing it to userId
+func recordRevision(ctx context.Context, tx pgx.Tx, postId, userId int) error {
+	_, err := tx.Exec(ctx, `INSERT INTO post_revisions (post_id, title, content, restricted, slug, status, user_id)
+		SELECT post_id, title, content, restricted, slug, status, $2 FROM posts WHERE post_id = $1`, postId, userId)
+	return err
+}
+
+// slugMatch is a post found by GetPostBySlug; current and translated slugs have priority 0 and take precedence
+// over a former slug's redirect at priority 1
+type slugMatch struct {
+	post_models.Post
+	Priority int `db:"priority"`
+}
+
+// GetPostBySlug resolves a current slug or a redirected former slug to its post. The slug of a translation
+// resolves to the post in that translation's language.
+func (repository *postsRepository) GetPostBySlug(postSlug string) (*post_models.Post, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT `+postColumns+`, 0 AS priority FROM posts WHERE slug = $1 AND deleted_at IS NULL
+		UNION ALL
+		SELECT `+translatedPostColumns+`, 0 AS priority FROM posts `+translated("ARRAY(SELECT locale FROM post_translations WHERE slug = $1)")+`
+		WHERE post_id = (SELECT post_id FROM post_translations WHERE slug = $1) AND deleted_at IS NULL
+		UNION ALL
+		SELECT `+postColumns+`, 1 AS priority FROM posts WHERE post_id = (SELECT post_id FROM post_slug_redirects WHERE slug = $1) AND deleted_at IS NULL
+		ORDER BY priority LIMIT 1`, postSlug,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	match, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[slugMatch])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting post by slug %s: %v ", postSlug, err)
+		return nil, err
+	}
+	return &match.Post, nil
+}
+
+// uniqueSlug slugifies text and appends the lowest free numeric suffix. Slugs are shared by posts, their former
+// slugs and translations; the ones owned by excludePostId are ignored, or only its translation into
+// excludeLocale when that is set.
+func uniqueSlug(ctx context.Context, tx pgx.Tx, text string, excludePostId int, excludeLocale string) (string, error) {
+	base := slug.Make(text)
+	if base == "" {
+		base = "post"
+	}
+	rows, err := tx.Query(ctx, `SELECT slug FROM posts WHERE (slug = $1 OR slug LIKE $1 || '-%') AND (post_id <> $2 OR $3 <> '')
+		UNION
+		SELECT slug FROM post_slug_redirects WHERE (slug = $1 OR slug LIKE $1 || '-%') AND (post_id <> $2 OR $3 <> '')
+		UNION
+		SELECT slug FROM post_translations WHERE (slug = $1 OR slug LIKE $1 || '-%') AND (post_id <> $2 OR locale <> $3)`, base, excludePostId, excludeLocale)
+	if err != nil {
+		return "", err
+	}
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, t := range taken {
+		used[t] = true
+	}
+	candidate := base
+	for n := 2; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s-%d", base, n)
+	}
+	return candidate, nil
+}
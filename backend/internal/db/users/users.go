@@ -3,6 +3,7 @@ package users
 import (
 	"context"
 	"errors"
+	"time"
 
 	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
 	"github.com/KylerJacobson/blog/backend/logger"
@@ -20,6 +21,8 @@ type UsersRepository interface {
 	DeleteUserById(id int) error
 	LoginUser(user user_models.UserLogin) (*user_models.User, error)
 	GetAllUsersWithEmailNotification() ([]user_models.User, error)
+	GetDigestSubscribers() ([]user_models.DigestSubscriber, error)
+	SetLastDigestAt(userId int, at time.Time) error
 }
 
 type usersRepository struct {
@@ -34,8 +37,9 @@ func New(conn *pgxpool.Pool, logger logger.Logger) *usersRepository {
 	}
 }
 
+// GetAllUsersWithEmailNotification returns the members who want an email for every new post
 func (repository *usersRepository) GetAllUsersWithEmailNotification() ([]user_models.User, error) {
-	rows, err := repository.conn.Query(context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification FROM users WHERE email_notification = true`)
+	rows, err := repository.conn.Query(context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification, notification_frequency, locale FROM users WHERE notification_frequency = 'immediate'`)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error retrieving users from the database: %v", err)
 		return nil, err
@@ -48,9 +52,36 @@ func (repository *usersRepository) GetAllUsersWithEmailNotification() ([]user_mo
 	return users, nil
 }
 
+// GetDigestSubscribers returns the members who get daily or weekly digests, with when they got the last one
+func (repository *usersRepository) GetDigestSubscribers() ([]user_models.DigestSubscriber, error) {
+	rows, err := repository.conn.Query(
+		context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification, notification_frequency, locale, last_digest_at
+		FROM users WHERE notification_frequency IN ('daily', 'weekly') ORDER BY id`,
+	)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error retrieving digest subscribers from the database: %v", err)
+		return nil, err
+	}
+	subscribers, err := pgx.CollectRows(rows, pgx.RowToStructByName[user_models.DigestSubscriber])
+	if err != nil {
+		repository.logger.Sugar().Errorf("error getting digest subscribers: %v", err)
+		return nil, err
+	}
+	return subscribers, nil
+}
+
+func (repository *usersRepository) SetLastDigestAt(userId int, at time.Time) error {
+	_, err := repository.conn.Exec(context.TODO(), `UPDATE users SET last_digest_at = $1 WHERE id = $2`, at, userId)
+	if err != nil {
+		repository.logger.Sugar().Errorf("error recording the digest sent to user %d: %v", userId, err)
+		return err
+	}
+	return nil
+}
+
 func (repository *usersRepository) GetUserById(id int) (*user_models.User, error) {
 	rows, err := repository.conn.Query(
-		context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification FROM users WHERE id = $1;`, id,
+		context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification, notification_frequency, locale FROM users WHERE id = $1;`, id,
 	)
 	if err != nil {
 		return nil, err
@@ -83,7 +114,7 @@ func (repository *usersRepository) DeleteUserById(id int) error {
 
 func (repository *usersRepository) CreateUser(user user_models.UserCreate) (string, error) {
 
-	rows, err := repository.conn.Query(context.TODO(), `INSERT INTO users (first_name, last_name, email, password, role, email_notification) VALUES ($1, $2, $3, crypt($4, gen_salt('bf', 8)), $5, $6) RETURNING id `, user.FirstName, user.LastName, user.Email, user.Password, user.AccessRequest, user.EmailNotification)
+	rows, err := repository.conn.Query(context.TODO(), `INSERT INTO users (first_name, last_name, email, password, role, email_notification, notification_frequency) VALUES ($1, $2, $3, crypt($4, gen_salt('bf', 8)), $5, $6, CASE WHEN $6 THEN 'immediate' ELSE 'off' END) RETURNING id `, user.FirstName, user.LastName, user.Email, user.Password, user.AccessRequest, user.EmailNotification)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error creating user for %s %s : %v", user.FirstName, user.FirstName, err)
 		return "", err
@@ -107,8 +138,18 @@ func (repository *usersRepository) CreateUser(user user_models.UserCreate) (stri
 	return createdUser[0].Id, nil
 }
 
+// UpdateUser saves a member's details, keeping their locale when none is given. Without a notification
+// frequency, EmailNotification turns notifications off, or back on as an email per post. Switching frequency
+// restarts the digest period so the first digest doesn't repeat posts already emailed.
 func (repository *usersRepository) UpdateUser(user user_models.UserUpdate) error {
-	rows, err := repository.conn.Query(context.TODO(), `UPDATE users SET first_name = $1, last_name = $2, email = $3, role = $4, email_notification = $5 WHERE id = $6 `, user.FirstName, user.LastName, user.Email, user.Role, user.EmailNotification, user.Id)
+	rows, err := repository.conn.Query(context.TODO(), `UPDATE users u SET first_name = $1, last_name = $2, email = $3, role = $4,
+		locale = coalesce(nullif($8, ''), u.locale),
+		notification_frequency = f.frequency,
+		email_notification = f.frequency <> 'off',
+		last_digest_at = CASE WHEN f.frequency <> u.notification_frequency THEN now() ELSE u.last_digest_at END
+		FROM (SELECT coalesce(nullif($7, ''), CASE WHEN NOT $5 THEN 'off' WHEN notification_frequency = 'off' THEN 'immediate' ELSE notification_frequency END) AS frequency
+			FROM users WHERE id = $6) f
+		WHERE u.id = $6 `, user.FirstName, user.LastName, user.Email, user.Role, user.EmailNotification, user.Id, user.NotificationFrequency, user.Locale)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error updating user %s %s : %v", user.FirstName, user.FirstName, err)
 		return err
@@ -118,7 +159,7 @@ func (repository *usersRepository) UpdateUser(user user_models.UserUpdate) error
 }
 
 func (repository *usersRepository) GetUserByEmail(email string) (*user_models.User, error) {
-	rows, err := repository.conn.Query(context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification FROM users WHERE email = $1`, email)
+	rows, err := repository.conn.Query(context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification, notification_frequency, locale FROM users WHERE email = $1`, email)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error retrieving user (%s) from the database: %v", email, err)
 		return nil, err
@@ -160,7 +201,7 @@ func (repository *usersRepository) LoginUser(user user_models.UserLogin) (*user_
 }
 
 func (repository *usersRepository) GetAllUsers() (*[]user_models.FrontendUser, error) {
-	rows, err := repository.conn.Query(context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification, created_at FROM users ORDER BY created_at ASC`)
+	rows, err := repository.conn.Query(context.TODO(), `SELECT id, first_name, last_name, email, role, email_notification, notification_frequency, locale, created_at FROM users ORDER BY created_at ASC`)
 	if err != nil {
 		repository.logger.Sugar().Errorf("error retrieving users from the database: %v", err)
 		return nil, err
@@ -0,0 +1,156 @@
+package diff
+
+import "strings"
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Line is one line of a line-level diff
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines computes a shortest line-level diff turning a into b with Myers' algorithm in its linear space form, so
+// comparing long posts costs memory in proportion to their length rather than to the product of their lengths.
+// Deletions are reported before insertions wherever a block of lines was replaced.
+func Lines(a, b string) []Line {
+	d := differ{from: splitLines(a), to: splitLines(b)}
+	d.lines = make([]Line, 0, max(len(d.from), len(d.to)))
+	d.compare(0, len(d.from), 0, len(d.to))
+	return deletionsFirst(d.lines)
+}
+
+type differ struct {
+	from, to []string
+	lines    []Line
+}
+
+// compare appends the diff of from[aLo:aHi] against to[bLo:bHi]
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	var suffix int
+	for aLo < aHi && bLo < bHi && d.from[aLo] == d.to[bLo] {
+		d.lines = append(d.lines, Line{Op: OpEqual, Text: d.from[aLo]})
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.from[aHi-1] == d.to[bHi-1] {
+		aHi--
+		bHi--
+		suffix++
+	}
+
+	switch {
+	case aLo == aHi:
+		for _, text := range d.to[bLo:bHi] {
+			d.lines = append(d.lines, Line{Op: OpInsert, Text: text})
+		}
+	case bLo == bHi:
+		for _, text := range d.from[aLo:aHi] {
+			d.lines = append(d.lines, Line{Op: OpDelete, Text: text})
+		}
+	default:
+		// Both ends differ, so the edit script has at least two edits and the middle snake splits it into
+		// strictly smaller halves
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for _, text := range d.from[x:u] {
+			d.lines = append(d.lines, Line{Op: OpEqual, Text: text})
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for _, text := range d.from[aHi : aHi+suffix] {
+		d.lines = append(d.lines, Line{Op: OpEqual, Text: text})
+	}
+}
+
+// middleSnake runs the search from both corners of the edit graph of from[aLo:aHi] and to[bLo:bHi] until the
+// paths meet, and returns the run of matching lines from[x:u] == to[y:v] in the middle of a shortest edit script
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	// forward[k] and backward[k] are the furthest x reached on diagonal k, counted from the start and the end
+	offset := limit + 1
+	forward := make([]int, 2*limit+3)
+	backward := make([]int, 2*limit+3)
+
+	for depth := 0; depth <= limit; depth++ {
+		for k := -depth; k <= depth; k += 2 {
+			var x int
+			if k == -depth || (k != depth && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.from[aLo+x] == d.to[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			// The backward search last covered diagonals -(depth-1) through depth-1
+			if reverse := delta - k; odd && reverse >= -(depth-1) && reverse <= depth-1 && x+backward[offset+reverse] >= n {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y
+			}
+		}
+		for k := -depth; k <= depth; k += 2 {
+			var x int
+			if k == -depth || (k != depth && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.from[aHi-1-x] == d.to[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if reverse := delta - k; !odd && reverse >= -depth && reverse <= depth && x+forward[offset+reverse] >= n {
+				return aLo + n - x, bLo + m - y, aLo + n - startX, bLo + m - startY
+			}
+		}
+	}
+	// A shortest path is at most n+m edits long, so the searches always meet
+	panic("diff: no middle snake")
+}
+
+// deletionsFirst moves the deletions of every run of changed lines ahead of its insertions
+func deletionsFirst(lines []Line) []Line {
+	sorted := make([]Line, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Op == OpEqual {
+			sorted = append(sorted, lines[i])
+			i++
+			continue
+		}
+		end := i
+		for end < len(lines) && lines[end].Op != OpEqual {
+			end++
+		}
+		for _, op := range []string{OpDelete, OpInsert} {
+			for _, line := range lines[i:end] {
+				if line.Op == op {
+					sorted = append(sorted, line)
+				}
+			}
+		}
+		i = end
+	}
+	return sorted
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
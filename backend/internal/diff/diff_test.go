@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected []Line
+	}{
+		{
+			name:     "identical",
+			a:        "one\ntwo",
+			b:        "one\ntwo",
+			expected: []Line{{OpEqual, "one"}, {OpEqual, "two"}},
+		},
+		{
+			name:     "replaced_line",
+			a:        "one\ntwo\nthree",
+			b:        "one\n2\nthree",
+			expected: []Line{{OpEqual, "one"}, {OpDelete, "two"}, {OpInsert, "2"}, {OpEqual, "three"}},
+		},
+		{
+			name:     "appended_lines",
+			a:        "one",
+			b:        "one\ntwo\nthree",
+			expected: []Line{{OpEqual, "one"}, {OpInsert, "two"}, {OpInsert, "three"}},
+		},
+		{
+			name:     "from_empty",
+			a:        "",
+			b:        "hello",
+			expected: []Line{{OpInsert, "hello"}},
+		},
+		{
+			name:     "replaced_block",
+			a:        "one\ntwo\nthree\nfour",
+			b:        "one\n2\n3\nfour",
+			expected: []Line{{OpEqual, "one"}, {OpDelete, "two"}, {OpDelete, "three"}, {OpInsert, "2"}, {OpInsert, "3"}, {OpEqual, "four"}},
+		},
+		{
+			name:     "windows_line_endings",
+			a:        "one\r\ntwo",
+			b:        "one\ntwo",
+			expected: []Line{{OpEqual, "one"}, {OpEqual, "two"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Lines(tt.a, tt.b))
+		})
+	}
+}
+
+// lcsLength is the quadratic textbook answer the diff has to match
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return lcs[0][0]
+}
+
+func TestLinesShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	text := func() []string {
+		lines := make([]string, random.Intn(30))
+		for i := range lines {
+			lines[i] = strconv.Itoa(random.Intn(5))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+		lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		var from, to []string
+		equal := 0
+		for _, line := range lines {
+			if line.Op != OpInsert {
+				from = append(from, line.Text)
+			}
+			if line.Op != OpDelete {
+				to = append(to, line.Text)
+			}
+			if line.Op == OpEqual {
+				equal++
+			}
+		}
+		// An empty line joins to the same text as no lines at all
+		if strings.Join(a, "\n") == "" {
+			a = nil
+		}
+		if strings.Join(b, "\n") == "" {
+			b = nil
+		}
+		assert.Equal(t, a, from)
+		assert.Equal(t, b, to)
+		assert.Equal(t, lcsLength(a, b), equal, "%q -> %q", a, b)
+	}
+}
+
+func TestLinesLongInput(t *testing.T) {
+	// A quadratic table for these would take gigabytes
+	a := make([]string, 50000)
+	b := make([]string, 50000)
+	for i := range a {
+		a[i] = "line " + strconv.Itoa(i)
+		b[i] = a[i]
+		if i%1000 == 0 {
+			b[i] = "changed " + strconv.Itoa(i)
+		}
+	}
+	lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	assert.Len(t, lines, 50000+50)
+}
@@ -0,0 +1,150 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	ap_models "github.com/KylerJacobson/blog/backend/internal/api/types/activitypub"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/services/activitypub"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+type ActivityPubApi interface {
+	WebFinger(w http.ResponseWriter, r *http.Request)
+	GetActor(w http.ResponseWriter, r *http.Request)
+	GetOutbox(w http.ResponseWriter, r *http.Request)
+	GetFollowers(w http.ResponseWriter, r *http.Request)
+	GetObject(w http.ResponseWriter, r *http.Request)
+	PostInbox(w http.ResponseWriter, r *http.Request)
+}
+
+type activityPubApi struct {
+	federator *activitypub.Federator
+	logger    logger.Logger
+}
+
+func New(federator *activitypub.Federator, logger logger.Logger) *activityPubApi {
+	return &activityPubApi{
+		federator: federator,
+		logger:    logger,
+	}
+}
+
+// WebFinger answers /.well-known/webfinger so @username@host can be looked up from Mastodon
+func (a *activityPubApi) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		httperr.Write(w, httperr.BadRequest("resource is required", ""))
+		return
+	}
+	finger, ok := a.federator.WebFinger(resource)
+	if !ok {
+		httperr.Write(w, httperr.NotFound("resource not found", ""))
+		return
+	}
+	a.write(w, "application/jrd+json", finger)
+}
+
+func (a *activityPubApi) GetActor(w http.ResponseWriter, r *http.Request) {
+	a.write(w, ap_models.ContentType, a.federator.Actor())
+}
+
+// GetOutbox serves the outbox collection, or one of its pages with ?page=true or ?cursor=
+func (a *activityPubApi) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("page") == "" && query.Get("cursor") == "" {
+		outbox, err := a.federator.Outbox()
+		if err != nil {
+			a.logger.Sugar().Errorf("error getting the outbox : %v", err)
+			httperr.Write(w, httperr.Internal("error getting the outbox", ""))
+			return
+		}
+		a.write(w, ap_models.ContentType, outbox)
+		return
+	}
+	page, err := a.federator.OutboxPage(query.Get("cursor"))
+	if err != nil {
+		if errors.Is(err, activitypub.ErrInvalidCursor) {
+			httperr.Write(w, httperr.BadRequest(err.Error(), ""))
+			return
+		}
+		a.logger.Sugar().Errorf("error getting an outbox page : %v", err)
+		httperr.Write(w, httperr.Internal("error getting the outbox", ""))
+		return
+	}
+	a.write(w, ap_models.ContentType, page)
+}
+
+func (a *activityPubApi) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	followers, err := a.federator.Followers()
+	if err != nil {
+		a.logger.Sugar().Errorf("error getting the followers collection : %v", err)
+		httperr.Write(w, httperr.Internal("error getting followers", ""))
+		return
+	}
+	a.write(w, ap_models.ContentType, followers)
+}
+
+// GetObject serves the Article a federated post's id points at; posts that aren't public don't exist here
+func (a *activityPubApi) GetObject(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	article, err := a.federator.Object(postId)
+	if err != nil {
+		if errors.Is(err, activitypub.ErrNotFederated) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		a.logger.Sugar().Errorf("error getting post %d for federation : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting post", ""))
+		return
+	}
+	a.write(w, ap_models.ContentType, article)
+}
+
+// PostInbox takes signed deliveries from remote servers
+func (a *activityPubApi) PostInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, activitypub.MaxDocumentSize+1))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("error reading the request body", ""))
+		return
+	}
+	if len(body) > activitypub.MaxDocumentSize {
+		httperr.Write(w, httperr.New(http.StatusRequestEntityTooLarge, "activity is too large", ""))
+		return
+	}
+	err = a.federator.HandleActivity(r, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, activitypub.ErrBadSignature):
+			a.logger.Sugar().Warnf("rejected an inbox delivery : %v", err)
+			httperr.Write(w, httperr.Unauthorized("invalid signature", err.Error()))
+		case errors.Is(err, activitypub.ErrInvalidActivity):
+			httperr.Write(w, httperr.BadRequest("invalid activity", err.Error()))
+		default:
+			a.logger.Sugar().Errorf("error handling an inbox delivery : %v", err)
+			httperr.Write(w, httperr.Internal("error handling activity", ""))
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *activityPubApi) write(w http.ResponseWriter, contentType string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		a.logger.Sugar().Errorf("error marshalling %T : %v", v, err)
+		httperr.Write(w, httperr.Internal("error encoding response", ""))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
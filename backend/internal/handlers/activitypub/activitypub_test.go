@@ -0,0 +1,121 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	ap_repo "github.com/KylerJacobson/blog/backend/internal/db/activitypub"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/services/activitypub"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+}
+
+func (s *stubPostsRepository) GetPostById(postId int) (*post_models.Post, error) {
+	if postId != 1 {
+		return nil, v5.ErrNoRows
+	}
+	return &post_models.Post{PostId: 1, Title: "Family only", Restricted: true, Status: post_models.StatusPublished}, nil
+}
+
+func (s *stubPostsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	return false, nil
+}
+
+type stubActivityPubRepository struct {
+	ap_repo.ActivityPubRepository
+	key string
+}
+
+func (s *stubActivityPubRepository) GetActorKey() (string, error) {
+	if s.key == "" {
+		return "", v5.ErrNoRows
+	}
+	return s.key, nil
+}
+
+func (s *stubActivityPubRepository) CreateActorKey(privateKeyPem string) error {
+	s.key = privateKeyPem
+	return nil
+}
+
+func TestActivityPubApi(t *testing.T) {
+	federator, err := activitypub.New(&stubPostsRepository{}, &stubActivityPubRepository{}, http.DefaultClient, "https://blog.example",
+		activitypub.Profile{Username: "blog"}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	activityPubApi := New(federator, zap.NewNop())
+
+	tests := []struct {
+		name                string
+		method              string
+		target              string
+		pathId              string
+		handler             func(w http.ResponseWriter, r *http.Request)
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{
+			name:                "webfinger",
+			method:              http.MethodGet,
+			target:              "/.well-known/webfinger?resource=acct:blog@blog.example",
+			handler:             activityPubApi.WebFinger,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/jrd+json",
+		},
+		{
+			name:           "webfinger_unknown_account",
+			method:         http.MethodGet,
+			target:         "/.well-known/webfinger?resource=acct:kyler@blog.example",
+			handler:        activityPubApi.WebFinger,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:                "actor",
+			method:              http.MethodGet,
+			target:              "/ap/actor",
+			handler:             activityPubApi.GetActor,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/activity+json",
+		},
+		{
+			name:           "restricted_post_is_not_an_object",
+			method:         http.MethodGet,
+			target:         "/ap/posts/1",
+			pathId:         "1",
+			handler:        activityPubApi.GetObject,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unsigned_delivery",
+			method:         http.MethodPost,
+			target:         "/ap/inbox",
+			handler:        activityPubApi.PostInbox,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{"type":"Follow"}`))
+			req.SetPathValue("id", tt.pathId)
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedContentType != "" {
+				assert.Equal(t, tt.expectedContentType, rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
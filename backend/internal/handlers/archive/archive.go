@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	archive_models "github.com/KylerJacobson/blog/backend/internal/api/types/archive"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/services/archive"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// Exporter writes an archive of the blog
+type Exporter interface {
+	Export(w io.Writer, opts archive.Options) (*archive_models.Manifest, error)
+}
+
+type ArchiveApi interface {
+	Export(w http.ResponseWriter, r *http.Request)
+}
+
+type archiveApi struct {
+	exporter Exporter
+	logger   logger.Logger
+}
+
+func New(exporter Exporter, logger logger.Logger) *archiveApi {
+	return &archiveApi{
+		exporter: exporter,
+		logger:   logger,
+	}
+}
+
+// Export streams a zip archive of the blog. Media files are included unless ?blobs=false and password hashes
+// only with ?passwords=true. Restoring an archive is left to the archive command, since it needs an empty
+// database and so has no admin to authorize it.
+func (a *archiveApi) Export(w http.ResponseWriter, r *http.Request) {
+	opts := archive.Options{Blobs: true}
+	var err error
+	if v := r.URL.Query().Get("passwords"); v != "" {
+		opts.Passwords, err = strconv.ParseBool(v)
+		if err != nil {
+			httperr.Write(w, httperr.BadRequest("passwords must be true or false", ""))
+			return
+		}
+	}
+	if v := r.URL.Query().Get("blobs"); v != "" {
+		opts.Blobs, err = strconv.ParseBool(v)
+		if err != nil {
+			httperr.Write(w, httperr.BadRequest("blobs must be true or false", ""))
+			return
+		}
+	}
+
+	out := &trackingWriter{w: w}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="blog-%s.zip"`, time.Now().UTC().Format("20060102-150405")))
+	w.Header().Set("Cache-Control", "no-store")
+	manifest, err := a.exporter.Export(out, opts)
+	if err != nil {
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			a.logger.Sugar().Errorf("error exporting the archive : %v", err)
+			httperr.Write(w, httperr.Internal("error exporting the archive", ""))
+			return
+		}
+		// Too late for an error response; the archive is cut short before its manifest, so restoring it fails
+		a.logger.Sugar().Errorf("error exporting the archive after it started streaming : %v", err)
+		return
+	}
+	a.logger.Sugar().Infof("exported archive with %d tables and %d blobs", len(manifest.Tables), len(manifest.Blobs))
+}
+
+// trackingWriter notes whether anything reached the response, after which the status can't change
+type trackingWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	archive_models "github.com/KylerJacobson/blog/backend/internal/api/types/archive"
+	"github.com/KylerJacobson/blog/backend/internal/services/archive"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubExporter struct {
+	opts *archive.Options
+	err  error
+}
+
+func (s *stubExporter) Export(w io.Writer, opts archive.Options) (*archive_models.Manifest, error) {
+	s.opts = &opts
+	if s.err != nil {
+		return nil, s.err
+	}
+	w.Write([]byte("PK"))
+	return &archive_models.Manifest{}, nil
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		err            error
+		expectedStatus int
+		expectedOpts   *archive.Options
+	}{
+		{
+			name:           "defaults_to_blobs_without_passwords",
+			expectedStatus: http.StatusOK,
+			expectedOpts:   &archive.Options{Blobs: true},
+		},
+		{
+			name:           "passwords_and_no_blobs",
+			query:          "?passwords=true&blobs=false",
+			expectedStatus: http.StatusOK,
+			expectedOpts:   &archive.Options{Passwords: true},
+		},
+		{
+			name:           "invalid_flag",
+			query:          "?passwords=please",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error_before_streaming",
+			err:            errors.New("connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedOpts:   &archive.Options{Blobs: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &stubExporter{err: tt.err}
+			archiveApi := New(exporter, zap.NewNop())
+
+			rr := httptest.NewRecorder()
+			archiveApi.Export(rr, httptest.NewRequest(http.MethodGet, "/api/export"+tt.query, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedOpts, exporter.opts)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+				assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+			} else {
+				assert.Empty(t, rr.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
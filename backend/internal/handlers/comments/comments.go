@@ -0,0 +1,390 @@
+package comments
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	comment_models "github.com/KylerJacobson/blog/backend/internal/api/types/comments"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	comments_repo "github.com/KylerJacobson/blog/backend/internal/db/comments"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/logger"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const (
+	MaxBodyLength = 2000
+	// EditWindow is how long after posting an author may still edit or delete their comment
+	EditWindow = 15 * time.Minute
+)
+
+type CommentsApi interface {
+	GetComments(w http.ResponseWriter, r *http.Request)
+	CreateComment(w http.ResponseWriter, r *http.Request)
+	UpdateComment(w http.ResponseWriter, r *http.Request)
+	DeleteComment(w http.ResponseWriter, r *http.Request)
+	GetModerationQueue(w http.ResponseWriter, r *http.Request)
+	ApproveComment(w http.ResponseWriter, r *http.Request)
+	RejectComment(w http.ResponseWriter, r *http.Request)
+}
+
+type commentsApi struct {
+	commentsRepository comments_repo.CommentsRepository
+	postsRepository    posts_repo.PostsRepository
+	auth               *authorization.AuthService
+	logger             logger.Logger
+	now                func() time.Time
+}
+
+func New(commentsRepo comments_repo.CommentsRepository, postsRepo posts_repo.PostsRepository, auth *authorization.AuthService, logger logger.Logger) *commentsApi {
+	return &commentsApi{
+		commentsRepository: commentsRepo,
+		postsRepository:    postsRepo,
+		auth:               auth,
+		logger:             logger,
+		now:                time.Now,
+	}
+}
+
+// GetComments returns the post's comments as threads, each top-level comment carrying its replies
+func (c *commentsApi) GetComments(w http.ResponseWriter, r *http.Request) {
+	post, ok := c.loadPost(w, r)
+	if !ok {
+		return
+	}
+	userID := session.Manager.GetInt(r.Context(), "user_id")
+	comments, err := c.commentsRepository.GetCommentsByPostId(post.PostId, userID, c.auth.IsAdmin(r))
+	if err != nil {
+		c.logger.Sugar().Errorf("error getting comments for post %d : %v", post.PostId, err)
+		httperr.Write(w, httperr.Internal("error getting comments", ""))
+		return
+	}
+	b, err := json.Marshal(buildThreads(comments))
+	if err != nil {
+		c.logger.Sugar().Errorf("error marshalling comments for post %d : %v", post.PostId, err)
+		httperr.Write(w, httperr.Internal("error getting comments", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// CreateComment adds a comment or reply for the signed in user. Reader comments wait in the moderation
+// queue; comments from the admin are approved straight away.
+func (c *commentsApi) CreateComment(w http.ResponseWriter, r *http.Request) {
+	userID := session.Manager.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		httperr.Write(w, httperr.Unauthorized("user not authenticated", ""))
+		return
+	}
+	post, ok := c.loadPost(w, r)
+	if !ok {
+		return
+	}
+	var request comment_models.CommentRequestBody
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		c.logger.Sugar().Errorf("error decoding the comment request body: %v", err)
+		httperr.Write(w, httperr.BadRequest("error decoding comment request body", ""))
+		return
+	}
+	body, err := sanitizeBody(request.Body)
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("comment was not formatted correctly", err.Error()))
+		return
+	}
+
+	if request.ParentId != nil {
+		parent, err := c.commentsRepository.GetCommentById(*request.ParentId)
+		if err != nil && !errors.Is(err, v5.ErrNoRows) {
+			c.logger.Sugar().Errorf("error getting parent comment %d : %v", *request.ParentId, err)
+			httperr.Write(w, httperr.Internal("error creating comment", ""))
+			return
+		}
+		if err != nil || parent.PostId != post.PostId || parent.Status != comment_models.StatusApproved {
+			httperr.Write(w, httperr.BadRequest("comment was not formatted correctly", "parent comment not found on this post"))
+			return
+		}
+	}
+
+	status := comment_models.StatusPending
+	if c.auth.IsAdmin(r) {
+		status = comment_models.StatusApproved
+	}
+	comment, err := c.commentsRepository.CreateComment(post.PostId, request.ParentId, userID, body, status)
+	if err != nil {
+		c.logger.Sugar().Errorf("error creating comment on post %d : %v", post.PostId, err)
+		httperr.Write(w, httperr.Internal("error creating comment", ""))
+		return
+	}
+	b, err := json.Marshal(comment)
+	if err != nil {
+		c.logger.Sugar().Errorf("error marshalling comment %d : %v", comment.CommentId, err)
+		httperr.Write(w, httperr.Internal("error creating comment", ""))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+// UpdateComment lets an author rewrite their comment within EditWindow. The new text goes back through
+// moderation so an approved comment can't be swapped for something else.
+func (c *commentsApi) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := c.loadOwnComment(w, r)
+	if !ok {
+		return
+	}
+	var request comment_models.CommentRequestBody
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		c.logger.Sugar().Errorf("error decoding the comment request body: %v", err)
+		httperr.Write(w, httperr.BadRequest("error decoding comment request body", ""))
+		return
+	}
+	body, err := sanitizeBody(request.Body)
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("comment was not formatted correctly", err.Error()))
+		return
+	}
+
+	status := comment_models.StatusPending
+	if c.auth.IsAdmin(r) {
+		status = comment.Status
+	}
+	updated, err := c.commentsRepository.UpdateCommentBody(comment.CommentId, body, status)
+	if err != nil {
+		c.logger.Sugar().Errorf("error updating comment %d : %v", comment.CommentId, err)
+		httperr.Write(w, httperr.Internal("error updating comment", ""))
+		return
+	}
+	b, err := json.Marshal(updated)
+	if err != nil {
+		c.logger.Sugar().Errorf("error marshalling comment %d : %v", comment.CommentId, err)
+		httperr.Write(w, httperr.Internal("error updating comment", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// DeleteComment removes a comment and its replies. Authors may delete within EditWindow, the admin at any time.
+func (c *commentsApi) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	load := c.loadOwnComment
+	if c.auth.IsAdmin(r) {
+		load = c.loadComment
+	}
+	comment, ok := load(w, r)
+	if !ok {
+		return
+	}
+	err := c.commentsRepository.DeleteComment(comment.CommentId)
+	if err != nil {
+		httperr.Write(w, httperr.Internal("error deleting comment", ""))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *commentsApi) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	comments, err := c.commentsRepository.GetPendingComments()
+	if err != nil {
+		c.logger.Sugar().Errorf("error getting pending comments : %v", err)
+		httperr.Write(w, httperr.Internal("error getting pending comments", ""))
+		return
+	}
+	if comments == nil {
+		comments = []comment_models.Comment{}
+	}
+	b, err := json.Marshal(comments)
+	if err != nil {
+		c.logger.Sugar().Errorf("error marshalling pending comments : %v", err)
+		httperr.Write(w, httperr.Internal("error getting pending comments", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func (c *commentsApi) ApproveComment(w http.ResponseWriter, r *http.Request) {
+	c.moderate(w, r, comment_models.StatusApproved)
+}
+
+func (c *commentsApi) RejectComment(w http.ResponseWriter, r *http.Request) {
+	c.moderate(w, r, comment_models.StatusRejected)
+}
+
+func (c *commentsApi) moderate(w http.ResponseWriter, r *http.Request, status string) {
+	commentId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("commentId must be an integer", ""))
+		return
+	}
+	err = c.commentsRepository.SetCommentStatus(commentId, status)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("comment not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error moderating comment", ""))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadPost fetches the post named by the id path value and checks the caller may read it. Unpublished
+// posts are hidden from everyone but the admin and everything else follows the post access rules.
+func (c *commentsApi) loadPost(w http.ResponseWriter, r *http.Request) (*post_models.Post, bool) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return nil, false
+	}
+	post, err := c.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return nil, false
+		}
+		httperr.Write(w, httperr.Internal("error getting post by id", ""))
+		return nil, false
+	}
+	if post.Status != post_models.StatusPublished && !c.auth.IsAdmin(r) {
+		httperr.Write(w, httperr.NotFound("post not found", ""))
+		return nil, false
+	}
+	viewer := c.auth.Viewer(r)
+	if viewer.Admin {
+		return post, true
+	}
+	visible, err := c.postsRepository.CanView(post.PostId, viewer)
+	if err != nil {
+		httperr.Write(w, httperr.Internal("error getting post by id", ""))
+		return nil, false
+	}
+	if !visible {
+		httperr.Write(w, httperr.Forbidden("insufficient privileges", ""))
+		return nil, false
+	}
+	return post, true
+}
+
+func (c *commentsApi) loadComment(w http.ResponseWriter, r *http.Request) (*comment_models.Comment, bool) {
+	commentId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("commentId must be an integer", ""))
+		return nil, false
+	}
+	comment, err := c.commentsRepository.GetCommentById(commentId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("comment not found", ""))
+			return nil, false
+		}
+		c.logger.Sugar().Errorf("error getting comment %d : %v", commentId, err)
+		httperr.Write(w, httperr.Internal("error getting comment", ""))
+		return nil, false
+	}
+	return comment, true
+}
+
+// loadOwnComment is loadComment for the comment's author while the edit window is still open
+func (c *commentsApi) loadOwnComment(w http.ResponseWriter, r *http.Request) (*comment_models.Comment, bool) {
+	userID := session.Manager.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		httperr.Write(w, httperr.Unauthorized("user not authenticated", ""))
+		return nil, false
+	}
+	comment, ok := c.loadComment(w, r)
+	if !ok {
+		return nil, false
+	}
+	if comment.UserId != userID {
+		c.logger.Sugar().Warnf("user %d tried to change comment %d owned by user %d", userID, comment.CommentId, comment.UserId)
+		httperr.Write(w, httperr.Forbidden("insufficient privileges", ""))
+		return nil, false
+	}
+	if c.now().Sub(comment.CreatedAt) > EditWindow {
+		httperr.Write(w, httperr.Forbidden("comment can no longer be changed", ""))
+		return nil, false
+	}
+	return comment, true
+}
+
+var stripTags = bluemonday.StrictPolicy()
+
+// sanitizeBody turns a submitted comment into plain text: markup and control characters are dropped,
+// runs of blank lines collapsed and the length checked. The frontend renders bodies as text, never HTML.
+func sanitizeBody(body string) (string, error) {
+	body = html.UnescapeString(stripTags.Sanitize(body))
+	body = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(body, "\r\n", "\n"))
+
+	lines := strings.Split(body, "\n")
+	kept := lines[:0]
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		kept = append(kept, line)
+	}
+	body = strings.TrimSpace(strings.Join(kept, "\n"))
+
+	if body == "" {
+		return "", errors.New("comment body is required")
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLength {
+		return "", errors.New("comment body must be at most " + strconv.Itoa(MaxBodyLength) + " characters")
+	}
+	return body, nil
+}
+
+// buildThreads nests replies under their parents. Comments arrive oldest first so replies keep that order;
+// a reply whose parent isn't visible to the caller is left out along with the parent.
+func buildThreads(comments []comment_models.Comment) []comment_models.Comment {
+	children := map[int][]comment_models.Comment{}
+	var roots []comment_models.Comment
+	for _, comment := range comments {
+		if comment.ParentId == nil {
+			roots = append(roots, comment)
+		} else {
+			children[*comment.ParentId] = append(children[*comment.ParentId], comment)
+		}
+	}
+	var attach func(comment comment_models.Comment) comment_models.Comment
+	attach = func(comment comment_models.Comment) comment_models.Comment {
+		for _, reply := range children[comment.CommentId] {
+			comment.Replies = append(comment.Replies, attach(reply))
+		}
+		return comment
+	}
+	threads := make([]comment_models.Comment, 0, len(roots))
+	for _, root := range roots {
+		threads = append(threads, attach(root))
+	}
+	return threads
+}
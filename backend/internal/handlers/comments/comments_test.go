@@ -0,0 +1,340 @@
+package comments
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	comment_models "github.com/KylerJacobson/blog/backend/internal/api/types/comments"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockCommentsRepository struct {
+	mock.Mock
+}
+
+func (m *mockCommentsRepository) GetCommentsByPostId(postId, viewerId int, includeUnapproved bool) ([]comment_models.Comment, error) {
+	args := m.Called(postId, viewerId, includeUnapproved)
+	return args.Get(0).([]comment_models.Comment), args.Error(1)
+}
+
+func (m *mockCommentsRepository) GetCommentById(commentId int) (*comment_models.Comment, error) {
+	args := m.Called(commentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*comment_models.Comment), args.Error(1)
+}
+
+func (m *mockCommentsRepository) GetPendingComments() ([]comment_models.Comment, error) {
+	args := m.Called()
+	return args.Get(0).([]comment_models.Comment), args.Error(1)
+}
+
+func (m *mockCommentsRepository) CreateComment(postId int, parentId *int, userId int, body, status string) (*comment_models.Comment, error) {
+	args := m.Called(postId, parentId, userId, body, status)
+	return args.Get(0).(*comment_models.Comment), args.Error(1)
+}
+
+func (m *mockCommentsRepository) UpdateCommentBody(commentId int, body, status string) (*comment_models.Comment, error) {
+	args := m.Called(commentId, body, status)
+	return args.Get(0).(*comment_models.Comment), args.Error(1)
+}
+
+func (m *mockCommentsRepository) SetCommentStatus(commentId int, status string) error {
+	args := m.Called(commentId, status)
+	return args.Error(0)
+}
+
+func (m *mockCommentsRepository) DeleteComment(commentId int) error {
+	args := m.Called(commentId)
+	return args.Error(0)
+}
+
+// stubPostsRepository serves a fixed set of posts by id, with grants keyed by post id
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	posts  map[int]post_models.Post
+	grants map[int][]int
+}
+
+func (s *stubPostsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	post := s.posts[postId]
+	for _, userId := range s.grants[postId] {
+		if userId == viewer.UserId {
+			return true, nil
+		}
+	}
+	if post.Restricted {
+		return viewer.Privileged, nil
+	}
+	return len(s.grants[postId]) == 0, nil
+}
+
+func (s *stubPostsRepository) GetPostById(postId int) (*post_models.Post, error) {
+	post, ok := s.posts[postId]
+	if !ok {
+		return nil, v5.ErrNoRows
+	}
+	return &post, nil
+}
+
+var testPosts = &stubPostsRepository{posts: map[int]post_models.Post{
+	1: {PostId: 1, Status: post_models.StatusPublished},
+	2: {PostId: 2, Status: post_models.StatusPublished, Restricted: true},
+	3: {PostId: 3, Status: post_models.StatusDraft},
+	4: {PostId: 4, Status: post_models.StatusPublished},
+	5: {PostId: 5, Status: post_models.StatusPublished},
+}, grants: map[int][]int{4: {7}, 5: {8}}}
+
+func withUser(userId, role int, next http.HandlerFunc) http.Handler {
+	return session.Manager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Manager.Put(r.Context(), "user_id", userId)
+		session.Manager.Put(r.Context(), "user_role", role)
+		next(w, r)
+	}))
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestSanitizeBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", body: "  Nice post!  ", want: "Nice post!"},
+		{name: "markup stripped", body: `<script>alert(1)</script>Hi <b>there</b> & welcome`, want: "Hi there & welcome"},
+		{name: "blank lines collapsed", body: "one\r\n\r\n\r\n\r\ntwo\x00", want: "one\n\ntwo"},
+		{name: "empty", body: " <p></p> ", wantErr: true},
+		{name: "too long", body: strings.Repeat("a", MaxBodyLength+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeBody(tt.body)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuildThreads(t *testing.T) {
+	threads := buildThreads([]comment_models.Comment{
+		{CommentId: 1},
+		{CommentId: 2, ParentId: intPtr(1)},
+		{CommentId: 3},
+		{CommentId: 4, ParentId: intPtr(2)},
+		{CommentId: 5, ParentId: intPtr(99)},
+	})
+
+	assert.Len(t, threads, 2)
+	assert.Equal(t, 1, threads[0].CommentId)
+	assert.Equal(t, 2, threads[0].Replies[0].CommentId)
+	assert.Equal(t, 4, threads[0].Replies[0].Replies[0].CommentId)
+	assert.Equal(t, 3, threads[1].CommentId)
+	assert.Empty(t, threads[1].Replies)
+}
+
+func TestGetComments(t *testing.T) {
+	session.Init()
+
+	tests := []struct {
+		name              string
+		postId            string
+		role              int
+		includeUnapproved bool
+		expectedStatus    int
+	}{
+		{name: "public post", postId: "1", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusOK},
+		{name: "restricted post for privileged", postId: "2", role: authorization.RolePrivileged, expectedStatus: http.StatusOK},
+		{name: "restricted post for non-privileged", postId: "2", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusUnauthorized},
+		{name: "post granted to the user", postId: "4", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusOK},
+		{name: "post granted to someone else", postId: "5", role: authorization.RolePrivileged, expectedStatus: http.StatusUnauthorized},
+		{name: "admin ignores grants", postId: "5", role: authorization.RoleAdmin, includeUnapproved: true, expectedStatus: http.StatusOK},
+		{name: "draft post hidden", postId: "3", role: authorization.RolePrivileged, expectedStatus: http.StatusNotFound},
+		{name: "admin sees unapproved", postId: "1", role: authorization.RoleAdmin, includeUnapproved: true, expectedStatus: http.StatusOK},
+		{name: "missing post", postId: "9", role: authorization.RoleAdmin, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockCommentsRepository)
+			if tt.expectedStatus == http.StatusOK {
+				mockRepo.On("GetCommentsByPostId", mock.Anything, 7, tt.includeUnapproved).Return([]comment_models.Comment{{CommentId: 1}}, nil)
+			}
+			commentsApi := New(mockRepo, testPosts, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts/"+tt.postId+"/comments", nil)
+			req.SetPathValue("id", tt.postId)
+			rr := httptest.NewRecorder()
+			withUser(7, tt.role, commentsApi.GetComments).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateComment(t *testing.T) {
+	session.Init()
+
+	tests := []struct {
+		name           string
+		role           int
+		body           comment_models.CommentRequestBody
+		parent         *comment_models.Comment
+		expectedStatus string
+		expectedCode   int
+	}{
+		{name: "reader comment is queued", role: authorization.RoleNonPrivileged, body: comment_models.CommentRequestBody{Body: "hello"}, expectedStatus: comment_models.StatusPending, expectedCode: http.StatusCreated},
+		{name: "admin comment is approved", role: authorization.RoleAdmin, body: comment_models.CommentRequestBody{Body: "hello"}, expectedStatus: comment_models.StatusApproved, expectedCode: http.StatusCreated},
+		{
+			name:           "reply to approved comment",
+			role:           authorization.RoleNonPrivileged,
+			body:           comment_models.CommentRequestBody{Body: "hello", ParentId: intPtr(5)},
+			parent:         &comment_models.Comment{CommentId: 5, PostId: 1, Status: comment_models.StatusApproved},
+			expectedStatus: comment_models.StatusPending,
+			expectedCode:   http.StatusCreated,
+		},
+		{
+			name:         "reply to comment on another post",
+			role:         authorization.RoleNonPrivileged,
+			body:         comment_models.CommentRequestBody{Body: "hello", ParentId: intPtr(5)},
+			parent:       &comment_models.Comment{CommentId: 5, PostId: 2, Status: comment_models.StatusApproved},
+			expectedCode: http.StatusBadRequest,
+		},
+		{name: "empty body", role: authorization.RoleNonPrivileged, body: comment_models.CommentRequestBody{Body: "   "}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockCommentsRepository)
+			if tt.parent != nil {
+				mockRepo.On("GetCommentById", tt.parent.CommentId).Return(tt.parent, nil)
+			}
+			if tt.expectedCode == http.StatusCreated {
+				mockRepo.On("CreateComment", 1, tt.body.ParentId, 7, "hello", tt.expectedStatus).
+					Return(&comment_models.Comment{CommentId: 10, PostId: 1, Body: "hello", Status: tt.expectedStatus}, nil)
+			}
+			commentsApi := New(mockRepo, testPosts, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			b, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/posts/1/comments", bytes.NewReader(b))
+			req.SetPathValue("id", "1")
+			rr := httptest.NewRecorder()
+			withUser(7, tt.role, commentsApi.CreateComment).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	session.Init()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		comment      comment_models.Comment
+		expectedCode int
+	}{
+		{name: "author within window", comment: comment_models.Comment{CommentId: 3, UserId: 7, Status: comment_models.StatusApproved, CreatedAt: now.Add(-time.Minute)}, expectedCode: http.StatusOK},
+		{name: "window closed", comment: comment_models.Comment{CommentId: 3, UserId: 7, CreatedAt: now.Add(-EditWindow - time.Second)}, expectedCode: http.StatusUnauthorized},
+		{name: "someone else's comment", comment: comment_models.Comment{CommentId: 3, UserId: 8, CreatedAt: now}, expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockCommentsRepository)
+			mockRepo.On("GetCommentById", 3).Return(&tt.comment, nil)
+			if tt.expectedCode == http.StatusOK {
+				// Edits go back through moderation
+				mockRepo.On("UpdateCommentBody", 3, "edited", comment_models.StatusPending).Return(&comment_models.Comment{CommentId: 3}, nil)
+			}
+			commentsApi := New(mockRepo, testPosts, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+			commentsApi.now = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodPut, "/api/comments/3", strings.NewReader(`{"body":"edited"}`))
+			req.SetPathValue("id", "3")
+			rr := httptest.NewRecorder()
+			withUser(7, authorization.RoleNonPrivileged, commentsApi.UpdateComment).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	session.Init()
+	old := &comment_models.Comment{CommentId: 3, UserId: 8, CreatedAt: time.Now().Add(-24 * time.Hour)}
+
+	t.Run("admin may delete any comment", func(t *testing.T) {
+		mockRepo := new(mockCommentsRepository)
+		mockRepo.On("GetCommentById", 3).Return(old, nil)
+		mockRepo.On("DeleteComment", 3).Return(nil)
+		commentsApi := New(mockRepo, testPosts, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/comments/3", nil)
+		req.SetPathValue("id", "3")
+		rr := httptest.NewRecorder()
+		withUser(1, authorization.RoleAdmin, commentsApi.DeleteComment).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("author outside window may not", func(t *testing.T) {
+		mockRepo := new(mockCommentsRepository)
+		mockRepo.On("GetCommentById", 3).Return(old, nil)
+		commentsApi := New(mockRepo, testPosts, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/comments/3", nil)
+		req.SetPathValue("id", "3")
+		rr := httptest.NewRecorder()
+		withUser(8, authorization.RoleNonPrivileged, commentsApi.DeleteComment).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockRepo.AssertNotCalled(t, "DeleteComment", 3)
+	})
+}
+
+func TestModerateComment(t *testing.T) {
+	mockRepo := new(mockCommentsRepository)
+	mockRepo.On("SetCommentStatus", 4, comment_models.StatusApproved).Return(nil)
+	mockRepo.On("SetCommentStatus", 5, comment_models.StatusRejected).Return(v5.ErrNoRows)
+	commentsApi := New(mockRepo, testPosts, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/comments/4/approve", nil)
+	req.SetPathValue("id", "4")
+	rr := httptest.NewRecorder()
+	commentsApi.ApproveComment(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/comments/5/reject", nil)
+	req.SetPathValue("id", "5")
+	rr = httptest.NewRecorder()
+	commentsApi.RejectComment(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockRepo.AssertExpectations(t)
+}
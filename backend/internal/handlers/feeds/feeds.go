@@ -0,0 +1,233 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httpcache"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/locale"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+const (
+	FeedSize    = 20
+	SiteTitle   = "Kyler Jacobson"
+	SiteAuthor  = "Kyler Jacobson"
+	Description = "Posts from kylerjacobson.dev"
+)
+
+type FeedsApi interface {
+	GetRSS(w http.ResponseWriter, r *http.Request)
+	GetAtom(w http.ResponseWriter, r *http.Request)
+	GetJSONFeed(w http.ResponseWriter, r *http.Request)
+}
+
+type feedsApi struct {
+	postsRepository posts_repo.PostsRepository
+	siteURL         string
+	logger          logger.Logger
+}
+
+// New builds the feed handlers; siteURL is the public origin used for absolute links, e.g. https://kylerjacobson.dev
+func New(postsRepo posts_repo.PostsRepository, siteURL string, logger logger.Logger) *feedsApi {
+	return &feedsApi{
+		postsRepository: postsRepo,
+		siteURL:         strings.TrimRight(siteURL, "/"),
+		logger:          logger,
+	}
+}
+
+func (f *feedsApi) GetRSS(w http.ResponseWriter, r *http.Request) {
+	posts, ok := f.loadPosts(w, r, "rss")
+	if !ok {
+		return
+	}
+	channel := rssChannel{
+		Title:       SiteTitle,
+		Link:        f.siteURL + "/",
+		Description: Description,
+		Language:    feedLanguage(r, "en-us"),
+		SelfLink:    atomLink{Href: f.siteURL + "/feed.xml", Rel: "self", Type: "application/rss+xml"},
+	}
+	if updated := lastUpdated(posts); !updated.IsZero() {
+		channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, post := range posts {
+		link := f.postURL(post)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        link,
+			Guid:        rssGuid{IsPermaLink: true, Value: link},
+			PubDate:     post.CreatedAt.UTC().Format(time.RFC1123Z),
+			Description: post.Content,
+			Categories:  post.Tags,
+		})
+	}
+	f.writeXML(w, "application/rss+xml; charset=utf-8", rss{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: channel,
+	})
+}
+
+func (f *feedsApi) GetAtom(w http.ResponseWriter, r *http.Request) {
+	posts, ok := f.loadPosts(w, r, "atom")
+	if !ok {
+		return
+	}
+	feed := atomFeed{
+		Title: SiteTitle,
+		Id:    f.siteURL + "/",
+		// Atom requires an updated timestamp even for an empty feed
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.siteURL + "/atom.xml", Rel: "self", Type: "application/atom+xml"},
+			{Href: f.siteURL + "/", Rel: "alternate", Type: "text/html"},
+		},
+		Author: atomAuthor{Name: SiteAuthor},
+	}
+	if updated := lastUpdated(posts); !updated.IsZero() {
+		feed.Updated = updated.UTC().Format(time.RFC3339)
+	}
+	for _, post := range posts {
+		link := f.postURL(post)
+		entry := atomEntry{
+			Title:     post.Title,
+			Id:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: post.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   postUpdated(post).UTC().Format(time.RFC3339),
+			Content:   atomContent{Type: "text", Value: post.Content},
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	f.writeXML(w, "application/atom+xml; charset=utf-8", feed)
+}
+
+func (f *feedsApi) GetJSONFeed(w http.ResponseWriter, r *http.Request) {
+	posts, ok := f.loadPosts(w, r, "json")
+	if !ok {
+		return
+	}
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       SiteTitle,
+		HomePageUrl: f.siteURL + "/",
+		FeedUrl:     f.siteURL + "/feed.json",
+		Language:    feedLanguage(r, "en-US"),
+		Authors:     []jsonAuthor{{Name: SiteAuthor}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, post := range posts {
+		link := f.postURL(post)
+		feed.Items = append(feed.Items, jsonFeedItem{
+			Id:            link,
+			Url:           link,
+			Title:         post.Title,
+			ContentText:   post.Content,
+			DatePublished: post.CreatedAt.UTC().Format(time.RFC3339),
+			DateModified:  postUpdated(post).UTC().Format(time.RFC3339),
+			Tags:          post.Tags,
+			Language:      post.Locale,
+		})
+	}
+	b, err := json.Marshal(feed)
+	if err != nil {
+		f.logger.Sugar().Errorf("error marshalling json feed : %v", err)
+		httperr.Write(w, httperr.Internal("error building feed", ""))
+		return
+	}
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// loadPosts fetches the latest public posts, translated into the reader's language by ?lang= or Accept-Language
+// where possible, and answers conditional requests. It returns false when the response has already been
+// written, either a 304 or an error.
+func (f *feedsApi) loadPosts(w http.ResponseWriter, r *http.Request, format string) ([]post_models.Post, bool) {
+	posts, err := f.postsRepository.GetPosts(post_models.PostFilter{Limit: FeedSize, Locales: locale.Preferences(r)})
+	if err != nil {
+		f.logger.Sugar().Errorf("error getting posts for %s feed : %v", format, err)
+		httperr.Write(w, httperr.Internal("error building feed", ""))
+		return nil, false
+	}
+
+	// The repository already leaves out restricted posts for an unprivileged filter, this is a second guard
+	public := make([]post_models.Post, 0, len(posts))
+	for _, post := range posts {
+		if !post.Restricted && post.Status == post_models.StatusPublished {
+			public = append(public, post)
+		}
+	}
+
+	parts := []any{format}
+	for _, post := range public {
+		parts = append(parts, post.PostId, postUpdated(post).UnixNano(), post.Locale)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Vary", "Accept-Language")
+	if httpcache.NotModified(w, r, httpcache.ETag(parts...), lastUpdated(public)) {
+		return nil, false
+	}
+	return public, true
+}
+
+func (f *feedsApi) writeXML(w http.ResponseWriter, contentType string, feed any) {
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		f.logger.Sugar().Errorf("error marshalling feed : %v", err)
+		httperr.Write(w, httperr.Internal("error building feed", ""))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
+// postURL links to the post in the language the feed carries it in
+func (f *feedsApi) postURL(post post_models.Post) string {
+	link := fmt.Sprintf("%s/post/%d", f.siteURL, post.PostId)
+	if len(post.Locales) > 0 && post.Locale != post.Locales[0] {
+		link += "?lang=" + url.QueryEscape(post.Locale)
+	}
+	return link
+}
+
+// feedLanguage is the language the reader asked for, or fallback when they didn't
+func feedLanguage(r *http.Request, fallback string) string {
+	if preferences := locale.Preferences(r); len(preferences) > 0 {
+		return preferences[0]
+	}
+	return fallback
+}
+
+// postUpdated is the later of UpdatedAt and CreatedAt; publishing a scheduled post moves CreatedAt forward
+func postUpdated(post post_models.Post) time.Time {
+	if post.UpdatedAt.After(post.CreatedAt) {
+		return post.UpdatedAt
+	}
+	return post.CreatedAt
+}
+
+func lastUpdated(posts []post_models.Post) time.Time {
+	var latest time.Time
+	for _, post := range posts {
+		if updated := postUpdated(post); updated.After(latest) {
+			latest = updated
+		}
+	}
+	return latest
+}
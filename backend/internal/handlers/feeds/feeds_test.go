@@ -0,0 +1,134 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	posts  []post_models.Post
+	filter post_models.PostFilter
+}
+
+func (s *stubPostsRepository) GetPosts(filter post_models.PostFilter) ([]post_models.Post, error) {
+	s.filter = filter
+	return s.posts, nil
+}
+
+func testPosts() []post_models.Post {
+	created := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	return []post_models.Post{
+		{PostId: 2, Title: "Second & last", Content: "body <b>two</b>", CreatedAt: created, UpdatedAt: created.Add(48 * time.Hour), Status: post_models.StatusPublished, Tags: []string{"go"}},
+		{PostId: 3, Title: "Family only", Content: "secret", CreatedAt: created, UpdatedAt: created, Restricted: true, Status: post_models.StatusPublished},
+		{PostId: 1, Title: "First", Content: "body one", CreatedAt: created.Add(-time.Hour), UpdatedAt: created.Add(-time.Hour), Status: post_models.StatusPublished},
+	}
+}
+
+func TestGetRSS(t *testing.T) {
+	repo := &stubPostsRepository{posts: testPosts()}
+	feedsApi := New(repo, "https://example.dev/", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	feedsApi.GetRSS(rr, httptest.NewRequest(http.MethodGet, "/feed.xml", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, post_models.PostFilter{Limit: FeedSize}, repo.filter)
+
+	var feed rss
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &feed))
+	assert.Equal(t, "Fri, 03 May 2024 08:00:00 +0000", feed.Channel.LastBuildDate)
+	assert.Len(t, feed.Channel.Items, 2)
+	assert.Equal(t, "Second & last", feed.Channel.Items[0].Title)
+	assert.Equal(t, "https://example.dev/post/2", feed.Channel.Items[0].Link)
+	assert.Equal(t, "body <b>two</b>", feed.Channel.Items[0].Description)
+	assert.Equal(t, []string{"go"}, feed.Channel.Items[0].Categories)
+	assert.NotContains(t, rr.Body.String(), "secret")
+}
+
+func TestGetAtom(t *testing.T) {
+	feedsApi := New(&stubPostsRepository{posts: testPosts()}, "https://example.dev", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	feedsApi.GetAtom(rr, httptest.NewRequest(http.MethodGet, "/atom.xml", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var feed atomFeed
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &feed))
+	assert.Equal(t, "2024-05-03T08:00:00Z", feed.Updated)
+	assert.Len(t, feed.Entries, 2)
+	assert.Equal(t, "2024-05-01T08:00:00Z", feed.Entries[0].Published)
+	assert.Equal(t, "2024-05-03T08:00:00Z", feed.Entries[0].Updated)
+	assert.Equal(t, "https://example.dev/post/2", feed.Entries[0].Id)
+}
+
+func TestGetJSONFeed(t *testing.T) {
+	feedsApi := New(&stubPostsRepository{posts: testPosts()}, "https://example.dev", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	feedsApi.GetJSONFeed(rr, httptest.NewRequest(http.MethodGet, "/feed.json", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/feed+json; charset=utf-8", rr.Header().Get("Content-Type"))
+	var feed jsonFeed
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&feed))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	assert.Equal(t, "https://example.dev/feed.json", feed.FeedUrl)
+	assert.Len(t, feed.Items, 2)
+	assert.Equal(t, "2024-05-03T08:00:00Z", feed.Items[0].DateModified)
+}
+
+func TestGetJSONFeedTranslated(t *testing.T) {
+	created := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	repo := &stubPostsRepository{posts: []post_models.Post{
+		{PostId: 2, Title: "Viaje familiar", CreatedAt: created, UpdatedAt: created, Status: post_models.StatusPublished, Locale: "es", Locales: []string{"en", "es"}},
+		{PostId: 1, Title: "Untranslated", CreatedAt: created, UpdatedAt: created, Status: post_models.StatusPublished, Locale: "en", Locales: []string{"en"}},
+	}}
+	feedsApi := New(repo, "https://example.dev", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	feedsApi.GetJSONFeed(rr, httptest.NewRequest(http.MethodGet, "/feed.json?lang=es", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{"es"}, repo.filter.Locales)
+	assert.Equal(t, "Accept-Language", rr.Header().Get("Vary"))
+	var feed jsonFeed
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&feed))
+	assert.Equal(t, "es", feed.Language)
+	assert.Equal(t, "https://example.dev/post/2?lang=es", feed.Items[0].Url)
+	assert.Equal(t, "es", feed.Items[0].Language)
+	assert.Equal(t, "https://example.dev/post/1", feed.Items[1].Url)
+	assert.Equal(t, "en", feed.Items[1].Language)
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	feedsApi := New(&stubPostsRepository{posts: testPosts()}, "https://example.dev", zap.NewNop())
+
+	first := httptest.NewRecorder()
+	feedsApi.GetRSS(first, httptest.NewRequest(http.MethodGet, "/feed.xml", nil))
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "Fri, 03 May 2024 08:00:00 GMT", first.Header().Get("Last-Modified"))
+
+	req := httptest.NewRequest(http.MethodGet, "/feed.xml", nil)
+	req.Header.Set("If-None-Match", etag)
+	second := httptest.NewRecorder()
+	feedsApi.GetRSS(second, req)
+	assert.Equal(t, http.StatusNotModified, second.Code)
+	assert.Empty(t, second.Body.String())
+
+	// Each format has its own representation and therefore its own ETag
+	atom := httptest.NewRecorder()
+	feedsApi.GetAtom(atom, httptest.NewRequest(http.MethodGet, "/atom.xml", nil))
+	assert.NotEqual(t, etag, atom.Header().Get("ETag"))
+}
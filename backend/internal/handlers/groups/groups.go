@@ -0,0 +1,179 @@
+package groups
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	group_models "github.com/KylerJacobson/blog/backend/internal/api/types/groups"
+	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
+	groups_repo "github.com/KylerJacobson/blog/backend/internal/db/groups"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/logger"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+const MaxNameLength = 50
+
+type GroupsApi interface {
+	GetGroups(w http.ResponseWriter, r *http.Request)
+	CreateGroup(w http.ResponseWriter, r *http.Request)
+	DeleteGroup(w http.ResponseWriter, r *http.Request)
+	GetMembers(w http.ResponseWriter, r *http.Request)
+	AddMember(w http.ResponseWriter, r *http.Request)
+	RemoveMember(w http.ResponseWriter, r *http.Request)
+}
+
+type groupsApi struct {
+	groupsRepository groups_repo.GroupsRepository
+	logger           logger.Logger
+}
+
+func New(groupsRepo groups_repo.GroupsRepository, logger logger.Logger) *groupsApi {
+	return &groupsApi{
+		groupsRepository: groupsRepo,
+		logger:           logger,
+	}
+}
+
+func (g *groupsApi) GetGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := g.groupsRepository.GetGroups()
+	if err != nil {
+		g.logger.Sugar().Errorf("error getting groups : %v", err)
+		httperr.Write(w, httperr.Internal("error getting groups", ""))
+		return
+	}
+	if groups == nil {
+		groups = []group_models.Group{}
+	}
+	b, err := json.Marshal(groups)
+	if err != nil {
+		g.logger.Sugar().Errorf("error marshalling groups : %v", err)
+		httperr.Write(w, httperr.Internal("error getting groups", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func (g *groupsApi) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var request group_models.GroupRequestBody
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		g.logger.Sugar().Errorf("error decoding the group request body: %v", err)
+		httperr.Write(w, httperr.BadRequest("error decoding group request body", ""))
+		return
+	}
+	name := normalizeName(request.Name)
+	if name == "" {
+		httperr.Write(w, httperr.BadRequest("group name must not be empty", ""))
+		return
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		httperr.Write(w, httperr.BadRequest("group name is too long", "names are limited to "+strconv.Itoa(MaxNameLength)+" characters"))
+		return
+	}
+	group, err := g.groupsRepository.CreateGroup(name)
+	if err != nil {
+		if errors.Is(err, groups_repo.ErrDuplicateName) {
+			httperr.Write(w, httperr.Conflict("group already exists", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error creating group", ""))
+		return
+	}
+	b, err := json.Marshal(group)
+	if err != nil {
+		g.logger.Sugar().Errorf("error marshalling group %d : %v", group.GroupId, err)
+		httperr.Write(w, httperr.Internal("error creating group", ""))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+func (g *groupsApi) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	groupId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("groupId must be an integer", ""))
+		return
+	}
+	err = g.groupsRepository.DeleteGroup(groupId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("group not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error deleting group", ""))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *groupsApi) GetMembers(w http.ResponseWriter, r *http.Request) {
+	groupId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("groupId must be an integer", ""))
+		return
+	}
+	members, err := g.groupsRepository.GetMembers(groupId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("group not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error getting group members", ""))
+		return
+	}
+	if members == nil {
+		members = []user_models.User{}
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		g.logger.Sugar().Errorf("error marshalling members of group %d : %v", groupId, err)
+		httperr.Write(w, httperr.Internal("error getting group members", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func (g *groupsApi) AddMember(w http.ResponseWriter, r *http.Request) {
+	g.membership(w, r, "adding", g.groupsRepository.AddMember)
+}
+
+func (g *groupsApi) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	g.membership(w, r, "removing", g.groupsRepository.RemoveMember)
+}
+
+func (g *groupsApi) membership(w http.ResponseWriter, r *http.Request, verb string, action func(groupId, userId int) error) {
+	groupId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("groupId must be an integer", ""))
+		return
+	}
+	userId, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("userId must be an integer", ""))
+		return
+	}
+	err = action(groupId, userId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			g.logger.Sugar().Warnf("%s user %d and group %d: no such group, user or membership", verb, userId, groupId)
+			httperr.Write(w, httperr.NotFound("group member not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error "+verb+" group member", ""))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeName lowercases a group name and collapses its whitespace so "Family" and " family " are one group
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
@@ -0,0 +1,154 @@
+package groups
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	group_models "github.com/KylerJacobson/blog/backend/internal/api/types/groups"
+	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
+	groups_repo "github.com/KylerJacobson/blog/backend/internal/db/groups"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockGroupsRepository struct {
+	mock.Mock
+}
+
+func (m *mockGroupsRepository) GetGroups() ([]group_models.Group, error) {
+	args := m.Called()
+	return args.Get(0).([]group_models.Group), args.Error(1)
+}
+
+func (m *mockGroupsRepository) CreateGroup(name string) (*group_models.Group, error) {
+	args := m.Called(name)
+	group, _ := args.Get(0).(*group_models.Group)
+	return group, args.Error(1)
+}
+
+func (m *mockGroupsRepository) DeleteGroup(groupId int) error {
+	args := m.Called(groupId)
+	return args.Error(0)
+}
+
+func (m *mockGroupsRepository) GetMembers(groupId int) ([]user_models.User, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockGroupsRepository) AddMember(groupId, userId int) error {
+	args := m.Called(groupId, userId)
+	return args.Error(0)
+}
+
+func (m *mockGroupsRepository) RemoveMember(groupId, userId int) error {
+	args := m.Called(groupId, userId)
+	return args.Error(0)
+}
+
+func TestGetGroupsNeverNull(t *testing.T) {
+	mockRepo := new(mockGroupsRepository)
+	mockRepo.On("GetGroups").Return([]group_models.Group(nil), nil)
+	groupsApi := New(mockRepo, zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	groupsApi.GetGroups(rr, httptest.NewRequest(http.MethodGet, "/api/groups", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", rr.Body.String())
+}
+
+func TestCreateGroup(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		setupMock      func(*mockGroupsRepository)
+		expectedStatus int
+	}{
+		{
+			name: "name_is_normalized",
+			body: `{"name":"  Close   Family "}`,
+			setupMock: func(m *mockGroupsRepository) {
+				m.On("CreateGroup", "close family").Return(&group_models.Group{GroupId: 1, Name: "close family", CreatedAt: time.Now()}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "duplicate_name",
+			body: `{"name":"family"}`,
+			setupMock: func(m *mockGroupsRepository) {
+				m.On("CreateGroup", "family").Return(nil, groups_repo.ErrDuplicateName)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "empty_name",
+			body:           `{"name":"   "}`,
+			setupMock:      func(m *mockGroupsRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "name_too_long",
+			body:           `{"name":"` + strings.Repeat("a", MaxNameLength+1) + `"}`,
+			setupMock:      func(m *mockGroupsRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockGroupsRepository)
+			tt.setupMock(mockRepo)
+			groupsApi := New(mockRepo, zap.NewNop())
+
+			rr := httptest.NewRecorder()
+			groupsApi.CreateGroup(rr, httptest.NewRequest(http.MethodPost, "/api/groups", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMembership(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		userId         string
+		repoErr        error
+		expectedStatus int
+	}{
+		{name: "add_member", method: http.MethodPut, userId: "9", expectedStatus: http.StatusNoContent},
+		{name: "add_unknown_user", method: http.MethodPut, userId: "9", repoErr: pgx.ErrNoRows, expectedStatus: http.StatusNotFound},
+		{name: "remove_member", method: http.MethodDelete, userId: "9", expectedStatus: http.StatusNoContent},
+		{name: "remove_non_member", method: http.MethodDelete, userId: "9", repoErr: pgx.ErrNoRows, expectedStatus: http.StatusNotFound},
+		{name: "bad_user_id", method: http.MethodPut, userId: "me", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockGroupsRepository)
+			handler := func(g *groupsApi) http.HandlerFunc { return g.AddMember }
+			if tt.method == http.MethodDelete {
+				mockRepo.On("RemoveMember", 3, 9).Return(tt.repoErr).Maybe()
+				handler = func(g *groupsApi) http.HandlerFunc { return g.RemoveMember }
+			} else {
+				mockRepo.On("AddMember", 3, 9).Return(tt.repoErr).Maybe()
+			}
+			groupsApi := New(mockRepo, zap.NewNop())
+
+			req := httptest.NewRequest(tt.method, "/api/groups/3/members/"+tt.userId, nil)
+			req.SetPathValue("id", "3")
+			req.SetPathValue("userId", tt.userId)
+			rr := httptest.NewRecorder()
+			handler(groupsApi)(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package media
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,20 +9,29 @@ import (
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
 	"github.com/KylerJacobson/blog/backend/internal/authorization"
 	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httpcache"
 	"github.com/KylerJacobson/blog/backend/internal/httperr"
-	"github.com/KylerJacobson/blog/backend/internal/services/azure"
+	"github.com/KylerJacobson/blog/backend/internal/imaging"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
 	"github.com/KylerJacobson/blog/backend/logger"
+	pgxv5 "github.com/jackc/pgx/v5"
 )
 
 const (
 	MaxFileSize        = 10 << 20 // 10 MB
 	MaxTotalUploadSize = 50 << 20 // 50 MB
 	MaxFilesPerRequest = 5        // Maximum number of files per upload
+	// SignedURLExpiry is how long the storage URL GetMediaFile redirects to stays valid
+	SignedURLExpiry = 5 * time.Minute
 )
 
 var AllowedFileTypes = map[string]bool{
@@ -41,23 +51,33 @@ var FileTypeExtensions = map[string]string{
 
 type MediaApi interface {
 	GetMediaByPostId(w http.ResponseWriter, r *http.Request)
+	GetMediaFile(w http.ResponseWriter, r *http.Request)
 	UploadMedia(w http.ResponseWriter, r *http.Request)
 	DeleteMediaByPostId(w http.ResponseWriter, r *http.Request)
 }
 
+// VariantQueue makes the resized variants of new image uploads in the background
+type VariantQueue interface {
+	Wake()
+}
+
 type mediaApi struct {
 	mediaRepository media_repo.MediaRepository
+	postsRepository posts_repo.PostsRepository
 	auth            *authorization.AuthService
 	logger          logger.Logger
-	azClient        *azure.AzureClient
+	blobs           storage.BlobStore
+	variants        VariantQueue
 }
 
-func New(mediaRepo media_repo.MediaRepository, auth *authorization.AuthService, logger logger.Logger, client *azure.AzureClient) *mediaApi {
+func New(mediaRepo media_repo.MediaRepository, postsRepo posts_repo.PostsRepository, auth *authorization.AuthService, logger logger.Logger, blobs storage.BlobStore, variants VariantQueue) *mediaApi {
 	return &mediaApi{
 		mediaRepository: mediaRepo,
+		postsRepository: postsRepo,
 		auth:            auth,
 		logger:          logger,
-		azClient:        client,
+		blobs:           blobs,
+		variants:        variants,
 	}
 }
 
@@ -70,47 +90,202 @@ func (m *mediaApi) GetMediaByPostId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	privilege := m.auth.CheckPrivilege(r)
+	// Attachments follow the access rules of the post they belong to
+	viewer := m.auth.Viewer(r)
+	if !viewer.Admin {
+		visible, err := m.postsRepository.CanView(postId, viewer)
+		if err != nil {
+			httperr.Write(w, httperr.Internal("internal server error", ""))
+			return
+		}
+		if !visible {
+			httperr.Write(w, httperr.Forbidden("insufficient privileges", ""))
+			return
+		}
+	}
 
 	media, err := m.mediaRepository.GetMediaByPostId(postId)
 	if err != nil {
 		httperr.Write(w, httperr.Internal("internal server error", ""))
 		return
 	}
+	variants, err := m.mediaRepository.GetVariantsByPostId(postId)
+	if err != nil {
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
+	// Restricted attachments stay out of the listing for viewers GetMediaFile wouldn't serve them to, even on a
+	// post they can read
+	if !viewer.Admin && !viewer.Privileged {
+		visible := make([]media_models.Post, 0, len(media))
+		hidden := map[int]bool{}
+		for _, attachment := range media {
+			if attachment.Restricted {
+				hidden[attachment.MediaId] = true
+				continue
+			}
+			visible = append(visible, attachment)
+		}
+		media = visible
+		visibleVariants := make([]media_models.Variant, 0, len(variants))
+		for _, variant := range variants {
+			if !hidden[variant.MediaId] {
+				visibleVariants = append(visibleVariants, variant)
+			}
+		}
+		variants = visibleVariants
+	}
+
+	parts := []any{viewer}
+	var lastModified time.Time
+	for _, attachment := range media {
+		parts = append(parts, attachment.MediaId, attachment.BlobName, attachment.ContentType, attachment.Restricted, attachment.CreatedAt.UnixNano())
+		if attachment.CreatedAt.After(lastModified) {
+			lastModified = attachment.CreatedAt
+		}
+	}
+	// Variants show up a little after their upload, so they change the response too
+	variantsByMedia := map[int][]media_models.Variant{}
+	for _, variant := range variants {
+		parts = append(parts, variant.MediaId, variant.Name, variant.CreatedAt.UnixNano())
+		if variant.CreatedAt.After(lastModified) {
+			lastModified = variant.CreatedAt
+		}
+		variantsByMedia[variant.MediaId] = append(variantsByMedia[variant.MediaId], variant)
+	}
+	httpcache.SetCacheControl(w, m.auth.IsAuthenticated(r))
+	if httpcache.NotModified(w, r, httpcache.ETag(parts...), lastModified) {
+		return
+	}
 	// TODO Add URL top postObject
 
 	// TODO create object with post + urls
+	type mediaVariant struct {
+		Name   string `json:"name"`
+		Url    string `json:"url"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	}
 	type postMedia struct {
+		MediaId     int    `json:"mediaId"`
 		Url         string `json:"url"`
 		ContentType string `json:"contentType"`
 		Name        string `json:"name"`
 		PostId      int    `json:"postId"`
+		// Srcset lists the resized variants as an img srcset value, e.g. "/api/media/file/3/w320 320w, ..."
+		Srcset       string         `json:"srcset,omitempty"`
+		ThumbnailUrl string         `json:"thumbnailUrl,omitempty"`
+		Variants     []mediaVariant `json:"variants"`
+		CapturedAt   *time.Time     `json:"capturedAt,omitempty"`
+		Camera       *string        `json:"camera,omitempty"`
 	}
 	var postMediaSlc = []postMedia{}
 	for _, attachment := range media {
-		//Check auth status
-		url, err := m.azClient.GetUrlForBlob(attachment.BlobName)
+		item := postMedia{MediaId: attachment.MediaId, Url: FilePath(attachment.MediaId), ContentType: attachment.ContentType, Name: attachment.BlobName, PostId: attachment.PostId, Variants: []mediaVariant{}}
+		var srcset []string
+		for _, variant := range variantsByMedia[attachment.MediaId] {
+			url := VariantPath(attachment.MediaId, variant.Name)
+			item.Variants = append(item.Variants, mediaVariant{Name: variant.Name, Url: url, Width: variant.Width, Height: variant.Height})
+			if variant.Name == imaging.ThumbnailName {
+				item.ThumbnailUrl = url
+				continue
+			}
+			srcset = append(srcset, fmt.Sprintf("%s %dw", url, variant.Width))
+		}
+		item.Srcset = strings.Join(srcset, ", ")
+		// The capture details were stripped from the file for privacy, so only admins get them back
+		if viewer.Admin {
+			item.CapturedAt, item.Camera = attachment.CapturedAt, attachment.Camera
+		}
+		postMediaSlc = append(postMediaSlc, item)
+	}
+	b, err := json.Marshal(postMediaSlc)
+	if err != nil {
+		m.logger.Sugar().Errorf("error marshalling media post for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// GetMediaFile serves one attachment after checking the caller may see it. Restricted attachments are streamed
+// so their bytes are never reachable without a session; the rest redirect to a storage URL that expires within
+// minutes, so a leaked link stops working almost at once either way.
+func (m *mediaApi) GetMediaFile(w http.ResponseWriter, r *http.Request) {
+	mediaId, err := strconv.Atoi(r.PathValue("mediaId"))
+	if err != nil {
+		m.logger.Sugar().Errorf("mediaId parameter was not an integer: %v", err)
+		httperr.Write(w, httperr.BadRequest("mediaId must be an integer", ""))
+		return
+	}
+	attachment, err := m.mediaRepository.GetMediaById(mediaId)
+	if err != nil {
+		if errors.Is(err, pgxv5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("media not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
+
+	viewer := m.auth.Viewer(r)
+	if !viewer.Admin {
+		visible, err := m.postsRepository.CanView(attachment.PostId, viewer)
 		if err != nil {
-			m.logger.Sugar().Errorf("error getting URL for blob: %v", err)
 			httperr.Write(w, httperr.Internal("internal server error", ""))
 			return
 		}
-		postMediaSlc = append(postMediaSlc, postMedia{Url: url, ContentType: attachment.ContentType, Name: attachment.BlobName, PostId: attachment.PostId})
-		if attachment.Restricted {
-			if !privilege {
-				httperr.Write(w, httperr.Forbidden("insufficient privileges", ""))
+		if !visible || (attachment.Restricted && !viewer.Privileged) {
+			httperr.Write(w, httperr.Forbidden("insufficient privileges", ""))
+			return
+		}
+	}
+
+	// Variants are served under the same rules as their original
+	blobName, contentType := attachment.BlobName, attachment.ContentType
+	if name := r.PathValue("variant"); name != "" {
+		variant, err := m.mediaRepository.GetVariant(mediaId, name)
+		if err != nil {
+			if errors.Is(err, pgxv5.ErrNoRows) {
+				httperr.Write(w, httperr.NotFound("variant not found", ""))
 				return
 			}
+			httperr.Write(w, httperr.Internal("internal server error", ""))
+			return
 		}
+		blobName, contentType = variant.BlobName, variant.ContentType
 	}
-	b, err := json.Marshal(postMediaSlc)
+
+	if !attachment.Restricted {
+		url, err := m.blobs.GetUrlForBlob(blobName, SignedURLExpiry)
+		if err != nil {
+			m.logger.Sugar().Errorf("error getting URL for blob %s: %v", blobName, err)
+			httperr.Write(w, httperr.Internal("internal server error", ""))
+			return
+		}
+		// Let the browser reuse the redirect, but never past the URL's own expiry
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(SignedURLExpiry.Seconds())/2))
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	blob, err := m.blobs.OpenBlob(blobName)
 	if err != nil {
-		m.logger.Sugar().Errorf("error marshalling media post for post %d : %v", postId, err)
+		if errors.Is(err, storage.ErrNotFound) {
+			m.logger.Sugar().Warnf("blob %s of media %d is missing", blobName, mediaId)
+			httperr.Write(w, httperr.NotFound("media not found", ""))
+			return
+		}
+		m.logger.Sugar().Errorf("error opening blob %s: %v", blobName, err)
 		httperr.Write(w, httperr.Internal("internal server error", ""))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+	defer blob.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	// ServeContent answers Range and If-Range requests, which is what lets a video seek
+	http.ServeContent(w, r, "", attachment.CreatedAt, blob)
 }
 
 func (m *mediaApi) DeleteMediaByPostId(w http.ResponseWriter, r *http.Request) {
@@ -122,11 +297,29 @@ func (m *mediaApi) DeleteMediaByPostId(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	media, err := m.mediaRepository.GetMediaByPostId(postId)
+	if err != nil {
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
+	variants, err := m.mediaRepository.GetVariantsByPostId(postId)
+	if err != nil {
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
 	err = m.mediaRepository.DeleteMediaByPostId(postId)
 	if err != nil {
 		httperr.Write(w, httperr.Internal("internal server error", ""))
 		return
 	}
+	var blobNames []string
+	for _, attachment := range media {
+		blobNames = append(blobNames, attachment.BlobName)
+	}
+	for _, variant := range variants {
+		blobNames = append(blobNames, variant.BlobName)
+	}
+	storage.DeleteBlobs(m.blobs, blobNames, m.logger)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -165,6 +358,16 @@ func (m *mediaApi) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		httperr.Write(w, httperr.BadRequest("restricted must be a boolean", ""))
 		return
 	}
+	// keepMetadata opts the upload out of EXIF stripping, for photos whose location is meant to be shared
+	keepMetadata := false
+	if keepMetadataStr := r.Form.Get("keepMetadata"); keepMetadataStr != "" {
+		keepMetadata, err = strconv.ParseBool(keepMetadataStr)
+		if err != nil {
+			m.logger.Sugar().Errorf("keepMetadata parameter was not a boolean: %v", err)
+			httperr.Write(w, httperr.BadRequest("keepMetadata must be a boolean", ""))
+			return
+		}
+	}
 
 	files := r.MultipartForm.File["photos"]
 	if files == nil {
@@ -188,25 +391,33 @@ func (m *mediaApi) UploadMedia(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		safeFilename := sanitizeFilename(fileHeader.Filename)
-
-		blobName := fmt.Sprintf("blog-media/%d_%s", postId, safeFilename)
-		err = m.azClient.UploadFileToBlob(fileHeader, blobName)
+		blobName := BlobName(postId, fileHeader.Filename)
+		metadata, err := m.uploadFile(fileHeader, fileType, blobName, keepMetadata)
+		if errors.Is(err, imaging.ErrMalformed) {
+			m.logger.Sugar().Warnf("unreadable image %s: %v", fileHeader.Filename, err)
+			failedUploads++
+			continue
+		}
 		if err != nil {
 			failedUploads++
 			m.logger.Sugar().Errorf("error uploading media: %v", err)
 			httperr.Write(w, httperr.Internal("internal server error", ""))
 			return
 		}
-		err = m.mediaRepository.UploadMedia(postId, blobName, fileType, restricted)
+		err = Record(m.mediaRepository, postId, blobName, fileType, restricted, metadata)
 		if err != nil {
-			// TODO delete the blob since the database entry failed
 			m.logger.Sugar().Errorf("error uploading media reference to database: %v", err)
+			if err := m.blobs.DeleteBlob(blobName); err != nil {
+				m.logger.Sugar().Errorf("error deleting orphaned blob %s: %v", blobName, err)
+			}
 			httperr.Write(w, httperr.Internal("internal server error", ""))
 			continue
 		}
 		successfulUploads++
 	}
+	if successfulUploads > 0 {
+		m.variants.Wake()
+	}
 	if successfulUploads == 0 && failedUploads > 0 {
 		httperr.Write(w, httperr.BadRequest("All uploads failed", "Check logs for details"))
 		return
@@ -230,6 +441,56 @@ func (m *mediaApi) UploadMedia(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// uploadFile opens an upload and stores it through Store
+func (m *mediaApi) uploadFile(fileHeader *multipart.FileHeader, contentType, blobName string, keepMetadata bool) (imaging.Metadata, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return imaging.Metadata{}, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	return Store(m.blobs, file, contentType, blobName, keepMetadata)
+}
+
+// BlobUploader stores media bytes under a blob name
+type BlobUploader interface {
+	UploadBlob(r io.Reader, blobName string) error
+}
+
+// Store uploads r under blobName and returns what its EXIF said. JPEGs and PNGs lose their EXIF and XMP on the
+// way unless keepMetadata is set; ones too broken to strip fail with imaging.ErrMalformed. Every way media gets
+// into storage goes through here, so none of it keeps a photo's location by accident.
+func Store(blobs BlobUploader, r io.Reader, contentType, blobName string, keepMetadata bool) (imaging.Metadata, error) {
+	if !slices.Contains(imaging.ContentTypes, contentType) {
+		return imaging.Metadata{}, blobs.UploadBlob(r, blobName)
+	}
+
+	// Uploads are capped at MaxFileSize, so the image fits in memory
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return imaging.Metadata{}, fmt.Errorf("error reading file: %v", err)
+	}
+	metadata, err := imaging.ReadMetadata(data, contentType)
+	if err != nil {
+		return metadata, err
+	}
+	if !keepMetadata {
+		data, err = imaging.Strip(data, contentType)
+		if err != nil {
+			return metadata, err
+		}
+	}
+	return metadata, blobs.UploadBlob(bytes.NewReader(data), blobName)
+}
+
+// Record saves the reference to what Store put under blobName, along with when and on what camera it was taken
+func Record(mediaRepo media_repo.MediaRepository, postId int, blobName, contentType string, restricted bool, metadata imaging.Metadata) error {
+	var capturedAt *time.Time
+	if !metadata.TakenAt.IsZero() {
+		capturedAt = &metadata.TakenAt
+	}
+	return mediaRepo.UploadMedia(postId, blobName, contentType, restricted, capturedAt, metadata.Camera())
+}
+
 func getFileContentType(fileHeader *multipart.FileHeader) (string, error) {
 	// Open the file
 	file, err := fileHeader.Open()
@@ -256,6 +517,21 @@ func getFileContentType(fileHeader *multipart.FileHeader) (string, error) {
 	return contentType, nil
 }
 
+// FilePath is the site-relative URL GetMediaFile serves an attachment from
+func FilePath(mediaId int) string {
+	return fmt.Sprintf("/api/media/file/%d", mediaId)
+}
+
+// VariantPath is the site-relative URL GetMediaFile serves an attachment's variant from
+func VariantPath(mediaId int, name string) string {
+	return FilePath(mediaId) + "/" + name
+}
+
+// BlobName is where an upload named filename is stored for postId
+func BlobName(postId int, filename string) string {
+	return fmt.Sprintf("blog-media/%d_%s", postId, sanitizeFilename(filename))
+}
+
 func sanitizeFilename(filename string) string {
 	filename = filepath.Base(filename)
 
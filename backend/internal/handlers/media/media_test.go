@@ -0,0 +1,495 @@
+package media
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// pngHeader sniffs as a PNG but is cut off after its header
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// pngImage is a whole PNG, since image uploads are parsed to strip their metadata
+var pngImage = func() []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	return buf.Bytes()
+}()
+
+// exifJPEG is a JPEG whose EXIF names its camera model and when it was taken
+func exifJPEG(t *testing.T) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x02")
+	tiff = append(tiff, 0x01, 0x10, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 'R', '6', 0x00, 0x00)
+	tiff = append(tiff, 0x01, 0x32, 0x00, 0x02, 0x00, 0x00, 0x00, 0x14, 0x00, 0x00, 0x00, 0x26)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00)
+	tiff = append(tiff, "2024:05:31 18:04:05\x00"...)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	b := append([]byte{}, encoded[:2]...)
+	b = append(b, 0xFF, 0xE1, byte((len(app1)+2)>>8), byte(len(app1)+2))
+	b = append(b, app1...)
+	return append(b, encoded[2:]...)
+}
+
+// stubMediaRepository keeps media rows and their variants in memory; failUpload makes every insert fail
+type stubMediaRepository struct {
+	media      map[int][]media_models.Post
+	variants   map[int][]media_models.Variant
+	failUpload bool
+}
+
+func (s *stubMediaRepository) GetMediaByPostId(postId int) ([]media_models.Post, error) {
+	return s.media[postId], nil
+}
+
+func (s *stubMediaRepository) GetMediaById(mediaId int) (*media_models.Post, error) {
+	for _, media := range s.media {
+		for _, attachment := range media {
+			if attachment.MediaId == mediaId {
+				return &attachment, nil
+			}
+		}
+	}
+	return nil, v5.ErrNoRows
+}
+
+func (s *stubMediaRepository) UploadMedia(postId int, blobName, contentType string, restricted bool, capturedAt *time.Time, camera string) error {
+	if s.failUpload {
+		return errors.New("insert failed")
+	}
+	attachment := media_models.Post{PostId: postId, BlobName: blobName, ContentType: contentType, Restricted: restricted, CapturedAt: capturedAt}
+	if camera != "" {
+		attachment.Camera = &camera
+	}
+	s.media[postId] = append(s.media[postId], attachment)
+	return nil
+}
+
+func (s *stubMediaRepository) DeleteMediaByPostId(postId int) error {
+	for _, attachment := range s.media[postId] {
+		delete(s.variants, attachment.MediaId)
+	}
+	delete(s.media, postId)
+	return nil
+}
+
+func (s *stubMediaRepository) GetUnprocessedMedia(contentTypes []string, limit int) ([]media_models.Post, error) {
+	return nil, nil
+}
+
+func (s *stubMediaRepository) SaveVariants(mediaId int, variants []media_models.Variant) error {
+	s.variants[mediaId] = variants
+	return nil
+}
+
+func (s *stubMediaRepository) GetVariantsByPostId(postId int) ([]media_models.Variant, error) {
+	var variants []media_models.Variant
+	for _, attachment := range s.media[postId] {
+		variants = append(variants, s.variants[attachment.MediaId]...)
+	}
+	return variants, nil
+}
+
+func (s *stubMediaRepository) GetVariant(mediaId int, name string) (*media_models.Variant, error) {
+	for _, variant := range s.variants[mediaId] {
+		if variant.Name == name {
+			return &variant, nil
+		}
+	}
+	return nil, v5.ErrNoRows
+}
+
+// stubVariantQueue counts how often it was woken
+type stubVariantQueue struct {
+	woken int
+}
+
+func (s *stubVariantQueue) Wake() {
+	s.woken++
+}
+
+// stubPostsRepository lets viewers see every post except restricted ones, which need a privileged viewer
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	restricted map[int]bool
+}
+
+func (s *stubPostsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	return !s.restricted[postId] || viewer.Privileged, nil
+}
+
+func withUser(userId, role int, next http.HandlerFunc) http.Handler {
+	return session.Manager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Manager.Put(r.Context(), "user_id", userId)
+		session.Manager.Put(r.Context(), "user_role", role)
+		next(w, r)
+	}))
+}
+
+func uploadRequest(t *testing.T, files map[string][]byte, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("postId", "1")
+	mw.WriteField("restricted", "false")
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for name, data := range files {
+		part, err := mw.CreateFormFile("photos", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/media", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadMedia(t *testing.T) {
+	tests := []struct {
+		name           string
+		files          map[string][]byte
+		failUpload     bool
+		expectedStatus int
+		expectedBlobs  []string
+	}{
+		{name: "image", files: map[string][]byte{"photo.png": pngImage}, expectedStatus: http.StatusOK, expectedBlobs: []string{"blog-media/1_photo.png"}},
+		{name: "unsafe filename", files: map[string][]byte{"../a:b.png": pngImage}, expectedStatus: http.StatusOK, expectedBlobs: []string{"blog-media/1_a_b.png"}},
+		{name: "disallowed type", files: map[string][]byte{"notes.txt": []byte("plain text")}, expectedStatus: http.StatusBadRequest},
+		{name: "some disallowed", files: map[string][]byte{"photo.png": pngImage, "notes.txt": []byte("plain text")}, expectedStatus: http.StatusPartialContent, expectedBlobs: []string{"blog-media/1_photo.png"}},
+		{name: "unreadable image", files: map[string][]byte{"photo.png": pngHeader}, expectedStatus: http.StatusBadRequest},
+		{name: "database failure removes the blob", files: map[string][]byte{"photo.png": pngImage}, failUpload: true, expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := storage.NewMemoryStore()
+			mediaRepo := &stubMediaRepository{media: map[int][]media_models.Post{}, failUpload: tt.failUpload}
+			queue := &stubVariantQueue{}
+			mediaApi := New(mediaRepo, &stubPostsRepository{}, authorization.NewAuthService(zap.NewNop()), zap.NewNop(), blobs, queue)
+
+			rr := httptest.NewRecorder()
+			mediaApi.UploadMedia(rr, uploadRequest(t, tt.files, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			var stored []string
+			for _, attachment := range mediaRepo.media[1] {
+				stored = append(stored, attachment.BlobName)
+			}
+			assert.ElementsMatch(t, tt.expectedBlobs, stored)
+			// Only a stored upload has anything to make variants of
+			assert.Equal(t, len(tt.expectedBlobs) > 0, queue.woken > 0)
+			for _, blobName := range tt.expectedBlobs {
+				_, err := blobs.StatBlob(blobName)
+				assert.NoError(t, err)
+			}
+			if tt.failUpload {
+				_, err := blobs.StatBlob("blog-media/1_photo.png")
+				assert.ErrorIs(t, err, storage.ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestUploadMediaMetadata(t *testing.T) {
+	tests := []struct {
+		name           string
+		keepMetadata   string
+		expectedStatus int
+		expectedExif   bool
+	}{
+		{name: "stripped by default", expectedStatus: http.StatusOK},
+		{name: "stripped when asked to", keepMetadata: "false", expectedStatus: http.StatusOK},
+		{name: "kept when opted out", keepMetadata: "true", expectedStatus: http.StatusOK, expectedExif: true},
+		{name: "invalid option", keepMetadata: "maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := storage.NewMemoryStore()
+			mediaRepo := &stubMediaRepository{media: map[int][]media_models.Post{}}
+			mediaApi := New(mediaRepo, &stubPostsRepository{}, authorization.NewAuthService(zap.NewNop()), zap.NewNop(), blobs, &stubVariantQueue{})
+
+			fields := map[string]string{}
+			if tt.keepMetadata != "" {
+				fields["keepMetadata"] = tt.keepMetadata
+			}
+			rr := httptest.NewRecorder()
+			mediaApi.UploadMedia(rr, uploadRequest(t, map[string][]byte{"photo.jpg": exifJPEG(t)}, fields))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			body, err := blobs.DownloadBlob("blog-media/1_photo.jpg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(body)
+			body.Close()
+			assert.Equal(t, tt.expectedExif, bytes.Contains(data, []byte("Exif")))
+
+			// The capture details are recorded either way
+			if assert.Len(t, mediaRepo.media[1], 1) {
+				attachment := mediaRepo.media[1][0]
+				if assert.NotNil(t, attachment.CapturedAt) {
+					assert.Equal(t, time.Date(2024, 5, 31, 18, 4, 5, 0, time.UTC), *attachment.CapturedAt)
+				}
+				if assert.NotNil(t, attachment.Camera) {
+					assert.Equal(t, "R6", *attachment.Camera)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMediaByPostIdCapture(t *testing.T) {
+	session.Init()
+	capturedAt, camera := time.Date(2024, 5, 31, 18, 4, 5, 0, time.UTC), "Canon EOS R6"
+	mediaRepo := &stubMediaRepository{media: map[int][]media_models.Post{
+		1: {{MediaId: 10, PostId: 1, BlobName: "blog-media/1_photo.jpg", ContentType: "image/jpeg", CapturedAt: &capturedAt, Camera: &camera}},
+	}}
+	mediaApi := New(mediaRepo, &stubPostsRepository{}, authorization.NewAuthService(zap.NewNop()), zap.NewNop(), storage.NewMemoryStore(), &stubVariantQueue{})
+
+	for role, expectedCamera := range map[int]string{authorization.RoleAdmin: camera, authorization.RolePrivileged: ""} {
+		req := httptest.NewRequest(http.MethodGet, "/api/media/1", nil)
+		req.SetPathValue("id", "1")
+		rr := httptest.NewRecorder()
+		withUser(7, role, mediaApi.GetMediaByPostId).ServeHTTP(rr, req)
+
+		var media []struct {
+			CapturedAt *time.Time `json:"capturedAt"`
+			Camera     string     `json:"camera"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &media); err != nil {
+			t.Fatal(err)
+		}
+		if assert.Len(t, media, 1) {
+			assert.Equal(t, expectedCamera, media[0].Camera)
+			assert.Equal(t, expectedCamera != "", media[0].CapturedAt != nil)
+		}
+	}
+}
+
+func TestGetMediaByPostId(t *testing.T) {
+	session.Init()
+	tests := []struct {
+		name           string
+		postId         string
+		role           int
+		expectedStatus int
+		expectedUrls   []string
+		expectedSrcset []string
+		expectedThumbs []string
+	}{
+		{
+			name: "restricted attachment on a public post for non-privileged", postId: "1", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusOK,
+			expectedUrls:   []string{"/api/media/file/10", "/api/media/file/11"},
+			expectedSrcset: []string{"/api/media/file/10/w320 320w, /api/media/file/10/w640 640w", ""},
+			expectedThumbs: []string{"/api/media/file/10/thumb", ""},
+		},
+		{
+			name: "restricted attachment on a public post for privileged", postId: "1", role: authorization.RolePrivileged, expectedStatus: http.StatusOK,
+			expectedUrls:   []string{"/api/media/file/10", "/api/media/file/11", "/api/media/file/12"},
+			expectedSrcset: []string{"/api/media/file/10/w320 320w, /api/media/file/10/w640 640w", "", ""},
+			expectedThumbs: []string{"/api/media/file/10/thumb", "", "/api/media/file/12/thumb"},
+		},
+		{name: "restricted post for privileged", postId: "2", role: authorization.RolePrivileged, expectedStatus: http.StatusOK, expectedUrls: []string{"/api/media/file/20"}, expectedSrcset: []string{""}, expectedThumbs: []string{""}},
+		{name: "restricted post for non-privileged", postId: "2", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusUnauthorized},
+		{name: "post without media", postId: "3", role: authorization.RoleAdmin, expectedStatus: http.StatusOK, expectedUrls: []string{}, expectedSrcset: []string{}, expectedThumbs: []string{}},
+		{name: "invalid id", postId: "abc", role: authorization.RoleAdmin, expectedStatus: http.StatusBadRequest},
+	}
+
+	mediaRepo := &stubMediaRepository{
+		media: map[int][]media_models.Post{
+			1: {
+				{MediaId: 10, PostId: 1, BlobName: "blog-media/1_photo.png", ContentType: "image/png"},
+				{MediaId: 11, PostId: 1, BlobName: "blog-media/1_new.png", ContentType: "image/png"},
+				{MediaId: 12, PostId: 1, BlobName: "blog-media/1_family.png", ContentType: "image/png", Restricted: true},
+			},
+			2: {{MediaId: 20, PostId: 2, BlobName: "blog-media/2_video.mp4", ContentType: "video/mp4", Restricted: true}},
+		},
+		variants: map[int][]media_models.Variant{
+			10: {
+				{MediaId: 10, Name: "w320", BlobName: "blog-media/1_photo_w320.png", Width: 320, Height: 240},
+				{MediaId: 10, Name: "w640", BlobName: "blog-media/1_photo_w640.png", Width: 640, Height: 480},
+				{MediaId: 10, Name: "thumb", BlobName: "blog-media/1_photo_thumb.png", Width: 200, Height: 200},
+			},
+			12: {{MediaId: 12, Name: "thumb", BlobName: "blog-media/1_family_thumb.png", Width: 200, Height: 200}},
+		},
+	}
+	postsRepo := &stubPostsRepository{restricted: map[int]bool{2: true}}
+	mediaApi := New(mediaRepo, postsRepo, authorization.NewAuthService(zap.NewNop()), zap.NewNop(), storage.NewMemoryStore(), &stubVariantQueue{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/media/"+tt.postId, nil)
+			req.SetPathValue("id", tt.postId)
+			rr := httptest.NewRecorder()
+			withUser(7, tt.role, mediaApi.GetMediaByPostId).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var media []struct {
+				Url          string `json:"url"`
+				Srcset       string `json:"srcset"`
+				ThumbnailUrl string `json:"thumbnailUrl"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &media); err != nil {
+				t.Fatal(err)
+			}
+			urls, srcsets, thumbs := []string{}, []string{}, []string{}
+			for _, attachment := range media {
+				urls = append(urls, attachment.Url)
+				srcsets = append(srcsets, attachment.Srcset)
+				thumbs = append(thumbs, attachment.ThumbnailUrl)
+			}
+			assert.Equal(t, tt.expectedUrls, urls)
+			assert.Equal(t, tt.expectedSrcset, srcsets)
+			assert.Equal(t, tt.expectedThumbs, thumbs)
+		})
+	}
+}
+
+func TestGetMediaFile(t *testing.T) {
+	session.Init()
+	blobs := storage.NewMemoryStore()
+	for _, blobName := range []string{"blog-media/1_photo.png", "blog-media/2_video.mp4", "blog-media/3_photo_w320.png"} {
+		if err := blobs.UploadBlob(strings.NewReader("0123456789"), blobName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mediaRepo := &stubMediaRepository{
+		media: map[int][]media_models.Post{
+			1: {{MediaId: 10, PostId: 1, BlobName: "blog-media/1_photo.png", ContentType: "image/png"}},
+			2: {
+				{MediaId: 20, PostId: 2, BlobName: "blog-media/2_video.mp4", ContentType: "video/mp4", Restricted: true},
+				{MediaId: 21, PostId: 2, BlobName: "blog-media/2_gone.mp4", ContentType: "video/mp4", Restricted: true},
+			},
+			3: {{MediaId: 30, PostId: 3, BlobName: "blog-media/3_photo.png", ContentType: "image/png"}},
+			4: {{MediaId: 40, PostId: 4, BlobName: "blog-media/4_photo.png", ContentType: "image/png", Restricted: true}},
+		},
+		variants: map[int][]media_models.Variant{
+			10: {{MediaId: 10, Name: "w320", BlobName: "blog-media/1_photo_w320.png", ContentType: "image/png"}},
+			30: {{MediaId: 30, Name: "w320", BlobName: "blog-media/3_photo_w320.png", ContentType: "image/png"}},
+			40: {{MediaId: 40, Name: "w320", BlobName: "blog-media/3_photo_w320.png", ContentType: "image/png"}},
+		},
+	}
+	postsRepo := &stubPostsRepository{restricted: map[int]bool{3: true}}
+	mediaApi := New(mediaRepo, postsRepo, authorization.NewAuthService(zap.NewNop()), zap.NewNop(), blobs, &stubVariantQueue{})
+
+	tests := []struct {
+		name             string
+		mediaId          string
+		variant          string
+		role             int
+		rangeHeader      string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+		expectedType     string
+	}{
+		{name: "public media redirects", mediaId: "10", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusFound, expectedLocation: "memory://blog-media/1_photo.png"},
+		{name: "restricted media streams", mediaId: "20", role: authorization.RolePrivileged, expectedStatus: http.StatusOK, expectedBody: "0123456789", expectedType: "video/mp4"},
+		{name: "restricted media range", mediaId: "20", role: authorization.RolePrivileged, rangeHeader: "bytes=2-4", expectedStatus: http.StatusPartialContent, expectedBody: "234", expectedType: "video/mp4"},
+		{name: "restricted media for non-privileged", mediaId: "20", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusUnauthorized},
+		{name: "admin streams restricted media", mediaId: "20", role: authorization.RoleAdmin, expectedStatus: http.StatusOK, expectedBody: "0123456789", expectedType: "video/mp4"},
+		{name: "media of a hidden post", mediaId: "30", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusUnauthorized},
+		{name: "missing blob", mediaId: "21", role: authorization.RolePrivileged, expectedStatus: http.StatusNotFound},
+		{name: "missing media", mediaId: "99", role: authorization.RoleAdmin, expectedStatus: http.StatusNotFound},
+		{name: "invalid id", mediaId: "abc", role: authorization.RoleAdmin, expectedStatus: http.StatusBadRequest},
+		{name: "public variant redirects", mediaId: "10", variant: "w320", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusFound, expectedLocation: "memory://blog-media/1_photo_w320.png"},
+		{name: "restricted variant streams", mediaId: "40", variant: "w320", role: authorization.RolePrivileged, expectedStatus: http.StatusOK, expectedBody: "0123456789", expectedType: "image/png"},
+		{name: "variant of a hidden post", mediaId: "30", variant: "w320", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusUnauthorized},
+		{name: "missing variant", mediaId: "10", variant: "w1600", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/media/file/"+tt.mediaId, nil)
+			req.SetPathValue("mediaId", tt.mediaId)
+			req.SetPathValue("variant", tt.variant)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			rr := httptest.NewRecorder()
+			withUser(7, tt.role, mediaApi.GetMediaFile).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedLocation != "" {
+				assert.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
+			}
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+				assert.Equal(t, tt.expectedType, rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestDeleteMediaByPostId(t *testing.T) {
+	blobs := storage.NewMemoryStore()
+	for _, blobName := range []string{"blog-media/1_photo.png", "blog-media/1_photo_thumb.png", "blog-media/2_photo.png"} {
+		if err := blobs.UploadBlob(bytes.NewReader(pngHeader), blobName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mediaRepo := &stubMediaRepository{
+		media: map[int][]media_models.Post{
+			1: {{MediaId: 10, PostId: 1, BlobName: "blog-media/1_photo.png"}, {MediaId: 11, PostId: 1, BlobName: "blog-media/1_missing.png"}},
+			2: {{MediaId: 20, PostId: 2, BlobName: "blog-media/2_photo.png"}},
+		},
+		variants: map[int][]media_models.Variant{
+			10: {{MediaId: 10, Name: "thumb", BlobName: "blog-media/1_photo_thumb.png"}},
+		},
+	}
+	mediaApi := New(mediaRepo, &stubPostsRepository{}, authorization.NewAuthService(zap.NewNop()), zap.NewNop(), blobs, &stubVariantQueue{})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/media/1", nil)
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+	mediaApi.DeleteMediaByPostId(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, mediaRepo.media[1])
+	for _, blobName := range []string{"blog-media/1_photo.png", "blog-media/1_photo_thumb.png"} {
+		_, err := blobs.StatBlob(blobName)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+	body, err := blobs.DownloadBlob("blog-media/2_photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	assert.True(t, strings.HasPrefix(string(data), "\x89PNG"))
+}
@@ -0,0 +1,201 @@
+package pages
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	media_handler "github.com/KylerJacobson/blog/backend/internal/handlers/media"
+	"github.com/KylerJacobson/blog/backend/internal/httpcache"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/services/markdown"
+	"github.com/KylerJacobson/blog/backend/logger"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+const (
+	SiteName        = "kylerjacobson.dev"
+	SiteDescription = "Experiences and lessons learned navigating the tech world. Follow along at kylerjacobson.dev"
+	// DescriptionLength keeps descriptions within what link previews show before truncating
+	DescriptionLength = 200
+)
+
+var (
+	titleTag       = regexp.MustCompile(`(?s)<title>.*?</title>`)
+	descriptionTag = regexp.MustCompile(`(?s)<meta\s+name="description".*?>`)
+)
+
+type PagesApi interface {
+	GetPostPage(w http.ResponseWriter, r *http.Request)
+}
+
+type pagesApi struct {
+	postsRepository posts_repo.PostsRepository
+	mediaRepository media_repo.MediaRepository
+	auth            *authorization.AuthService
+	renderer        *markdown.Renderer
+	siteURL         string
+	indexPath       string
+	logger          logger.Logger
+}
+
+// New builds the server-rendered SPA pages; indexPath is the built index.html and siteURL the public origin
+// used for canonical links, e.g. https://kylerjacobson.dev
+func New(postsRepo posts_repo.PostsRepository, mediaRepo media_repo.MediaRepository, auth *authorization.AuthService, siteURL, indexPath string, logger logger.Logger) *pagesApi {
+	return &pagesApi{
+		postsRepository: postsRepo,
+		mediaRepository: mediaRepo,
+		auth:            auth,
+		renderer:        markdown.New(0),
+		siteURL:         strings.TrimRight(siteURL, "/"),
+		indexPath:       indexPath,
+		logger:          logger,
+	}
+}
+
+// pageMeta is what a shared link preview shows
+type pageMeta struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	Type        string
+	PublishedAt *time.Time
+	NoIndex     bool
+}
+
+// GetPostPage serves the SPA for /post/{id} with the post's Open Graph and Twitter Card tags filled in, so chat
+// apps and crawlers that don't run JavaScript still get a preview. Tags always describe the post as an anonymous
+// visitor would see it; posts that aren't public get the site's generic tags. Unknown posts get a real 404 while
+// still rendering the app so the visitor sees its not-found page.
+func (p *pagesApi) GetPostPage(w http.ResponseWriter, r *http.Request) {
+	index, err := os.ReadFile(p.indexPath)
+	if err != nil {
+		p.logger.Sugar().Errorf("error reading %s : %v", p.indexPath, err)
+		httperr.Write(w, httperr.Internal("error loading page", ""))
+		return
+	}
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		p.writePage(w, http.StatusNotFound, index)
+		return
+	}
+	post, err := p.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			p.writePage(w, http.StatusNotFound, index)
+			return
+		}
+		p.logger.Sugar().Errorf("error getting post %d for its page : %v", postId, err)
+		p.writePage(w, http.StatusInternalServerError, index)
+		return
+	}
+	if post.Status != post_models.StatusPublished && !p.auth.IsAdmin(r) {
+		p.writePage(w, http.StatusNotFound, index)
+		return
+	}
+	public, err := p.postsRepository.CanView(postId, post_models.Viewer{})
+	if err != nil {
+		p.writePage(w, http.StatusInternalServerError, index)
+		return
+	}
+
+	canonical := fmt.Sprintf("%s/post/%d", p.siteURL, post.PostId)
+	meta := pageMeta{Title: SiteName, Description: SiteDescription, URL: canonical, Type: "website", NoIndex: true}
+	if public && post.Status == post_models.StatusPublished {
+		meta = p.postMeta(post, canonical)
+	}
+	httpcache.SetCacheControl(w, p.auth.IsAuthenticated(r))
+	p.writePage(w, http.StatusOK, injectMeta(index, meta))
+}
+
+func (p *pagesApi) postMeta(post *post_models.Post, canonical string) pageMeta {
+	meta := pageMeta{
+		Title:       post.Title,
+		Description: p.renderer.Excerpt(post.Content, DescriptionLength),
+		URL:         canonical,
+		Type:        "article",
+		PublishedAt: post.PublishAt,
+	}
+	if meta.Description == "" {
+		meta.Description = SiteDescription
+	}
+	meta.Image = p.firstImage(post.PostId)
+	return meta
+}
+
+// firstImage is the media proxy URL of the post's first image attachment an anonymous visitor can load. A
+// preview without an image is better than no page at all, so failures are only logged.
+func (p *pagesApi) firstImage(postId int) string {
+	media, err := p.mediaRepository.GetMediaByPostId(postId)
+	if err != nil {
+		p.logger.Sugar().Warnf("error getting media for post %d page : %v", postId, err)
+		return ""
+	}
+	for _, attachment := range media {
+		if !strings.HasPrefix(attachment.ContentType, "image/") || attachment.Restricted {
+			continue
+		}
+		return p.siteURL + media_handler.FilePath(attachment.MediaId)
+	}
+	return ""
+}
+
+func (p *pagesApi) writePage(w http.ResponseWriter, status int, page []byte) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(page)
+}
+
+// injectMeta swaps the title and description of index for the page's own and adds the Open Graph and
+// Twitter Card tags just before </head>
+func injectMeta(index []byte, meta pageMeta) []byte {
+	page := string(index)
+	page = titleTag.ReplaceAllLiteralString(page, "<title>"+html.EscapeString(meta.Title)+"</title>")
+	page = descriptionTag.ReplaceAllLiteralString(page, "")
+
+	var tags strings.Builder
+	tag := func(attr, key, value string) {
+		if value != "" {
+			fmt.Fprintf(&tags, "<meta %s=\"%s\" content=\"%s\" />\n", attr, key, html.EscapeString(value))
+		}
+	}
+	tag("name", "description", meta.Description)
+	if meta.NoIndex {
+		tag("name", "robots", "noindex")
+	} else {
+		fmt.Fprintf(&tags, "<link rel=\"canonical\" href=\"%s\" />\n", html.EscapeString(meta.URL))
+	}
+	tag("property", "og:site_name", SiteName)
+	tag("property", "og:type", meta.Type)
+	tag("property", "og:title", meta.Title)
+	tag("property", "og:description", meta.Description)
+	tag("property", "og:url", meta.URL)
+	tag("property", "og:image", meta.Image)
+	if meta.PublishedAt != nil {
+		tag("property", "article:published_time", meta.PublishedAt.UTC().Format(time.RFC3339))
+	}
+	card := "summary"
+	if meta.Image != "" {
+		card = "summary_large_image"
+	}
+	tag("name", "twitter:card", card)
+	tag("name", "twitter:title", meta.Title)
+	tag("name", "twitter:description", meta.Description)
+	tag("name", "twitter:image", meta.Image)
+
+	if i := strings.Index(page, "</head>"); i >= 0 {
+		page = page[:i] + tags.String() + page[i:]
+	}
+	return []byte(page)
+}
@@ -0,0 +1,144 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testIndex = `<!DOCTYPE html>
+<html lang="en">
+    <head>
+        <meta
+            name="description"
+            content="site description"
+        />
+        <title>kylerjacobson.dev</title>
+    </head>
+    <body><div id="root"></div></body>
+</html>`
+
+// stubPostsRepository serves a fixed set of posts; public lists the ids an anonymous visitor may read
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	posts  map[int]post_models.Post
+	public map[int]bool
+}
+
+func (s *stubPostsRepository) GetPostById(postId int) (*post_models.Post, error) {
+	post, ok := s.posts[postId]
+	if !ok {
+		return nil, v5.ErrNoRows
+	}
+	return &post, nil
+}
+
+func (s *stubPostsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	return s.public[postId], nil
+}
+
+type stubMediaRepository struct {
+	media_repo.MediaRepository
+	media []media_models.Post
+}
+
+func (s *stubMediaRepository) GetMediaByPostId(postId int) ([]media_models.Post, error) {
+	return s.media, nil
+}
+
+func newTestApi(t *testing.T) *pagesApi {
+	indexPath := filepath.Join(t.TempDir(), "index.html")
+	assert.NoError(t, os.WriteFile(indexPath, []byte(testIndex), 0o644))
+	publishAt := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	posts := &stubPostsRepository{
+		posts: map[int]post_models.Post{
+			1: {PostId: 1, Title: `Tips & "Tricks"`, Content: "# Heading\n\nA short **intro**.", Status: post_models.StatusPublished, PublishAt: &publishAt},
+			2: {PostId: 2, Title: "Family trip", Content: "Where we went.", Restricted: true, Status: post_models.StatusPublished},
+			3: {PostId: 3, Title: "Draft", Status: post_models.StatusDraft},
+		},
+		public: map[int]bool{1: true, 3: true},
+	}
+	media := &stubMediaRepository{media: []media_models.Post{
+		{MediaId: 4, PostId: 1, BlobName: "clip.mp4", ContentType: "video/mp4"},
+		{MediaId: 5, PostId: 1, BlobName: "private.jpg", ContentType: "image/jpeg", Restricted: true},
+		{MediaId: 6, PostId: 1, BlobName: "cover.jpg", ContentType: "image/jpeg"},
+	}}
+	return New(posts, media, authorization.NewAuthService(zap.NewNop()), "https://example.dev/", indexPath, zap.NewNop())
+}
+
+func TestGetPostPage(t *testing.T) {
+	session.Init()
+	pagesApi := newTestApi(t)
+
+	tests := []struct {
+		name           string
+		postId         string
+		expectedStatus int
+		contains       []string
+		excludes       []string
+	}{
+		{
+			name:           "public_post",
+			postId:         "1",
+			expectedStatus: http.StatusOK,
+			contains: []string{
+				`<title>Tips &amp; &#34;Tricks&#34;</title>`,
+				`<meta name="description" content="A short intro." />`,
+				`<link rel="canonical" href="https://example.dev/post/1" />`,
+				`<meta property="og:type" content="article" />`,
+				`<meta property="og:title" content="Tips &amp; &#34;Tricks&#34;" />`,
+				`<meta property="og:image" content="https://example.dev/api/media/file/6" />`,
+				`<meta property="article:published_time" content="2024-05-01T08:00:00Z" />`,
+				`<meta name="twitter:card" content="summary_large_image" />`,
+				`<div id="root"></div>`,
+			},
+			excludes: []string{"site description", "noindex"},
+		},
+		{
+			name:           "restricted_post_is_generic",
+			postId:         "2",
+			expectedStatus: http.StatusOK,
+			contains: []string{
+				`<title>kylerjacobson.dev</title>`,
+				`<meta name="robots" content="noindex" />`,
+				`<meta property="og:description" content="` + SiteDescription + `" />`,
+				`<meta name="twitter:card" content="summary" />`,
+			},
+			excludes: []string{"Family trip", "Where we went", "og:image", "canonical"},
+		},
+		{name: "draft_is_not_found", postId: "3", expectedStatus: http.StatusNotFound, contains: []string{`<div id="root"></div>`}, excludes: []string{"og:title"}},
+		{name: "unknown_post", postId: "9", expectedStatus: http.StatusNotFound, contains: []string{`<div id="root"></div>`}},
+		{name: "bad_id", postId: "latest", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/post/"+tt.postId, nil)
+			req.SetPathValue("id", tt.postId)
+			rr := httptest.NewRecorder()
+			session.Manager.LoadAndSave(http.HandlerFunc(pagesApi.GetPostPage)).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+			for _, s := range tt.contains {
+				assert.Contains(t, rr.Body.String(), s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, rr.Body.String(), s)
+			}
+		})
+	}
+}
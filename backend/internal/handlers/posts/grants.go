@@ -0,0 +1,94 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+// GetPostGrants lists the groups and users a post is shared with
+func (p *postsApi) GetPostGrants(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	grants, err := p.postsRepository.GetPostGrants(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		p.logger.Sugar().Errorf("error getting grants for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting grants", ""))
+		return
+	}
+	b, err := json.Marshal(grants)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling grants for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting grants", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// SetPostGrants replaces the groups and users a post is shared with; an empty body makes the post follow its
+// restricted flag alone again
+func (p *postsApi) SetPostGrants(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	var grants post_models.PostGrants
+	err = json.NewDecoder(r.Body).Decode(&grants)
+	if err != nil {
+		p.logger.Sugar().Errorf("error decoding the grants request body: %v", err)
+		httperr.Write(w, httperr.BadRequest("invalid request body", ""))
+		return
+	}
+	grants = normalizeGrants(grants)
+	err = p.postsRepository.SetPostGrants(postId, grants)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			p.logger.Sugar().Warnf("grants for post %d name a missing post, group or user", postId)
+			httperr.Write(w, httperr.NotFound("post, group or user not found", ""))
+			return
+		}
+		p.logger.Sugar().Errorf("error setting grants for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error setting grants", ""))
+		return
+	}
+	b, err := json.Marshal(grants)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling grants for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error setting grants", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// normalizeGrants drops duplicate ids and never returns nil slices, so the response always has both lists
+func normalizeGrants(grants post_models.PostGrants) post_models.PostGrants {
+	return post_models.PostGrants{Groups: uniqueIds(grants.Groups), Users: uniqueIds(grants.Users)}
+}
+
+func uniqueIds(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
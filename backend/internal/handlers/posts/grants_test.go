@@ -0,0 +1,89 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestGetPostGrants(t *testing.T) {
+	mockRepo := new(mockPostsRepository)
+	mockRepo.On("GetPostGrants", 5).Return(&post_models.PostGrants{Groups: []int{2}, Users: []int{}}, nil)
+	postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/5/grants", nil)
+	req.SetPathValue("id", "5")
+	rr := httptest.NewRecorder()
+	postsApi.GetPostGrants(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"groups":[2],"users":[]}`, rr.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetPostGrants(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expected       post_models.PostGrants
+		repoErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "duplicates_dropped",
+			body:           `{"groups":[2,3,2],"users":[9]}`,
+			expected:       post_models.PostGrants{Groups: []int{2, 3}, Users: []int{9}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "empty_body_clears_grants",
+			body:           `{}`,
+			expected:       post_models.PostGrants{Groups: []int{}, Users: []int{}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown_group",
+			body:           `{"groups":[99]}`,
+			expected:       post_models.PostGrants{Groups: []int{99}, Users: []int{}},
+			repoErr:        pgx.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "database_error",
+			body:           `{"users":[1]}`,
+			expected:       post_models.PostGrants{Groups: []int{}, Users: []int{1}},
+			repoErr:        errors.New("boom"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			mockRepo.On("SetPostGrants", 5, tt.expected).Return(tt.repoErr)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPut, "/api/posts/5/grants", strings.NewReader(tt.body))
+			req.SetPathValue("id", "5")
+			rr := httptest.NewRecorder()
+			postsApi.SetPostGrants(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var grants post_models.PostGrants
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&grants))
+				assert.Equal(t, tt.expected, grants)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
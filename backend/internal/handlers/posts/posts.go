@@ -1,11 +1,17 @@
 package posts
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/KylerJacobson/blog/backend/internal/api/types/posts"
 	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
@@ -13,40 +19,79 @@ import (
 	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
 	users_repo "github.com/KylerJacobson/blog/backend/internal/db/users"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/httpcache"
 	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/locale"
+	"github.com/KylerJacobson/blog/backend/internal/services/markdown"
 	"github.com/KylerJacobson/blog/backend/internal/services/notifications"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/KylerJacobson/blog/backend/internal/slug"
 	"github.com/KylerJacobson/blog/backend/logger"
 	v5 "github.com/jackc/pgx/v5"
 )
 
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 50
+)
+
 type PostsApi interface {
+	GetPosts(w http.ResponseWriter, r *http.Request)
 	GetRecentPosts(w http.ResponseWriter, r *http.Request)
 	GetRecentPublicPosts(w http.ResponseWriter, r *http.Request)
 	GetPostById(w http.ResponseWriter, r *http.Request)
+	GetPostBySlug(w http.ResponseWriter, r *http.Request)
+	SearchPosts(w http.ResponseWriter, r *http.Request)
 	DeletePostById(w http.ResponseWriter, r *http.Request)
+	GetTrash(w http.ResponseWriter, r *http.Request)
+	RestorePost(w http.ResponseWriter, r *http.Request)
+	PurgePost(w http.ResponseWriter, r *http.Request)
 	CreatePost(w http.ResponseWriter, r *http.Request)
+	UpdatePost(w http.ResponseWriter, r *http.Request)
+	GetTags(w http.ResponseWriter, r *http.Request)
+	GetPostsByTag(w http.ResponseWriter, r *http.Request)
+	GetRevisions(w http.ResponseWriter, r *http.Request)
+	GetRevision(w http.ResponseWriter, r *http.Request)
+	DiffRevisions(w http.ResponseWriter, r *http.Request)
+	RestoreRevision(w http.ResponseWriter, r *http.Request)
+	GetPostGrants(w http.ResponseWriter, r *http.Request)
+	SetPostGrants(w http.ResponseWriter, r *http.Request)
+	GetTranslations(w http.ResponseWriter, r *http.Request)
+	SetTranslation(w http.ResponseWriter, r *http.Request)
+	DeleteTranslation(w http.ResponseWriter, r *http.Request)
+}
+
+// Federator delivers newly published posts to the blog's fediverse followers; it skips posts that aren't public
+type Federator interface {
+	FederatePost(postId int)
 }
 
 type postsApi struct {
 	postsRepository posts_repo.PostsRepository
 	usersRepository users_repo.UsersRepository
 	notifier        *notifications.Notifier
+	federator       Federator
+	blobs           storage.BlobStore
 	auth            *authorization.AuthService
+	renderer        *markdown.Renderer
 	logger          logger.Logger
 }
 
-func New(postsRepo posts_repo.PostsRepository, usersRepo users_repo.UsersRepository, notifier *notifications.Notifier, auth *authorization.AuthService, logger logger.Logger) *postsApi {
+func New(postsRepo posts_repo.PostsRepository, usersRepo users_repo.UsersRepository, notifier *notifications.Notifier, federator Federator, blobs storage.BlobStore, auth *authorization.AuthService, logger logger.Logger) *postsApi {
 	return &postsApi{
 		postsRepository: postsRepo,
 		usersRepository: usersRepo,
 		notifier:        notifier,
+		federator:       federator,
+		blobs:           blobs,
 		auth:            auth,
+		renderer:        markdown.New(markdown.DefaultCacheSize),
 		logger:          logger,
 	}
 }
 
 func (p *postsApi) GetRecentPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := p.postsRepository.GetRecentPosts()
+	posts, err := p.postsRepository.GetPosts(post_models.PostFilter{Limit: DefaultPageSize, Viewer: p.auth.Viewer(r), Locales: locale.Preferences(r)})
 	if err != nil {
 		p.logger.Sugar().Errorf("error getting all recent posts : %v", err)
 		httperr.Write(w, httperr.Internal("error getting all recent posts", ""))
@@ -64,28 +109,54 @@ func (p *postsApi) GetRecentPosts(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p *postsApi) GetPosts(w http.ResponseWriter, r *http.Request) {
-	var posts []post_models.Post
-	var err error
+	filter, err := parsePostFilter(r.URL.Query())
+	if err != nil {
+		p.logger.Sugar().Warnf("invalid post listing parameters: %v", err)
+		httperr.Write(w, httperr.BadRequest("invalid query parameters", err.Error()))
+		return
+	}
+	p.writePostPage(w, r, filter)
+}
 
-	privileged := p.auth.CheckPrivilege(r)
-	if privileged {
-		posts, err = p.postsRepository.GetRecentPosts()
-		if err != nil {
-			p.logger.Sugar().Errorf("error getting all recent posts : %v", err)
-			httperr.Write(w, httperr.Internal("error getting all recent posts", ""))
-			return
-		}
-	} else {
-		posts, err = p.postsRepository.GetRecentPublicPosts()
-		if err != nil {
-			p.logger.Sugar().Errorf("error getting all recent public posts : %v", err)
-			httperr.Write(w, httperr.Internal("error getting all recent public posts", ""))
-			return
-		}
+// writePostPage applies the caller's visibility to filter and writes one page of posts with its next cursor
+func (p *postsApi) writePostPage(w http.ResponseWriter, r *http.Request, filter post_models.PostFilter) {
+	filter.Viewer = p.auth.Viewer(r)
+	filter.IncludeUnpublished = filter.Viewer.Admin
+	filter.Locales = locale.Preferences(r)
+
+	// Ask for one extra row so we know whether another page exists
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1
+	posts, err := p.postsRepository.GetPosts(filter)
+	if err != nil {
+		p.logger.Sugar().Errorf("error getting posts : %v", err)
+		httperr.Write(w, httperr.Internal("error getting posts", ""))
+		return
 	}
-	b, err := json.Marshal(posts)
+
+	page := post_models.PostPage{Posts: posts}
+	if len(posts) > pageSize {
+		page.Posts = posts[:pageSize]
+		last := page.Posts[pageSize-1]
+		page.NextCursor = encodeCursor(post_models.PostCursor{CreatedAt: last.CreatedAt, PostId: last.PostId})
+	}
+	if page.Posts == nil {
+		page.Posts = []post_models.Post{}
+	}
+
+	// Lists only get an ETag: a post leaving the list wouldn't move any Last-Modified we could compute
+	parts := []any{filter.Viewer, filter.IncludeUnpublished, page.NextCursor}
+	for _, post := range page.Posts {
+		parts = append(parts, post.PostId, post.Version, post.Locale)
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	httpcache.SetCacheControl(w, p.auth.IsAuthenticated(r))
+	if httpcache.NotModified(w, r, httpcache.ETag(parts...), time.Time{}) {
+		return
+	}
+	b, err := json.Marshal(page)
 	if err != nil {
-		p.logger.Sugar().Errorf("error unmarshalling recent public posts : %v", err)
+		p.logger.Sugar().Errorf("error marshalling posts page : %v", err)
 		httperr.Write(w, httperr.Internal("error getting posts", ""))
 		return
 	}
@@ -127,6 +198,30 @@ func (p *postsApi) GetPostById(w http.ResponseWriter, r *http.Request) {
 		httperr.Write(w, httperr.Internal("error getting post by id", ""))
 		return
 	}
+	if post.Status != post_models.StatusPublished && !p.auth.IsAdmin(r) {
+		p.logger.Sugar().Warnf("post %d is %s and hidden from non-admins", val, post.Status)
+		httperr.Write(w, httperr.NotFound("post not found", ""))
+		return
+	}
+	if !p.checkAccess(w, r, post.PostId) {
+		return
+	}
+	err = p.translate(w, r, post)
+	if err != nil {
+		p.logger.Sugar().Errorf("error translating post %d : %v", val, err)
+		httperr.Write(w, httperr.Internal("error getting post by id", ""))
+		return
+	}
+	w.Header().Set("Content-Language", post.Locale)
+	if p.postNotModified(w, r, post) {
+		return
+	}
+	err = p.renderContent(r, post)
+	if err != nil {
+		p.logger.Sugar().Errorf("error rendering post %d : %v", val, err)
+		httperr.Write(w, httperr.Internal("error rendering post", ""))
+		return
+	}
 	b, err := json.Marshal(post)
 	if err != nil {
 		p.logger.Sugar().Errorf("error unmarshalling post %d - %v", id, err)
@@ -137,6 +232,132 @@ func (p *postsApi) GetPostById(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+func (p *postsApi) GetPostBySlug(w http.ResponseWriter, r *http.Request) {
+	postSlug := r.PathValue("slug")
+	post, err := p.postsRepository.GetPostBySlug(postSlug)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			p.logger.Sugar().Warnf("post with slug %s does not exist in the database", postSlug)
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error getting post by slug", ""))
+		return
+	}
+	if post.Status != post_models.StatusPublished && !p.auth.IsAdmin(r) {
+		httperr.Write(w, httperr.NotFound("post not found", ""))
+		return
+	}
+	if !p.checkAccess(w, r, post.PostId) {
+		return
+	}
+	// A former slug resolved through the redirect table, point the caller at the permalink
+	if post.Slug != postSlug {
+		target := "/api/slugs/" + url.PathEscape(post.Slug)
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+	// The slug of a translation already picked the language
+	w.Header().Set("Content-Language", post.Locale)
+	if p.postNotModified(w, r, post) {
+		return
+	}
+	err = p.renderContent(r, post)
+	if err != nil {
+		p.logger.Sugar().Errorf("error rendering post %s : %v", postSlug, err)
+		httperr.Write(w, httperr.Internal("error rendering post", ""))
+		return
+	}
+	b, err := json.Marshal(post)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling post %s - %v", postSlug, err)
+		httperr.Write(w, httperr.Internal("error getting post by slug", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// translate swaps in the translation of post the request prefers by ?lang= or Accept-Language, unless it asks for
+// the post as written with ?translate=false
+func (p *postsApi) translate(w http.ResponseWriter, r *http.Request, post *post_models.Post) error {
+	w.Header().Add("Vary", "Accept-Language")
+	if translate, err := strconv.ParseBool(r.URL.Query().Get("translate")); err == nil && !translate {
+		return nil
+	}
+	chosen := locale.Choose(locale.Preferences(r), post.Locales, post.Locale)
+	if chosen == post.Locale {
+		return nil
+	}
+	translation, err := p.postsRepository.GetTranslation(post.PostId, chosen)
+	if err != nil {
+		return err
+	}
+	post.Title = translation.Title
+	post.Content = translation.Content
+	post.Slug = translation.Slug
+	post.Locale = translation.Locale
+	return nil
+}
+
+// renderContent fills in the post's HTML and table of contents when the request asks for ?render=true.
+// Output is cached per saved version and language of the post; every save moves updated_at.
+func (p *postsApi) renderContent(r *http.Request, post *post_models.Post) error {
+	render, _ := strconv.ParseBool(r.URL.Query().Get("render"))
+	if !render {
+		return nil
+	}
+	rendered, err := p.renderer.RenderCached(fmt.Sprintf("%d@%d/%s", post.PostId, post.UpdatedAt.UnixNano(), post.Locale), post.Content)
+	if err != nil {
+		return err
+	}
+	post.ContentHTML = rendered.HTML
+	post.TOC = make([]post_models.Heading, len(rendered.TOC))
+	for i, heading := range rendered.TOC {
+		post.TOC[i] = post_models.Heading{Level: heading.Level, Text: heading.Text, Id: heading.Id}
+	}
+	return nil
+}
+
+func (p *postsApi) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		httperr.Write(w, httperr.BadRequest("q must not be empty", ""))
+		return
+	}
+	limit := DefaultPageSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		val, err := strconv.Atoi(l)
+		if err != nil || val < 1 {
+			httperr.Write(w, httperr.BadRequest("limit must be a positive integer", ""))
+			return
+		}
+		limit = min(val, MaxPageSize)
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	results, err := p.postsRepository.SearchPosts(query, locale.Preferences(r), p.auth.Viewer(r), limit)
+	if err != nil {
+		p.logger.Sugar().Errorf("error searching posts for %q : %v", query, err)
+		httperr.Write(w, httperr.Internal("error searching posts", ""))
+		return
+	}
+	if results == nil {
+		results = []post_models.SearchResult{}
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling search results : %v", err)
+		httperr.Write(w, httperr.Internal("error searching posts", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
 func (p *postsApi) DeletePostById(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	val, err := strconv.Atoi(id)
@@ -158,18 +379,48 @@ func (p *postsApi) DeletePostById(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (p *postsApi) NotifyOnNewPost(post posts.PostRequestBody) error {
+// NotifyOnNewPost emails every subscriber who is allowed to read the post, in their own language when the post
+// has been translated into it, and federates it when it is public
+func (p *postsApi) NotifyOnNewPost(postId int, post posts.PostRequestBody) error {
+	if p.federator != nil {
+		p.federator.FederatePost(postId)
+	}
 	users, err := p.usersRepository.GetAllUsersWithEmailNotification()
 	if err != nil {
 		p.logger.Sugar().Errorf("error getting all users with email notification: %v", err)
 		return err
 	}
+	translations, err := p.postsRepository.GetTranslations(postId)
+	if err != nil {
+		return err
+	}
+	translated := make(map[string]post_models.PostTranslation, len(translations))
+	for _, translation := range translations {
+		translated[translation.Locale] = translation
+	}
+	available := slices.Collect(maps.Keys(translated))
 	for _, user := range users {
-		if post.Restricted && user.Role != 2 {
+		userId, err := strconv.Atoi(user.Id)
+		if err != nil {
+			p.logger.Sugar().Errorf("error converting user id %s to int: %v", user.Id, err)
 			continue
 		}
+		visible, err := p.postsRepository.CanView(postId, authorization.NewViewer(userId, user.Role))
+		if err != nil {
+			return err
+		}
+		if !visible {
+			continue
+		}
+		localized := post
+		if translation, ok := translated[locale.Choose(locale.For(user.Locale), available, "")]; ok {
+			localized.Title = translation.Title
+			localized.Content = translation.Content
+			localized.Slug = translation.Slug
+			localized.Locale = translation.Locale
+		}
 		p.logger.Sugar().Infof("notifying user %s of new post", user.Email)
-		err := p.notifier.NewPost(user, post)
+		err = p.notifier.NewPost(user, localized)
 		if err != nil {
 			p.logger.Sugar().Errorf("error notifying user %s of new post: %v", user.Email, err)
 			return err
@@ -189,10 +440,18 @@ func (p *postsApi) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if post.Status == "" {
+		post.Status = post_models.StatusPublished
+	}
+	post.Tags = NormalizeTags(post.Tags)
+	post.Locale = normalizeLocale(post.Locale)
+	if post.Grants != nil {
+		*post.Grants = normalizeGrants(*post.Grants)
+	}
 	err = validatePost(post.PostRequestBody)
 	if err != nil {
 		p.logger.Sugar().Errorf("the post was not formatter correctly: %v", err)
-		httperr.Write(w, httperr.BadRequest("post was not formatted correctly", ""))
+		httperr.Write(w, httperr.BadRequest("post was not formatted correctly", err.Error()))
 		return
 	}
 
@@ -203,11 +462,14 @@ func (p *postsApi) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = p.NotifyOnNewPost(post.PostRequestBody)
-	if err != nil {
-		p.logger.Sugar().Errorf("error notifying users of new post (%s) : %v", post.Title, err)
-		httperr.Write(w, httperr.Internal("error notifying users of new post", ""))
-		return
+	// Drafts stay quiet and scheduled posts are announced by the publisher once they go live
+	if post.Status == post_models.StatusPublished {
+		err = p.NotifyOnNewPost(postId, post.PostRequestBody)
+		if err != nil {
+			p.logger.Sugar().Errorf("error notifying users of new post (%s) : %v", post.Title, err)
+			httperr.Write(w, httperr.Internal("error notifying users of new post", ""))
+			return
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -225,36 +487,153 @@ func (p *postsApi) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
 		return
 	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		httperr.Write(w, httperr.PreconditionRequired("If-Match header is required", "send the ETag from GET /api/posts/{id}"))
+		return
+	}
+	version, ok := ifMatchVersion(ifMatch)
+	if !ok {
+		p.writeVersionConflict(w, postId)
+		return
+	}
 	err = json.NewDecoder(r.Body).Decode(&post)
 	if err != nil {
 		p.logger.Sugar().Errorf("error decoding the post request body: %v", err)
 		httperr.Write(w, httperr.Internal("error decoding post request body", ""))
 		return
 	}
+	existingPost, err := p.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error updating post", ""))
+		return
+	}
+	// The edit form doesn't send the status, so leaving it out keeps a draft a draft rather than publishing it
+	if post.Status == "" {
+		post.Status = existingPost.Status
+	}
+	if post.PublishAt == nil {
+		post.PublishAt = existingPost.PublishAt
+	}
+	post.Tags = NormalizeTags(post.Tags)
+	post.Locale = normalizeLocale(post.Locale)
+	if post.Grants != nil {
+		*post.Grants = normalizeGrants(*post.Grants)
+	}
 	err = validatePost(post.PostRequestBody)
 	if err != nil {
 		p.logger.Sugar().Errorf("the post was not formatter correctly: %v", err)
-		httperr.Write(w, httperr.BadRequest("post was not formatted correctly", ""))
+		httperr.Write(w, httperr.BadRequest("post was not formatted correctly", err.Error()))
 		return
 	}
-	updatedPost, err := p.postsRepository.UpdatePost(post.PostRequestBody, postId, userID)
+	if post.Locale != "" && post.Locale != existingPost.Locale && slices.Contains(existingPost.Locales, post.Locale) {
+		httperr.Write(w, httperr.Conflict("post already has a translation into "+post.Locale, "delete the translation before changing the post's locale"))
+		return
+	}
+	updatedPost, err := p.postsRepository.UpdatePost(post.PostRequestBody, postId, userID, version)
 	if err != nil {
+		if errors.Is(err, posts_repo.ErrVersionConflict) {
+			p.writeVersionConflict(w, postId)
+			return
+		}
 		p.logger.Sugar().Errorf("error updating post (%s) : %v", post.Title, err)
 		httperr.Write(w, httperr.Internal("error updating post", ""))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	if existingPost.Status != post_models.StatusPublished && updatedPost.Status == post_models.StatusPublished {
+		err = p.NotifyOnNewPost(postId, *updatedPost)
+		if err != nil {
+			p.logger.Sugar().Errorf("error notifying users of new post (%s) : %v", post.Title, err)
+			httperr.Write(w, httperr.Internal("error notifying users of new post", ""))
+			return
+		}
+	}
 	b, err := json.Marshal(updatedPost)
 	if err != nil {
 		p.logger.Sugar().Errorf("error unmarshalling updated post (%s) : %v", post.Title, err)
 		httperr.Write(w, httperr.Internal("error updating post", ""))
 		return
 	}
+	w.Header().Set("ETag", postETag(updatedPost.Version, updatedPost.Locale))
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 
 }
 
+// checkAccess applies the post ACL for the caller, writing the error response itself when access is denied
+func (p *postsApi) checkAccess(w http.ResponseWriter, r *http.Request, postId int) bool {
+	viewer := p.auth.Viewer(r)
+	if viewer.Admin {
+		return true
+	}
+	visible, err := p.postsRepository.CanView(postId, viewer)
+	if err != nil {
+		httperr.Write(w, httperr.Internal("error getting post", ""))
+		return false
+	}
+	if !visible {
+		p.logger.Sugar().Warnf("user %d is not allowed to read post %d", viewer.UserId, postId)
+		httperr.Write(w, httperr.Forbidden("insufficient privileges", ""))
+		return false
+	}
+	return true
+}
+
+// postNotModified sets the caching headers for a single post and writes a 304 when the client's copy is current.
+// Call it after translate so the ETag names the language being served. PUT takes the same ETag in If-Match.
+func (p *postsApi) postNotModified(w http.ResponseWriter, r *http.Request, post *post_models.Post) bool {
+	lastModified := post.UpdatedAt
+	if post.CreatedAt.After(lastModified) {
+		lastModified = post.CreatedAt
+	}
+	httpcache.SetCacheControl(w, p.auth.IsAuthenticated(r))
+	return httpcache.NotModified(w, r, postETag(post.Version, post.Locale), lastModified)
+}
+
+// postETag is the entity tag for a post at version served in locale. The same version reads differently in each
+// language, so a copy cached for one language must never be revalidated for another.
+func postETag(version int, locale string) string {
+	return `"` + strconv.Itoa(version) + "-" + locale + `"`
+}
+
+// ifMatchVersion reads the post version out of an If-Match header. Any language's ETag names the version, since
+// an update edits the post as written. "*" carries no version, so like weak tags and anything that isn't one of
+// our tags it can't match: an update always says which version it was made against.
+func ifMatchVersion(header string) (int, bool) {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if len(candidate) < 2 || candidate[0] != '"' || candidate[len(candidate)-1] != '"' {
+			continue
+		}
+		tag, _, _ := strings.Cut(candidate[1:len(candidate)-1], "-")
+		version, err := strconv.Atoi(tag)
+		if err == nil && version > 0 {
+			return version, true
+		}
+	}
+	return 0, false
+}
+
+// writeVersionConflict answers a stale If-Match with 412, pointing the client at the version it should merge with
+func (p *postsApi) writeVersionConflict(w http.ResponseWriter, postId int) {
+	current, err := p.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error updating post", ""))
+		return
+	}
+	p.logger.Sugar().Warnf("rejected stale update of post %d, current version is %d", postId, current.Version)
+	w.Header().Set("ETag", postETag(current.Version, current.Locale))
+	httperr.Write(w, httperr.PreconditionFailed("post was modified by someone else", fmt.Sprintf("current version is %d", current.Version)))
+}
+
 func validatePost(post post_models.PostRequestBody) error {
 	if len(post.Title) < 1 {
 		return fmt.Errorf("post title must not be empty")
@@ -262,5 +641,115 @@ func validatePost(post post_models.PostRequestBody) error {
 	if len(post.Content) < 1 {
 		return fmt.Errorf("post content must not be empty")
 	}
+	if post.Slug != "" && slug.Make(post.Slug) == "" {
+		return fmt.Errorf("post slug must contain letters or digits")
+	}
+	if len(post.Tags) > MaxTagsPerPost {
+		return fmt.Errorf("posts can have at most %d tags", MaxTagsPerPost)
+	}
+	for _, tag := range post.Tags {
+		if len(tag) > MaxTagLength {
+			return fmt.Errorf("tags must be at most %d characters", MaxTagLength)
+		}
+	}
+	if !validStatus(post.Status) {
+		return fmt.Errorf("post status must be one of draft, scheduled or published")
+	}
+	if post.Locale != "" {
+		if _, err := locale.Parse(post.Locale); err != nil {
+			return err
+		}
+	}
+	if post.Status == post_models.StatusScheduled && (post.PublishAt == nil || !post.PublishAt.After(time.Now())) {
+		return fmt.Errorf("scheduled posts need a publish_at in the future")
+	}
 	return nil
 }
+
+func validStatus(status string) bool {
+	switch status {
+	case post_models.StatusDraft, post_models.StatusScheduled, post_models.StatusPublished:
+		return true
+	}
+	return false
+}
+
+// parsePostFilter reads limit, cursor, from, to and restricted from the query string.
+// Dates may be RFC 3339 timestamps or plain YYYY-MM-DD dates; a plain "to" date includes that whole day.
+func parsePostFilter(query url.Values) (post_models.PostFilter, error) {
+	filter := post_models.PostFilter{Limit: DefaultPageSize}
+
+	if limit := query.Get("limit"); limit != "" {
+		val, err := strconv.Atoi(limit)
+		if err != nil || val < 1 {
+			return filter, fmt.Errorf("limit must be a positive integer")
+		}
+		filter.Limit = min(val, MaxPageSize)
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+	}
+	if from := query.Get("from"); from != "" {
+		t, _, err := parseDate(from)
+		if err != nil {
+			return filter, fmt.Errorf("from must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		}
+		filter.From = &t
+	}
+	if to := query.Get("to"); to != "" {
+		t, dateOnly, err := parseDate(to)
+		if err != nil {
+			return filter, fmt.Errorf("to must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+	if restricted := query.Get("restricted"); restricted != "" {
+		val, err := strconv.ParseBool(restricted)
+		if err != nil {
+			return filter, fmt.Errorf("restricted must be a boolean")
+		}
+		filter.Restricted = &val
+	}
+	if status := query.Get("status"); status != "" {
+		if !validStatus(status) {
+			return filter, fmt.Errorf("status must be one of draft, scheduled or published")
+		}
+		filter.Status = status
+	}
+	if tag := query.Get("tag"); tag != "" {
+		filter.Tag = normalizeTag(tag)
+	}
+	return filter, nil
+}
+
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func encodeCursor(cursor post_models.PostCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(value string) (*post_models.PostCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+	var cursor post_models.PostCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.PostId == 0 {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,742 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockPostsRepository struct {
+	mock.Mock
+}
+
+func (m *mockPostsRepository) GetRecentPublicPosts() ([]post_models.Post, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) GetPosts(filter post_models.PostFilter) ([]post_models.Post, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]post_models.Post), args.Error(1)
+}
+
+func (m *mockPostsRepository) GetPostById(postId int) (*post_models.Post, error) {
+	args := m.Called(postId)
+	post, _ := args.Get(0).(*post_models.Post)
+	return post, args.Error(1)
+}
+
+func (m *mockPostsRepository) GetPostBySlug(slug string) (*post_models.Post, error) {
+	args := m.Called(slug)
+	post, _ := args.Get(0).(*post_models.Post)
+	return post, args.Error(1)
+}
+
+func (m *mockPostsRepository) SearchPosts(query string, locales []string, viewer post_models.Viewer, limit int) ([]post_models.SearchResult, error) {
+	args := m.Called(query, locales, viewer, limit)
+	return args.Get(0).([]post_models.SearchResult), args.Error(1)
+}
+
+func (m *mockPostsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	args := m.Called(postId, viewer)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockPostsRepository) GetPostGrants(postId int) (*post_models.PostGrants, error) {
+	args := m.Called(postId)
+	grants, _ := args.Get(0).(*post_models.PostGrants)
+	return grants, args.Error(1)
+}
+
+func (m *mockPostsRepository) SetPostGrants(postId int, grants post_models.PostGrants) error {
+	args := m.Called(postId, grants)
+	return args.Error(0)
+}
+
+func (m *mockPostsRepository) DeletePostById(postId int) error {
+	args := m.Called(postId)
+	return args.Error(0)
+}
+
+func (m *mockPostsRepository) GetTrashedPosts() ([]post_models.Post, error) {
+	args := m.Called()
+	return args.Get(0).([]post_models.Post), args.Error(1)
+}
+
+func (m *mockPostsRepository) RestorePost(postId int) error {
+	args := m.Called(postId)
+	return args.Error(0)
+}
+
+func (m *mockPostsRepository) PurgePost(postId int) ([]string, error) {
+	args := m.Called(postId)
+	blobNames, _ := args.Get(0).([]string)
+	return blobNames, args.Error(1)
+}
+
+func (m *mockPostsRepository) PurgeTrashedBefore(cutoff time.Time) (int, []string, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) CreatePost(post post_models.PostRequestBody, userId int) (int, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) ImportPost(post post_models.PostRequestBody, userId int, createdAt time.Time) (int, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) UpdatePost(post post_models.PostRequestBody, postId, userId, version int) (*post_models.PostRequestBody, error) {
+	args := m.Called(post, postId, userId, version)
+	updated, _ := args.Get(0).(*post_models.PostRequestBody)
+	return updated, args.Error(1)
+}
+
+func (m *mockPostsRepository) ReimportPost(post post_models.PostRequestBody, postId, userId, version int, createdAt time.Time) (*post_models.PostRequestBody, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) PublishDuePosts(now time.Time) ([]post_models.Post, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) GetTranslations(postId int) ([]post_models.PostTranslation, error) {
+	args := m.Called(postId)
+	return args.Get(0).([]post_models.PostTranslation), args.Error(1)
+}
+
+func (m *mockPostsRepository) GetTranslation(postId int, locale string) (*post_models.PostTranslation, error) {
+	args := m.Called(postId, locale)
+	translation, _ := args.Get(0).(*post_models.PostTranslation)
+	return translation, args.Error(1)
+}
+
+func (m *mockPostsRepository) SetTranslation(translation post_models.PostTranslation) (*post_models.PostTranslation, error) {
+	args := m.Called(translation)
+	saved, _ := args.Get(0).(*post_models.PostTranslation)
+	return saved, args.Error(1)
+}
+
+func (m *mockPostsRepository) DeleteTranslation(postId int, locale string) error {
+	args := m.Called(postId, locale)
+	return args.Error(0)
+}
+
+func (m *mockPostsRepository) GetRevisions(postId int) ([]post_models.PostRevision, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) GetRevision(postId, revisionId int) (*post_models.PostRevision, error) {
+	args := m.Called(postId, revisionId)
+	revision, _ := args.Get(0).(*post_models.PostRevision)
+	return revision, args.Error(1)
+}
+
+func (m *mockPostsRepository) GetTags(viewer post_models.Viewer, includeUnpublished bool) ([]post_models.Tag, error) {
+	args := m.Called(viewer, includeUnpublished)
+	return args.Get(0).([]post_models.Tag), args.Error(1)
+}
+
+func (m *mockPostsRepository) CountPublicPosts() (int, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockPostsRepository) GetPublicPostSummaries(offset, limit int) ([]post_models.PostSummary, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+// withRole runs the handler inside a session carrying the given user role
+func withRole(role int, next http.HandlerFunc) http.Handler {
+	return session.Manager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Manager.Put(r.Context(), "user_role", role)
+		next(w, r)
+	}))
+}
+
+func makePosts(n int) []post_models.Post {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	posts := make([]post_models.Post, n)
+	for i := range posts {
+		posts[i] = post_models.Post{PostId: 100 - i, Title: "post", CreatedAt: start.Add(-time.Duration(i) * time.Hour)}
+	}
+	return posts
+}
+
+func TestGetPosts(t *testing.T) {
+	session.Init()
+	cursor := post_models.PostCursor{CreatedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), PostId: 42}
+
+	tests := []struct {
+		name               string
+		query              string
+		role               int
+		setupMock          func(*mockPostsRepository)
+		expectedStatus     int
+		expectedPosts      int
+		expectedNextCursor bool
+	}{
+		{
+			name: "public_first_page_has_next_cursor",
+			role: authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPosts", post_models.PostFilter{Limit: DefaultPageSize + 1}).Return(makePosts(DefaultPageSize+1), nil)
+			},
+			expectedStatus:     http.StatusOK,
+			expectedPosts:      DefaultPageSize,
+			expectedNextCursor: true,
+		},
+		{
+			name:  "privileged_last_page_has_no_cursor",
+			query: "?limit=5&cursor=" + encodeCursor(cursor),
+			role:  authorization.RolePrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPosts", post_models.PostFilter{Limit: 6, After: &cursor, Viewer: post_models.Viewer{Privileged: true}}).Return(makePosts(3), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPosts:  3,
+		},
+		{
+			name:  "limit_is_capped",
+			query: "?limit=1000&restricted=true",
+			role:  authorization.RoleAdmin,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPosts", mock.MatchedBy(func(f post_models.PostFilter) bool {
+					return f.Limit == MaxPageSize+1 && f.Viewer.Admin && f.IncludeUnpublished && f.Restricted != nil && *f.Restricted
+				})).Return([]post_models.Post{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPosts:  0,
+		},
+		{
+			name:  "date_range",
+			query: "?from=2024-01-01&to=2024-01-31",
+			role:  authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPosts", mock.MatchedBy(func(f post_models.PostFilter) bool {
+					return f.Viewer == post_models.Viewer{} &&
+						f.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+						f.To.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+				})).Return(makePosts(2), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedPosts:  2,
+		},
+		{
+			name:           "malformed_cursor",
+			query:          "?cursor=not-a-cursor",
+			role:           authorization.RoleNonPrivileged,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "bad_limit",
+			query:          "?limit=0",
+			role:           authorization.RoleNonPrivileged,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			withRole(tt.role, postsApi.GetPosts).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, "Status code mismatch for test: %s", tt.name)
+			if tt.expectedStatus == http.StatusOK {
+				var page post_models.PostPage
+				err := json.NewDecoder(rr.Body).Decode(&page)
+				assert.NoError(t, err)
+				assert.Len(t, page.Posts, tt.expectedPosts)
+				assert.Equal(t, tt.expectedNextCursor, page.NextCursor != "")
+				if tt.expectedNextCursor {
+					next, err := decodeCursor(page.NextCursor)
+					assert.NoError(t, err)
+					assert.Equal(t, page.Posts[len(page.Posts)-1].PostId, next.PostId)
+				}
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchPosts(t *testing.T) {
+	session.Init()
+
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		role           int
+		setupMock      func(*mockPostsRepository)
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:  "anonymous_search_excludes_restricted",
+			query: "?q=golang+generics",
+			role:  authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("SearchPosts", "golang generics", []string(nil), post_models.Viewer{}, DefaultPageSize).Return([]post_models.SearchResult{
+					{Post: post_models.Post{PostId: 1, Title: "Go generics"}, Snippet: "<mark>generics</mark>"},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:  "privileged_search_includes_restricted",
+			query: "?q=family&limit=3",
+			role:  authorization.RolePrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("SearchPosts", "family", []string(nil), post_models.Viewer{Privileged: true}, 3).Return([]post_models.SearchResult{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  0,
+		},
+		{
+			name:           "search_prefers_the_readers_language",
+			query:          "?q=familia",
+			acceptLanguage: "es-MX,en;q=0.5",
+			role:           authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("SearchPosts", "familia", []string{"es-MX", "es", "en"}, post_models.Viewer{}, DefaultPageSize).Return([]post_models.SearchResult{
+					{Post: post_models.Post{PostId: 1, Title: "Familia", Locale: "es"}},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:           "empty_query",
+			query:          "?q=++",
+			role:           authorization.RoleAdmin,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts/search"+tt.query, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rr := httptest.NewRecorder()
+			withRole(tt.role, postsApi.SearchPosts).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, "Status code mismatch for test: %s", tt.name)
+			if tt.expectedStatus == http.StatusOK {
+				var results []post_models.SearchResult
+				err := json.NewDecoder(rr.Body).Decode(&results)
+				assert.NoError(t, err)
+				assert.Len(t, results, tt.expectedCount)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetPostBySlug(t *testing.T) {
+	session.Init()
+
+	tests := []struct {
+		name             string
+		slug             string
+		role             int
+		setupMock        func(*mockPostsRepository)
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name: "current_slug",
+			slug: "hello-world",
+			role: authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "hello-world").Return(&post_models.Post{PostId: 1, Slug: "hello-world", Status: post_models.StatusPublished}, nil)
+				m.On("CanView", 1, post_models.Viewer{}).Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "former_slug_redirects",
+			slug: "hello",
+			role: authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "hello").Return(&post_models.Post{PostId: 1, Slug: "hello-world", Status: post_models.StatusPublished}, nil)
+				m.On("CanView", 1, post_models.Viewer{}).Return(true, nil)
+			},
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/api/slugs/hello-world",
+		},
+		{
+			name: "restricted_post_needs_privilege",
+			slug: "family-trip",
+			role: authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "family-trip").Return(&post_models.Post{PostId: 2, Slug: "family-trip", Restricted: true, Status: post_models.StatusPublished}, nil)
+				m.On("CanView", 2, post_models.Viewer{}).Return(false, nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "restricted_post_granted_to_viewer",
+			slug: "family-trip",
+			role: authorization.RoleNonPrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "family-trip").Return(&post_models.Post{PostId: 2, Slug: "family-trip", Restricted: true, Status: post_models.StatusPublished}, nil)
+				m.On("CanView", 2, post_models.Viewer{}).Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "access_check_fails",
+			slug: "family-trip",
+			role: authorization.RolePrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "family-trip").Return(&post_models.Post{PostId: 2, Slug: "family-trip", Restricted: true, Status: post_models.StatusPublished}, nil)
+				m.On("CanView", 2, post_models.Viewer{Privileged: true}).Return(false, errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "draft_hidden_from_non_admins",
+			slug: "work-in-progress",
+			role: authorization.RolePrivileged,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "work-in-progress").Return(&post_models.Post{PostId: 3, Slug: "work-in-progress", Status: post_models.StatusDraft}, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "draft_visible_to_admins",
+			slug: "work-in-progress",
+			role: authorization.RoleAdmin,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "work-in-progress").Return(&post_models.Post{PostId: 3, Slug: "work-in-progress", Status: post_models.StatusDraft}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "unknown_slug",
+			slug: "missing",
+			role: authorization.RoleAdmin,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostBySlug", "missing").Return(nil, pgx.ErrNoRows)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			tt.setupMock(mockRepo)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/slugs/"+tt.slug, nil)
+			req.SetPathValue("slug", tt.slug)
+			rr := httptest.NewRecorder()
+			withRole(tt.role, postsApi.GetPostBySlug).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, "Status code mismatch for test: %s", tt.name)
+			assert.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetPostByIdRendered(t *testing.T) {
+	session.Init()
+	post := &post_models.Post{PostId: 4, Content: "## Setup\n\nRun `make`.", Status: post_models.StatusPublished}
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedHTML bool
+	}{
+		{name: "raw_by_default", query: ""},
+		{name: "rendered_on_request", query: "?render=true", expectedHTML: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			postCopy := *post
+			mockRepo.On("GetPostById", 4).Return(&postCopy, nil)
+			mockRepo.On("CanView", 4, mock.Anything).Return(true, nil)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts/4"+tt.query, nil)
+			req.SetPathValue("id", "4")
+			rr := httptest.NewRecorder()
+			withRole(authorization.RoleNonPrivileged, postsApi.GetPostById).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var got post_models.Post
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+			assert.Equal(t, post.Content, got.Content)
+			if tt.expectedHTML {
+				assert.Contains(t, got.ContentHTML, `<h2 id="setup">Setup</h2>`)
+				assert.Equal(t, []post_models.Heading{{Level: 2, Text: "Setup", Id: "setup"}}, got.TOC)
+			} else {
+				assert.Empty(t, got.ContentHTML)
+				assert.Nil(t, got.TOC)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdatePostIfMatch(t *testing.T) {
+	session.Init()
+	body := `{"postData":{"title":"Title","content":"Body","status":"published"}}`
+
+	tests := []struct {
+		name           string
+		ifMatch        string
+		setupMock      func(*mockPostsRepository)
+		expectedStatus int
+		expectedETag   string
+	}{
+		{
+			name:           "missing_if_match",
+			setupMock:      func(m *mockPostsRepository) {},
+			expectedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:    "current_version",
+			ifMatch: `"4-en"`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 7).Return(&post_models.Post{PostId: 7, Status: post_models.StatusPublished, Version: 4, Locale: "en"}, nil)
+				m.On("UpdatePost", mock.Anything, 7, 0, 4).Return(&post_models.PostRequestBody{Title: "Title", Status: post_models.StatusPublished, Version: 5, Locale: "en"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"5-en"`,
+		},
+		{
+			name:    "etag_of_a_translation",
+			ifMatch: `"4-es"`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 7).Return(&post_models.Post{PostId: 7, Status: post_models.StatusPublished, Version: 4, Locale: "en"}, nil)
+				m.On("UpdatePost", mock.Anything, 7, 0, 4).Return(&post_models.PostRequestBody{Title: "Title", Status: post_models.StatusPublished, Version: 5, Locale: "en"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"5-en"`,
+		},
+		{
+			name:    "stale_version",
+			ifMatch: `"3-en"`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 7).Return(&post_models.Post{PostId: 7, Status: post_models.StatusPublished, Version: 4, Locale: "en"}, nil)
+				m.On("UpdatePost", mock.Anything, 7, 0, 3).Return(nil, posts_repo.ErrVersionConflict)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedETag:   `"4-en"`,
+		},
+		{
+			name:    "weak_tag_never_matches",
+			ifMatch: `W/"4-en"`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 7).Return(&post_models.Post{PostId: 7, Status: post_models.StatusPublished, Version: 4, Locale: "en"}, nil)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedETag:   `"4-en"`,
+		},
+		{
+			name:    "wildcard_never_matches",
+			ifMatch: "*",
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 7).Return(&post_models.Post{PostId: 7, Status: post_models.StatusPublished, Version: 4, Locale: "en"}, nil)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedETag:   `"4-en"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			tt.setupMock(mockRepo)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPut, "/api/posts/7", strings.NewReader(body))
+			req.SetPathValue("id", "7")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			withRole(authorization.RoleAdmin, postsApi.UpdatePost).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedETag, rr.Header().Get("ETag"))
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdatePostKeepsStatus(t *testing.T) {
+	session.Init()
+	publishAt := time.Now().Add(24 * time.Hour)
+	tests := []struct {
+		name     string
+		existing post_models.Post
+	}{
+		{name: "draft", existing: post_models.Post{PostId: 7, Status: post_models.StatusDraft, Version: 4}},
+		{name: "scheduled", existing: post_models.Post{PostId: 7, Status: post_models.StatusScheduled, PublishAt: &publishAt, Version: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			mockRepo.On("GetPostById", 7).Return(&tt.existing, nil)
+			// No NotifyOnNewPost expectations: the post isn't published, so nobody is emailed
+			mockRepo.On("UpdatePost", mock.MatchedBy(func(post post_models.PostRequestBody) bool {
+				return post.Status == tt.existing.Status && post.PublishAt == tt.existing.PublishAt
+			}), 7, 0, 4).Return(&post_models.PostRequestBody{Title: "Title", Status: tt.existing.Status, Version: 5}, nil)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			// What the edit form sends: the post without a status
+			req := httptest.NewRequest(http.MethodPut, "/api/posts/7", strings.NewReader(`{"postData":{"title":"Title","content":"Body"}}`))
+			req.SetPathValue("id", "7")
+			req.Header.Set("If-Match", `"4"`)
+			rr := httptest.NewRecorder()
+			withRole(authorization.RoleAdmin, postsApi.UpdatePost).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var updated post_models.PostRequestBody
+			if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.existing.Status, updated.Status)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	version, ok := ifMatchVersion(`"12-en"`)
+	assert.True(t, ok)
+	assert.Equal(t, 12, version)
+
+	version, ok = ifMatchVersion(`W/"3-en", "12-pt-BR"`)
+	assert.True(t, ok)
+	assert.Equal(t, 12, version)
+
+	_, ok = ifMatchVersion("*")
+	assert.False(t, ok)
+
+	_, ok = ifMatchVersion(`"abc", W/"3"`)
+	assert.False(t, ok)
+}
+
+func TestGetPostByIdConditional(t *testing.T) {
+	session.Init()
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := post_models.Post{PostId: 4, Content: "body", Status: post_models.StatusPublished, UpdatedAt: updated, Version: 3, Locale: "en"}
+
+	tests := []struct {
+		name                 string
+		role                 int
+		headers              map[string]string
+		expectedStatus       int
+		expectedCacheControl string
+	}{
+		{name: "anonymous_public", role: authorization.RoleNonPrivileged, expectedStatus: http.StatusOK, expectedCacheControl: "public, max-age=60"},
+		{name: "signed_in_private", role: authorization.RolePrivileged, expectedStatus: http.StatusOK, expectedCacheControl: "private, no-cache"},
+		{name: "matching_etag", role: authorization.RoleNonPrivileged, headers: map[string]string{"If-None-Match": `"3-en"`}, expectedStatus: http.StatusNotModified, expectedCacheControl: "public, max-age=60"},
+		{name: "stale_etag", role: authorization.RoleNonPrivileged, headers: map[string]string{"If-None-Match": `"2-en"`}, expectedStatus: http.StatusOK, expectedCacheControl: "public, max-age=60"},
+		{
+			name:                 "not_modified_since",
+			role:                 authorization.RoleNonPrivileged,
+			headers:              map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)},
+			expectedStatus:       http.StatusNotModified,
+			expectedCacheControl: "public, max-age=60",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			postCopy := post
+			mockRepo.On("GetPostById", 4).Return(&postCopy, nil)
+			mockRepo.On("CanView", 4, mock.Anything).Return(true, nil)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts/4", nil)
+			req.SetPathValue("id", "4")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			withRole(tt.role, postsApi.GetPostById).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, `"3-en"`, rr.Header().Get("ETag"))
+			assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", rr.Header().Get("Last-Modified"))
+			assert.Equal(t, tt.expectedCacheControl, rr.Header().Get("Cache-Control"))
+			assert.Contains(t, rr.Header().Values("Vary"), "Cookie")
+			if tt.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetPostsConditional(t *testing.T) {
+	session.Init()
+	mockRepo := new(mockPostsRepository)
+	mockRepo.On("GetPosts", mock.Anything).Return(makePosts(2), nil)
+	postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	withRole(authorization.RoleNonPrivileged, postsApi.GetPosts).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	withRole(authorization.RoleNonPrivileged, postsApi.GetPosts).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	// Privileged callers see restricted posts, so the anonymous tag must not validate their copy
+	req = httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	withRole(authorization.RolePrivileged, postsApi.GetPosts).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "private, no-cache", rr.Header().Get("Cache-Control"))
+}
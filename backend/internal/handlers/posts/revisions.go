@@ -0,0 +1,162 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/diff"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+func (p *postsApi) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		p.logger.Sugar().Errorf("GetRevisions parameter was not an integer: %v", err)
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	revisions, err := p.postsRepository.GetRevisions(postId)
+	if err != nil {
+		p.logger.Sugar().Errorf("error getting revisions for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting revisions", ""))
+		return
+	}
+	if len(revisions) == 0 {
+		httperr.Write(w, httperr.NotFound("post not found", ""))
+		return
+	}
+	b, err := json.Marshal(revisions)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling revisions for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting revisions", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func (p *postsApi) GetRevision(w http.ResponseWriter, r *http.Request) {
+	revision, ok := p.loadRevision(w, r.PathValue("id"), r.PathValue("revisionId"))
+	if !ok {
+		return
+	}
+	b, err := json.Marshal(revision)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling revision %d : %v", revision.RevisionId, err)
+		httperr.Write(w, httperr.Internal("error getting revision", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// DiffRevisions compares two revisions of a post given by the from and to query parameters
+func (p *postsApi) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	from, ok := p.loadRevision(w, r.PathValue("id"), r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := p.loadRevision(w, r.PathValue("id"), r.URL.Query().Get("to"))
+	if !ok {
+		return
+	}
+	revisionDiff := post_models.RevisionDiff{
+		From:    from.RevisionId,
+		To:      to.RevisionId,
+		Title:   diffLines(from.Title, to.Title),
+		Content: diffLines(from.Content, to.Content),
+	}
+	b, err := json.Marshal(revisionDiff)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling diff of revisions %d and %d : %v", from.RevisionId, to.RevisionId, err)
+		httperr.Write(w, httperr.Internal("error comparing revisions", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// RestoreRevision makes an old revision the current version of the post. Status and schedule are left alone
+// so restoring never unpublishes a post, and the restore is itself recorded as a new revision.
+func (p *postsApi) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	userID := session.Manager.GetInt(r.Context(), "user_id")
+	revision, ok := p.loadRevision(w, r.PathValue("id"), r.PathValue("revisionId"))
+	if !ok {
+		return
+	}
+	current, err := p.postsRepository.GetPostById(revision.PostId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error restoring revision", ""))
+		return
+	}
+	restored, err := p.postsRepository.UpdatePost(post_models.PostRequestBody{
+		Title:      revision.Title,
+		Content:    revision.Content,
+		Restricted: revision.Restricted,
+		Slug:       revision.Slug,
+		Status:     current.Status,
+		PublishAt:  current.PublishAt,
+	}, revision.PostId, userID, current.Version)
+	if err != nil {
+		if errors.Is(err, posts_repo.ErrVersionConflict) {
+			httperr.Write(w, httperr.PreconditionFailed("post was modified while restoring, try again", ""))
+			return
+		}
+		p.logger.Sugar().Errorf("error restoring revision %d of post %d : %v", revision.RevisionId, revision.PostId, err)
+		httperr.Write(w, httperr.Internal("error restoring revision", ""))
+		return
+	}
+	b, err := json.Marshal(restored)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling restored post %d : %v", revision.PostId, err)
+		httperr.Write(w, httperr.Internal("error restoring revision", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// loadRevision parses the ids and fetches the revision, writing the error response itself when it can't
+func (p *postsApi) loadRevision(w http.ResponseWriter, postIdStr, revisionIdStr string) (*post_models.PostRevision, bool) {
+	postId, err := strconv.Atoi(postIdStr)
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return nil, false
+	}
+	revisionId, err := strconv.Atoi(revisionIdStr)
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("revisionId must be an integer", ""))
+		return nil, false
+	}
+	revision, err := p.postsRepository.GetRevision(postId, revisionId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			p.logger.Sugar().Warnf("revision %d of post %d does not exist in the database", revisionId, postId)
+			httperr.Write(w, httperr.NotFound("revision not found", ""))
+			return nil, false
+		}
+		httperr.Write(w, httperr.Internal("error getting revision", ""))
+		return nil, false
+	}
+	return revision, true
+}
+
+// diffLines diffs a into b line by line for a RevisionDiff
+func diffLines(a, b string) []post_models.DiffLine {
+	lines := diff.Lines(a, b)
+	diffLines := make([]post_models.DiffLine, len(lines))
+	for i, line := range lines {
+		diffLines[i] = post_models.DiffLine{Op: line.Op, Text: line.Text}
+	}
+	return diffLines
+}
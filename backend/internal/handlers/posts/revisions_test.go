@@ -0,0 +1,106 @@
+package posts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	"github.com/KylerJacobson/blog/backend/internal/diff"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	session.Init()
+	mockRepo := new(mockPostsRepository)
+	mockRepo.On("GetRevision", 7, 1).Return(&post_models.PostRevision{RevisionId: 1, PostId: 7, Title: "Draft", Content: "intro\nbody"}, nil)
+	mockRepo.On("GetRevision", 7, 2).Return(&post_models.PostRevision{RevisionId: 2, PostId: 7, Title: "Final", Content: "intro\nbetter body"}, nil)
+	postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/7/revisions/diff?from=1&to=2", nil)
+	req.SetPathValue("id", "7")
+	rr := httptest.NewRecorder()
+	postsApi.DiffRevisions(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var revisionDiff post_models.RevisionDiff
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&revisionDiff))
+	assert.Equal(t, []post_models.DiffLine{{Op: diff.OpDelete, Text: "Draft"}, {Op: diff.OpInsert, Text: "Final"}}, revisionDiff.Title)
+	assert.Equal(t, []post_models.DiffLine{
+		{Op: diff.OpEqual, Text: "intro"},
+		{Op: diff.OpDelete, Text: "body"},
+		{Op: diff.OpInsert, Text: "better body"},
+	}, revisionDiff.Content)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreRevision(t *testing.T) {
+	session.Init()
+	publishAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		revisionId     string
+		setupMock      func(*mockPostsRepository)
+		expectedStatus int
+	}{
+		{
+			name:       "restores_content_but_keeps_status",
+			revisionId: "3",
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetRevision", 9, 3).Return(&post_models.PostRevision{RevisionId: 3, PostId: 9, Title: "Old", Content: "old body", Slug: "old", Status: post_models.StatusDraft}, nil)
+				m.On("GetPostById", 9).Return(&post_models.Post{PostId: 9, Status: post_models.StatusPublished, PublishAt: &publishAt, Version: 4}, nil)
+				m.On("UpdatePost", post_models.PostRequestBody{
+					Title:     "Old",
+					Content:   "old body",
+					Slug:      "old",
+					Status:    post_models.StatusPublished,
+					PublishAt: &publishAt,
+				}, 9, 1, 4).Return(&post_models.PostRequestBody{Title: "Old"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown_revision",
+			revisionId: "4",
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetRevision", 9, 4).Return(nil, pgx.ErrNoRows)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "bad_revision_id",
+			revisionId:     "latest",
+			setupMock:      func(m *mockPostsRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			tt.setupMock(mockRepo)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/posts/9/revisions/"+tt.revisionId+"/restore", nil)
+			req.SetPathValue("id", "9")
+			req.SetPathValue("revisionId", tt.revisionId)
+			rr := httptest.NewRecorder()
+			handler := session.Manager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session.Manager.Put(r.Context(), "user_id", 1)
+				session.Manager.Put(r.Context(), "user_role", authorization.RoleAdmin)
+				postsApi.RestoreRevision(w, r)
+			}))
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, "Status code mismatch for test: %s", tt.name)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
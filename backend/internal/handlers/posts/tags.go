@@ -0,0 +1,77 @@
+package posts
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+)
+
+const (
+	MaxTagsPerPost = 20
+	MaxTagLength   = 50
+)
+
+func (p *postsApi) GetTags(w http.ResponseWriter, r *http.Request) {
+	viewer := p.auth.Viewer(r)
+	tags, err := p.postsRepository.GetTags(viewer, viewer.Admin)
+	if err != nil {
+		p.logger.Sugar().Errorf("error getting tags : %v", err)
+		httperr.Write(w, httperr.Internal("error getting tags", ""))
+		return
+	}
+	if tags == nil {
+		tags = []post_models.Tag{}
+	}
+	b, err := json.Marshal(tags)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling tags : %v", err)
+		httperr.Write(w, httperr.Internal("error getting tags", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// GetPostsByTag pages through the posts carrying a tag, taking the same query parameters as GetPosts
+func (p *postsApi) GetPostsByTag(w http.ResponseWriter, r *http.Request) {
+	tag := normalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		httperr.Write(w, httperr.BadRequest("tag must not be empty", ""))
+		return
+	}
+	filter, err := parsePostFilter(r.URL.Query())
+	if err != nil {
+		p.logger.Sugar().Warnf("invalid post listing parameters: %v", err)
+		httperr.Write(w, httperr.BadRequest("invalid query parameters", err.Error()))
+		return
+	}
+	filter.Tag = tag
+	p.writePostPage(w, r, filter)
+}
+
+// normalizeTag lowercases a tag and joins its words with hyphens so "Go", " go " and "GO" are the same tag
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// NormalizeTags normalizes and de-duplicates tags, dropping empty ones. A nil slice stays nil
+// so updates can tell "leave tags alone" apart from "remove all tags".
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
@@ -0,0 +1,73 @@
+package posts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Nil(t, NormalizeTags(nil))
+	assert.Equal(t, []string{}, NormalizeTags([]string{}))
+	assert.Equal(t, []string{"go", "machine-learning", "c++"}, NormalizeTags([]string{"Go", " go ", "Machine  Learning", "", "C++", "GO"}))
+}
+
+func TestGetTags(t *testing.T) {
+	session.Init()
+
+	tests := []struct {
+		name               string
+		role               int
+		viewer             post_models.Viewer
+		includeUnpublished bool
+	}{
+		{name: "anonymous", role: authorization.RoleNonPrivileged},
+		{name: "privileged", role: authorization.RolePrivileged, viewer: post_models.Viewer{Privileged: true}},
+		{name: "admin", role: authorization.RoleAdmin, viewer: post_models.Viewer{Privileged: true, Admin: true}, includeUnpublished: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			mockRepo.On("GetTags", tt.viewer, tt.includeUnpublished).Return([]post_models.Tag{{Name: "go", PostCount: 3}}, nil)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			rr := httptest.NewRecorder()
+			withRole(tt.role, postsApi.GetTags).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var tags []post_models.Tag
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&tags))
+			assert.Equal(t, []post_models.Tag{{Name: "go", PostCount: 3}}, tags)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetPostsByTag(t *testing.T) {
+	session.Init()
+	mockRepo := new(mockPostsRepository)
+	mockRepo.On("GetPosts", mock.MatchedBy(func(f post_models.PostFilter) bool {
+		return f.Tag == "machine-learning" && f.Limit == 3 && !f.Viewer.Privileged
+	})).Return(makePosts(2), nil)
+	postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tags/Machine%20Learning/posts?limit=2", nil)
+	req.SetPathValue("tag", "Machine Learning")
+	rr := httptest.NewRecorder()
+	withRole(authorization.RoleNonPrivileged, postsApi.GetPostsByTag).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page post_models.PostPage
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	assert.Len(t, page.Posts, 2)
+	mockRepo.AssertExpectations(t)
+}
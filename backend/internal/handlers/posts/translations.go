@@ -0,0 +1,157 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/locale"
+	"github.com/KylerJacobson/blog/backend/internal/slug"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+// GetTranslations lists every translation of a post
+func (p *postsApi) GetTranslations(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	_, err = p.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error getting translations", ""))
+		return
+	}
+	translations, err := p.postsRepository.GetTranslations(postId)
+	if err != nil {
+		p.logger.Sugar().Errorf("error getting translations for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting translations", ""))
+		return
+	}
+	if translations == nil {
+		translations = []post_models.PostTranslation{}
+	}
+	b, err := json.Marshal(translations)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling translations for post %d : %v", postId, err)
+		httperr.Write(w, httperr.Internal("error getting translations", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// SetTranslation creates or replaces the translation of a post into the locale in the path
+func (p *postsApi) SetTranslation(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	translationLocale, err := locale.Parse(r.PathValue("locale"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("invalid locale", err.Error()))
+		return
+	}
+	var translation post_models.PostTranslation
+	err = json.NewDecoder(r.Body).Decode(&translation)
+	if err != nil {
+		p.logger.Sugar().Errorf("error decoding the translation request body: %v", err)
+		httperr.Write(w, httperr.BadRequest("invalid request body", ""))
+		return
+	}
+	translation.PostId = postId
+	translation.Locale = translationLocale
+	err = validateTranslation(translation)
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("translation was not formatted correctly", err.Error()))
+		return
+	}
+	post, err := p.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		httperr.Write(w, httperr.Internal("error saving translation", ""))
+		return
+	}
+	if post.Locale == translationLocale {
+		httperr.Write(w, httperr.Conflict("post is written in "+translationLocale, "edit the post itself instead"))
+		return
+	}
+	saved, err := p.postsRepository.SetTranslation(translation)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("post not found", ""))
+			return
+		}
+		p.logger.Sugar().Errorf("error saving the %s translation of post %d : %v", translationLocale, postId, err)
+		httperr.Write(w, httperr.Internal("error saving translation", ""))
+		return
+	}
+	b, err := json.Marshal(saved)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling the %s translation of post %d : %v", translationLocale, postId, err)
+		httperr.Write(w, httperr.Internal("error saving translation", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func (p *postsApi) DeleteTranslation(w http.ResponseWriter, r *http.Request) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	translationLocale, err := locale.Parse(r.PathValue("locale"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("invalid locale", err.Error()))
+		return
+	}
+	err = p.postsRepository.DeleteTranslation(postId, translationLocale)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			httperr.Write(w, httperr.NotFound("translation not found", ""))
+			return
+		}
+		p.logger.Sugar().Errorf("error deleting the %s translation of post %d : %v", translationLocale, postId, err)
+		httperr.Write(w, httperr.Internal("error deleting translation", ""))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validateTranslation(translation post_models.PostTranslation) error {
+	if len(translation.Title) < 1 {
+		return errors.New("translation title must not be empty")
+	}
+	if len(translation.Content) < 1 {
+		return errors.New("translation content must not be empty")
+	}
+	if translation.Slug != "" && slug.Make(translation.Slug) == "" {
+		return errors.New("translation slug must contain letters or digits")
+	}
+	return nil
+}
+
+// normalizeLocale canonicalizes a post's locale, leaving anything unparseable for validatePost to reject
+func normalizeLocale(value string) string {
+	if value == "" {
+		return ""
+	}
+	parsed, err := locale.Parse(value)
+	if err != nil {
+		return value
+	}
+	return parsed
+}
@@ -0,0 +1,164 @@
+package posts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestGetPostByIdTranslated(t *testing.T) {
+	session.Init()
+	post := post_models.Post{PostId: 4, Title: "Family trip", Content: "We went", Slug: "family-trip", Status: post_models.StatusPublished,
+		Locale: "en", Locales: []string{"en", "es"}, Version: 3}
+	translation := &post_models.PostTranslation{PostId: 4, Locale: "es", Title: "Viaje familiar", Content: "Fuimos", Slug: "viaje-familiar"}
+
+	tests := []struct {
+		name             string
+		query            string
+		acceptLanguage   string
+		ifNoneMatch      string
+		expectedTitle    string
+		expectedLanguage string
+	}{
+		{name: "written_language_by_default", expectedTitle: "Family trip", expectedLanguage: "en"},
+		{name: "accept_language", acceptLanguage: "es-MX, en;q=0.8", expectedTitle: "Viaje familiar", expectedLanguage: "es"},
+		{name: "lang_overrides_the_header", query: "?lang=en", acceptLanguage: "es", expectedTitle: "Family trip", expectedLanguage: "en"},
+		{name: "missing_translation_falls_back", acceptLanguage: "fr", expectedTitle: "Family trip", expectedLanguage: "en"},
+		{name: "as_written_for_editing", query: "?translate=false", acceptLanguage: "es", expectedTitle: "Family trip", expectedLanguage: "en"},
+		{name: "etag_of_another_language", acceptLanguage: "es", ifNoneMatch: `"3-en"`, expectedTitle: "Viaje familiar", expectedLanguage: "es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			postCopy := post
+			mockRepo.On("GetPostById", 4).Return(&postCopy, nil)
+			mockRepo.On("CanView", 4, mock.Anything).Return(true, nil)
+			mockRepo.On("GetTranslation", 4, "es").Return(translation, nil).Maybe()
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts/4"+tt.query, nil)
+			req.SetPathValue("id", "4")
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			withRole(authorization.RoleNonPrivileged, postsApi.GetPostById).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var got post_models.Post
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+			assert.Equal(t, tt.expectedTitle, got.Title)
+			assert.Equal(t, tt.expectedLanguage, got.Locale)
+			assert.Equal(t, []string{"en", "es"}, got.Locales)
+			assert.Equal(t, tt.expectedLanguage, rr.Header().Get("Content-Language"))
+			assert.Equal(t, `"3-`+tt.expectedLanguage+`"`, rr.Header().Get("ETag"))
+			assert.Contains(t, rr.Header().Values("Vary"), "Accept-Language")
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSetTranslation(t *testing.T) {
+	post := &post_models.Post{PostId: 5, Locale: "en", Locales: []string{"en"}}
+
+	tests := []struct {
+		name           string
+		locale         string
+		body           string
+		setupMock      func(*mockPostsRepository)
+		expectedStatus int
+	}{
+		{
+			name:   "created",
+			locale: "ES",
+			body:   `{"title":"Hola","content":"Contenido"}`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 5).Return(post, nil)
+				m.On("SetTranslation", post_models.PostTranslation{PostId: 5, Locale: "es", Title: "Hola", Content: "Contenido"}).
+					Return(&post_models.PostTranslation{PostId: 5, Locale: "es", Title: "Hola", Content: "Contenido", Slug: "hola"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid_locale",
+			locale:         "not_a_locale!",
+			body:           `{"title":"Hola","content":"Contenido"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing_content",
+			locale:         "es",
+			body:           `{"title":"Hola"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "written_language",
+			locale: "en",
+			body:   `{"title":"Hello","content":"Body"}`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 5).Return(post, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:   "missing_post",
+			locale: "es",
+			body:   `{"title":"Hola","content":"Contenido"}`,
+			setupMock: func(m *mockPostsRepository) {
+				m.On("GetPostById", 5).Return(nil, pgx.ErrNoRows)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPut, "/api/posts/5/translations/"+tt.locale, strings.NewReader(tt.body))
+			req.SetPathValue("id", "5")
+			req.SetPathValue("locale", tt.locale)
+			rr := httptest.NewRecorder()
+			postsApi.SetTranslation(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteTranslation(t *testing.T) {
+	mockRepo := new(mockPostsRepository)
+	mockRepo.On("DeleteTranslation", 5, "es").Return(nil)
+	mockRepo.On("DeleteTranslation", 5, "fr").Return(pgx.ErrNoRows)
+	postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	for locale, expectedStatus := range map[string]int{"es": http.StatusNoContent, "fr": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodDelete, "/api/posts/5/translations/"+locale, nil)
+		req.SetPathValue("id", "5")
+		req.SetPathValue("locale", locale)
+		rr := httptest.NewRecorder()
+		postsApi.DeleteTranslation(rr, req)
+
+		assert.Equal(t, expectedStatus, rr.Code, locale)
+	}
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,70 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+func (p *postsApi) GetTrash(w http.ResponseWriter, r *http.Request) {
+	posts, err := p.postsRepository.GetTrashedPosts()
+	if err != nil {
+		p.logger.Sugar().Errorf("error getting trashed posts : %v", err)
+		httperr.Write(w, httperr.Internal("error getting trash", ""))
+		return
+	}
+	if posts == nil {
+		posts = []post_models.Post{}
+	}
+	b, err := json.Marshal(posts)
+	if err != nil {
+		p.logger.Sugar().Errorf("error marshalling trashed posts : %v", err)
+		httperr.Write(w, httperr.Internal("error getting trash", ""))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// RestorePost moves a post and its media out of the trash
+func (p *postsApi) RestorePost(w http.ResponseWriter, r *http.Request) {
+	p.trashAction(w, r, "restoring", p.postsRepository.RestorePost)
+}
+
+// PurgePost permanently deletes a post that is already in the trash, along with its media in storage
+func (p *postsApi) PurgePost(w http.ResponseWriter, r *http.Request) {
+	p.trashAction(w, r, "purging", func(postId int) error {
+		blobNames, err := p.postsRepository.PurgePost(postId)
+		if err != nil {
+			return err
+		}
+		storage.DeleteBlobs(p.blobs, blobNames, p.logger)
+		return nil
+	})
+}
+
+func (p *postsApi) trashAction(w http.ResponseWriter, r *http.Request, verb string, action func(postId int) error) {
+	postId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		httperr.Write(w, httperr.BadRequest("postId must be an integer", ""))
+		return
+	}
+	err = action(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			p.logger.Sugar().Warnf("post %d is not in the trash", postId)
+			httperr.Write(w, httperr.NotFound("post not found in trash", ""))
+			return
+		}
+		p.logger.Sugar().Errorf("error %s post %d : %v", verb, postId, err)
+		httperr.Write(w, httperr.Internal("error "+verb+" post", ""))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
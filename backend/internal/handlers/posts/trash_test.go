@@ -0,0 +1,110 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestDeletePostById(t *testing.T) {
+	tests := []struct {
+		name           string
+		repoErr        error
+		expectedStatus int
+	}{
+		{name: "moved_to_trash", expectedStatus: http.StatusNoContent},
+		{name: "missing_or_already_trashed", repoErr: pgx.ErrNoRows, expectedStatus: http.StatusNotFound},
+		{name: "database_error", repoErr: errors.New("boom"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockPostsRepository)
+			mockRepo.On("DeletePostById", 5).Return(tt.repoErr)
+			postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/posts/5", nil)
+			req.SetPathValue("id", "5")
+			rr := httptest.NewRecorder()
+			postsApi.DeletePostById(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetTrash(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	mockRepo := new(mockPostsRepository)
+	mockRepo.On("GetTrashedPosts").Return([]post_models.Post{{PostId: 5, DeletedAt: &deletedAt}}, nil)
+	postsApi := New(mockRepo, nil, nil, nil, nil, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	postsApi.GetTrash(rr, httptest.NewRequest(http.MethodGet, "/api/posts/trash", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var posts []post_models.Post
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&posts))
+	assert.Len(t, posts, 1)
+	assert.Equal(t, deletedAt, *posts[0].DeletedAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreAndPurgePost(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		repoErr        error
+		expectedStatus int
+	}{
+		{name: "restore", method: "RestorePost", expectedStatus: http.StatusNoContent},
+		{name: "restore_not_in_trash", method: "RestorePost", repoErr: pgx.ErrNoRows, expectedStatus: http.StatusNotFound},
+		{name: "purge", method: "PurgePost", expectedStatus: http.StatusNoContent},
+		{name: "purge_not_in_trash", method: "PurgePost", repoErr: pgx.ErrNoRows, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := storage.NewMemoryStore()
+			if err := blobs.UploadBlob(strings.NewReader("data"), "blog-media/5_photo.png"); err != nil {
+				t.Fatal(err)
+			}
+			mockRepo := new(mockPostsRepository)
+			postsApi := New(mockRepo, nil, nil, nil, blobs, authorization.NewAuthService(zap.NewNop()), zap.NewNop())
+			handler := postsApi.RestorePost
+			if tt.method == "PurgePost" {
+				mockRepo.On(tt.method, 5).Return([]string{"blog-media/5_photo.png"}, tt.repoErr)
+				handler = postsApi.PurgePost
+			} else {
+				mockRepo.On(tt.method, 5).Return(tt.repoErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/posts/trash/5", nil)
+			req.SetPathValue("id", "5")
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockRepo.AssertExpectations(t)
+			// Only a purge that went through removes the post's media from storage
+			_, err := blobs.StatBlob("blog-media/5_photo.png")
+			if tt.method == "PurgePost" && tt.repoErr == nil {
+				assert.ErrorIs(t, err, storage.ErrNotFound)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// MaxURLsPerSitemap is the sitemaps.org protocol limit for a single sitemap file
+const MaxURLsPerSitemap = 50000
+
+// StaticRoutes are the public SPA pages listed ahead of the posts
+var StaticRoutes = []string{"/", "/about"}
+
+type SitemapApi interface {
+	GetSitemap(w http.ResponseWriter, r *http.Request)
+	GetSitemapPage(w http.ResponseWriter, r *http.Request)
+	GetRobots(w http.ResponseWriter, r *http.Request)
+}
+
+type sitemapApi struct {
+	postsRepository posts_repo.PostsRepository
+	siteURL         string
+	urlsPerSitemap  int
+	logger          logger.Logger
+}
+
+func New(postsRepo posts_repo.PostsRepository, siteURL string, logger logger.Logger) *sitemapApi {
+	return &sitemapApi{
+		postsRepository: postsRepo,
+		siteURL:         strings.TrimRight(siteURL, "/"),
+		urlsPerSitemap:  MaxURLsPerSitemap,
+		logger:          logger,
+	}
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+// GetSitemap serves the whole sitemap, or a sitemap index pointing at /sitemaps/{n}.xml once there are
+// more URLs than one sitemap may hold
+func (s *sitemapApi) GetSitemap(w http.ResponseWriter, r *http.Request) {
+	count, err := s.postsRepository.CountPublicPosts()
+	if err != nil {
+		s.logger.Sugar().Errorf("error counting posts for sitemap : %v", err)
+		httperr.Write(w, httperr.Internal("error building sitemap", ""))
+		return
+	}
+	total := len(StaticRoutes) + count
+	if total <= s.urlsPerSitemap {
+		s.writePage(w, 1)
+		return
+	}
+
+	pages := (total + s.urlsPerSitemap - 1) / s.urlsPerSitemap
+	index := sitemapIndex{}
+	for page := 1; page <= pages; page++ {
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: fmt.Sprintf("%s/sitemaps/%d.xml", s.siteURL, page)})
+	}
+	s.writeXML(w, index)
+}
+
+func (s *sitemapApi) GetSitemapPage(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(strings.TrimSuffix(r.PathValue("page"), ".xml"))
+	if err != nil || page < 1 {
+		httperr.Write(w, httperr.NotFound("sitemap not found", ""))
+		return
+	}
+	s.writePage(w, page)
+}
+
+func (s *sitemapApi) GetRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "User-agent: *\nDisallow: /api/\n\nSitemap: %s/sitemap.xml\n", s.siteURL)
+}
+
+// writePage writes the 1-based sitemap page; the static routes come first, followed by posts oldest first
+func (s *sitemapApi) writePage(w http.ResponseWriter, page int) {
+	start := (page - 1) * s.urlsPerSitemap
+	end := start + s.urlsPerSitemap
+
+	set := urlSet{}
+	for i := start; i < min(end, len(StaticRoutes)); i++ {
+		set.URLs = append(set.URLs, sitemapURL{Loc: s.siteURL + StaticRoutes[i]})
+	}
+
+	offset := max(start-len(StaticRoutes), 0)
+	limit := s.urlsPerSitemap - len(set.URLs)
+	posts, err := s.postsRepository.GetPublicPostSummaries(offset, limit)
+	if err != nil {
+		s.logger.Sugar().Errorf("error getting posts for sitemap page %d : %v", page, err)
+		httperr.Write(w, httperr.Internal("error building sitemap", ""))
+		return
+	}
+	for _, post := range posts {
+		lastMod := post.UpdatedAt
+		if post.CreatedAt.After(lastMod) {
+			lastMod = post.CreatedAt
+		}
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     fmt.Sprintf("%s/post/%d", s.siteURL, post.PostId),
+			LastMod: lastMod.UTC().Format(time.RFC3339),
+		})
+	}
+	if len(set.URLs) == 0 && page > 1 {
+		httperr.Write(w, httperr.NotFound("sitemap not found", ""))
+		return
+	}
+	s.writeXML(w, set)
+}
+
+func (s *sitemapApi) writeXML(w http.ResponseWriter, v any) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		s.logger.Sugar().Errorf("error marshalling sitemap : %v", err)
+		httperr.Write(w, httperr.Internal("error building sitemap", ""))
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
@@ -0,0 +1,93 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// stubPostsRepository serves summaries for public posts 1..count
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	count int
+}
+
+func (s *stubPostsRepository) CountPublicPosts() (int, error) {
+	return s.count, nil
+}
+
+func (s *stubPostsRepository) GetPublicPostSummaries(offset, limit int) ([]post_models.PostSummary, error) {
+	updated := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	var summaries []post_models.PostSummary
+	for id := offset + 1; id <= min(offset+limit, s.count); id++ {
+		summaries = append(summaries, post_models.PostSummary{PostId: id, CreatedAt: updated.Add(-time.Hour), UpdatedAt: updated})
+	}
+	return summaries, nil
+}
+
+func TestGetSitemap(t *testing.T) {
+	sitemapApi := New(&stubPostsRepository{count: 2}, "https://example.dev", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	sitemapApi.GetSitemap(rr, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var set urlSet
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &set))
+	assert.Equal(t, []sitemapURL{
+		{Loc: "https://example.dev/"},
+		{Loc: "https://example.dev/about"},
+		{Loc: "https://example.dev/post/1", LastMod: "2024-05-01T08:00:00Z"},
+		{Loc: "https://example.dev/post/2", LastMod: "2024-05-01T08:00:00Z"},
+	}, set.URLs)
+}
+
+func TestGetSitemapIndex(t *testing.T) {
+	sitemapApi := New(&stubPostsRepository{count: 7}, "https://example.dev", zap.NewNop())
+	sitemapApi.urlsPerSitemap = 4
+
+	rr := httptest.NewRecorder()
+	sitemapApi.GetSitemap(rr, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var index sitemapIndex
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &index))
+	assert.Equal(t, []sitemapEntry{
+		{Loc: "https://example.dev/sitemaps/1.xml"},
+		{Loc: "https://example.dev/sitemaps/2.xml"},
+		{Loc: "https://example.dev/sitemaps/3.xml"},
+	}, index.Sitemaps)
+
+	// Page 2 continues right after the two static routes and first two posts
+	req := httptest.NewRequest(http.MethodGet, "/sitemaps/2.xml", nil)
+	req.SetPathValue("page", "2.xml")
+	rr = httptest.NewRecorder()
+	sitemapApi.GetSitemapPage(rr, req)
+	var set urlSet
+	assert.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &set))
+	assert.Len(t, set.URLs, 4)
+	assert.Equal(t, "https://example.dev/post/3", set.URLs[0].Loc)
+
+	req = httptest.NewRequest(http.MethodGet, "/sitemaps/4.xml", nil)
+	req.SetPathValue("page", "4.xml")
+	rr = httptest.NewRecorder()
+	sitemapApi.GetSitemapPage(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetRobots(t *testing.T) {
+	sitemapApi := New(&stubPostsRepository{}, "https://example.dev/", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	sitemapApi.GetRobots(rr, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "User-agent: *\nDisallow: /api/\n\nSitemap: https://example.dev/sitemap.xml\n", rr.Body.String())
+}
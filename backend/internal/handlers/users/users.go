@@ -15,6 +15,7 @@ import (
 	users_repo "github.com/KylerJacobson/blog/backend/internal/db/users"
 	"github.com/KylerJacobson/blog/backend/internal/handlers/session"
 	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/internal/locale"
 	pgxv5 "github.com/jackc/pgx/v5"
 )
 
@@ -119,8 +120,8 @@ func (u *usersApi) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"id": userId,
 	})
@@ -150,7 +151,7 @@ func validateCreateUserRequest(userRequest users.UserCreate) error {
 		errors = append(errors, "access request must be -1, 0 or 2")
 	}
 	if len(errors) > 0 {
-		return fmt.Errorf(strings.Join(errors, ", "))
+		return fmt.Errorf("%s", strings.Join(errors, ", "))
 	}
 	return nil
 }
@@ -221,6 +222,9 @@ func (u *usersApi) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if userUpdate.Locale != "" {
+		userUpdate.Locale, _ = locale.Parse(userUpdate.Locale)
+	}
 	err = u.usersRepository.UpdateUser(userUpdate)
 	if err != nil {
 		if errors.Is(err, pgxv5.ErrNoRows) {
@@ -300,6 +304,14 @@ func (u *usersApi) validateUpdateUserRequest(r *http.Request, userUpdate users.U
 	if userUpdate.Email == "" {
 		errors = append(errors, fmt.Errorf("email is required"))
 	}
+	if userUpdate.NotificationFrequency != "" && !users.NotificationFrequencies[userUpdate.NotificationFrequency] {
+		errors = append(errors, fmt.Errorf("notification frequency must be immediate, daily, weekly or off"))
+	}
+	if userUpdate.Locale != "" {
+		if _, err := locale.Parse(userUpdate.Locale); err != nil {
+			errors = append(errors, err)
+		}
+	}
 
 	if len(errors) > 0 {
 		return fmt.Errorf("validation failed: %v", errors)
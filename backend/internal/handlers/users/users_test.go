@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	userModels "github.com/KylerJacobson/blog/backend/internal/api/types/users"
 	"github.com/KylerJacobson/blog/backend/internal/authorization"
@@ -54,6 +55,16 @@ func (m *mockUsersRepository) GetAllUsersWithEmailNotification() ([]userModels.U
 	panic("implement me")
 }
 
+func (m *mockUsersRepository) GetDigestSubscribers() ([]userModels.DigestSubscriber, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockUsersRepository) SetLastDigestAt(userId int, at time.Time) error {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (m *mockUsersRepository) CreateUser(user userModels.UserCreate) (string, error) {
 	args := m.Called(user)
 	return args.Get(0).(string), args.Error(1)
@@ -69,14 +80,14 @@ func TestCreateUser(t *testing.T) {
 	}{
 		{
 			name: "successful_user_creation",
-			requestBody: userModels.UserCreate{
+			requestBody: userModels.AccountCreationRequest{User: userModels.UserCreate{
 				FirstName:         "John",
 				LastName:          "Doe",
 				Email:             "john@test.com",
 				Password:          "password123",
 				AccessRequest:     0,
 				EmailNotification: true,
-			},
+			}},
 			setupMock: func(m *mockUsersRepository) {
 				m.On("CreateUser", mock.MatchedBy(func(user userModels.UserCreate) bool {
 					return user.FirstName == "John" &&
@@ -94,50 +105,50 @@ func TestCreateUser(t *testing.T) {
 		},
 		{
 			name: "missing_required_fields",
-			requestBody: userModels.UserCreate{
+			requestBody: userModels.AccountCreationRequest{User: userModels.UserCreate{
 
 				// Missing FirstName, LastName and Email
 				Password:          "",
 				AccessRequest:     0,
 				EmailNotification: true,
-			},
+			}},
 			setupMock: func(m *mockUsersRepository) {
 				// No mock needed as validation should fail before repository call
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   map[string]interface{}{"status": float64(400), "message": "Invalid request body", "detail": "first name is required, last name is required, email is required, password is required, password must be at least 8 characters long, invalid email format"},
+			expectedBody:   map[string]interface{}{"status": float64(400), "message": "invalid request body", "detail": "first name is required, last name is required, email is required, password is required, password must be at least 8 characters long, invalid email format"},
 		},
 		{
 			name: "invalid_email_format",
-			requestBody: userModels.UserCreate{
+			requestBody: userModels.AccountCreationRequest{User: userModels.UserCreate{
 				FirstName:         "John",
 				LastName:          "Doe",
 				Email:             "invalid-email", // Invalid email format
 				Password:          "password123",
 				AccessRequest:     0,
 				EmailNotification: true,
-			},
+			}},
 			setupMock: func(m *mockUsersRepository) {
 				// No mock needed as validation should fail before repository call
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   map[string]interface{}{"status": float64(400), "message": "Invalid request body", "detail": "invalid email format"},
+			expectedBody:   map[string]interface{}{"status": float64(400), "message": "invalid request body", "detail": "invalid email format"},
 		},
 		{
 			name: "bad access request",
-			requestBody: userModels.UserCreate{
+			requestBody: userModels.AccountCreationRequest{User: userModels.UserCreate{
 				FirstName:         "John",
 				LastName:          "Doe",
 				Email:             "john@test.com",
 				Password:          "password123", // Too short password
 				AccessRequest:     4,
 				EmailNotification: true,
-			},
+			}},
 			setupMock: func(m *mockUsersRepository) {
 				// No mock needed as validation should fail before repository call
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   map[string]interface{}{"status": float64(400), "message": "Invalid request body", "detail": "access request must be -1, 0 or 2"},
+			expectedBody:   map[string]interface{}{"status": float64(400), "message": "invalid request body", "detail": "access request must be -1, 0 or 2"},
 		},
 		{
 			name:        "bad request body",
@@ -146,18 +157,18 @@ func TestCreateUser(t *testing.T) {
 				// No mock needed as validation should fail before repository call
 			},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   map[string]interface{}{"status": float64(400), "message": "Invalid request body", "detail": "invalid character 'e' looking for beginning of object key string"},
+			expectedBody:   map[string]interface{}{"status": float64(400), "message": "invalid request body", "detail": "invalid character 'e' looking for beginning of object key string"},
 		},
 		{
 			name: "unsuccessful_user_creation",
-			requestBody: userModels.UserCreate{
+			requestBody: userModels.AccountCreationRequest{User: userModels.UserCreate{
 				FirstName:         "John",
 				LastName:          "Doe",
 				Email:             "john@test.com",
 				Password:          "password123",
 				AccessRequest:     0,
 				EmailNotification: true,
-			},
+			}},
 			setupMock: func(m *mockUsersRepository) {
 				m.On("CreateUser", mock.MatchedBy(func(user userModels.UserCreate) bool {
 					return user.FirstName == "John" &&
@@ -169,7 +180,7 @@ func TestCreateUser(t *testing.T) {
 				})).Return("", errors.New("failed to create user"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   map[string]interface{}{"detail": "failed to create user", "message": "failed to create user", "status": float64(500)},
+			expectedBody:   map[string]interface{}{"message": "failed to create user", "status": float64(500)},
 		},
 	}
 
@@ -0,0 +1,62 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 30, 15, 500, time.UTC)
+	etag := ETag("feed", 1, modified)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected bool
+	}{
+		{name: "no_conditions", expected: false},
+		{name: "matching_etag", headers: map[string]string{"If-None-Match": etag}, expected: true},
+		{name: "weak_etag_in_list", headers: map[string]string{"If-None-Match": `"other", W/` + etag}, expected: true},
+		{name: "stale_etag", headers: map[string]string{"If-None-Match": `"other"`}, expected: false},
+		{name: "not_modified_since", headers: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, expected: true},
+		{name: "modified_since", headers: map[string]string{"If-Modified-Since": modified.Add(-time.Minute).Format(http.TimeFormat)}, expected: false},
+		{
+			name:     "etag_wins_over_date",
+			headers:  map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/feed.xml", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			assert.Equal(t, tt.expected, NotModified(rr, req, etag, modified))
+			assert.Equal(t, etag, rr.Header().Get("ETag"))
+			assert.Equal(t, "Wed, 01 May 2024 12:30:15 GMT", rr.Header().Get("Last-Modified"))
+			if tt.expected {
+				assert.Equal(t, http.StatusNotModified, rr.Code)
+			}
+		})
+	}
+}
+
+func TestSetCacheControl(t *testing.T) {
+	rr := httptest.NewRecorder()
+	SetCacheControl(rr, false)
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, "Cookie", rr.Header().Get("Vary"))
+
+	rr = httptest.NewRecorder()
+	SetCacheControl(rr, true)
+	assert.Equal(t, "private, no-cache", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, "Cookie", rr.Header().Get("Vary"))
+}
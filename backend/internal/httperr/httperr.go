@@ -48,6 +48,18 @@ func Forbidden(message string, detail string) *Error {
 	return New(http.StatusUnauthorized, message, detail)
 }
 
+func Conflict(message string, detail string) *Error {
+	return New(http.StatusConflict, message, detail)
+}
+
+func PreconditionFailed(message string, detail string) *Error {
+	return New(http.StatusPreconditionFailed, message, detail)
+}
+
+func PreconditionRequired(message string, detail string) *Error {
+	return New(http.StatusPreconditionRequired, message, detail)
+}
+
 // Write sends the error response to the http.ResponseWriter
 func Write(w http.ResponseWriter, err error) {
 	var httpErr *Error
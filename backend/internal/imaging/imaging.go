@@ -0,0 +1,110 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// ThumbnailSize is the side of the square thumbnail, in pixels
+	ThumbnailSize = 200
+	// ThumbnailName names the thumbnail among an image's variants
+	ThumbnailName = "thumb"
+	JPEGQuality   = 82
+)
+
+// Widths are the widths resized variants are made at. Widths that aren't smaller than the original are skipped,
+// since upscaling only adds bytes.
+var Widths = []int{320, 640, 1024, 1600}
+
+// ContentTypes are the image types variants can be made of
+var ContentTypes = []string{"image/jpeg", "image/png"}
+
+// ErrUnsupported means the content type isn't one of ContentTypes
+var ErrUnsupported = errors.New("unsupported image type")
+
+// Variant is one encoded rendition of an image, in the same format as the original
+type Variant struct {
+	// Name is w<width> for resized variants and ThumbnailName for the thumbnail
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// Variants decodes an image and renders it at each of Widths narrower than itself, plus a ThumbnailSize square
+// thumbnail cropped from its center. Variants are upright even when the original relies on its EXIF orientation.
+func Variants(r io.Reader, contentType string) ([]Variant, error) {
+	var decode func(io.Reader) (image.Image, error)
+	switch contentType {
+	case "image/jpeg":
+		decode = jpeg.Decode
+	case "image/png":
+		decode = png.Decode
+	default:
+		return nil, ErrUnsupported
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	// The variants carry no EXIF, so turn the image the way its orientation says first
+	if metadata, err := ReadMetadata(data, contentType); err == nil && metadata.Orientation > 1 {
+		img = orient(img, metadata.Orientation)
+	}
+
+	bounds := img.Bounds()
+	var variants []Variant
+	for _, width := range Widths {
+		if width >= bounds.Dx() {
+			break
+		}
+		height := max(1, (bounds.Dy()*width+bounds.Dx()/2)/bounds.Dx())
+		variant, err := render(img, bounds, width, height, contentType)
+		if err != nil {
+			return nil, err
+		}
+		variant.Name = fmt.Sprintf("w%d", width)
+		variants = append(variants, variant)
+	}
+
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+	size := min(side, ThumbnailSize)
+	thumbnail, err := render(img, crop, size, size, contentType)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail.Name = ThumbnailName
+	return append(variants, thumbnail), nil
+}
+
+// render scales the src part of img to width by height and encodes it as contentType
+func render(img image.Image, src image.Rectangle, width, height int, contentType string) (Variant, error) {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: JPEGQuality})
+	}
+	if err != nil {
+		return Variant{}, fmt.Errorf("error encoding %dx%d variant: %w", width, height, err)
+	}
+	return Variant{Width: width, Height: height, ContentType: contentType, Data: buf.Bytes()}, nil
+}
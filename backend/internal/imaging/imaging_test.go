@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encoded(t *testing.T, contentType string, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVariants(t *testing.T) {
+	type size struct {
+		name          string
+		width, height int
+	}
+	tests := []struct {
+		name          string
+		contentType   string
+		width, height int
+		expected      []size
+	}{
+		{
+			name:        "large landscape jpeg",
+			contentType: "image/jpeg",
+			width:       2000, height: 1000,
+			expected: []size{{"w320", 320, 160}, {"w640", 640, 320}, {"w1024", 1024, 512}, {"w1600", 1600, 800}, {"thumb", 200, 200}},
+		},
+		{
+			name:        "portrait png between widths",
+			contentType: "image/png",
+			width:       700, height: 1400,
+			expected: []size{{"w320", 320, 640}, {"w640", 640, 1280}, {"thumb", 200, 200}},
+		},
+		{
+			name:        "image smaller than every width",
+			contentType: "image/png",
+			width:       150, height: 90,
+			expected: []size{{"thumb", 90, 90}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants, err := Variants(bytes.NewReader(encoded(t, tt.contentType, tt.width, tt.height)), tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sizes []size
+			for _, variant := range variants {
+				sizes = append(sizes, size{variant.Name, variant.Width, variant.Height})
+				assert.Equal(t, tt.contentType, variant.ContentType)
+
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(variant.Data))
+				if assert.NoError(t, err) {
+					assert.Equal(t, strings.TrimPrefix(tt.contentType, "image/"), format)
+					assert.Equal(t, variant.Width, cfg.Width)
+					assert.Equal(t, variant.Height, cfg.Height)
+				}
+			}
+			assert.Equal(t, tt.expected, sizes)
+		})
+	}
+}
+
+func TestVariantsErrors(t *testing.T) {
+	_, err := Variants(bytes.NewReader([]byte("GIF89a")), "image/gif")
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = Variants(bytes.NewReader([]byte("not a jpeg")), "image/jpeg")
+	assert.Error(t, err)
+}
@@ -0,0 +1,411 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// RotatedJPEGQuality is the quality a JPEG is re-encoded at when its orientation is applied to the pixels. It's
+// higher than JPEGQuality since the result replaces the original.
+const RotatedJPEGQuality = 92
+
+// ErrMalformed means an image's container couldn't be parsed, so it can't be stripped safely
+var ErrMalformed = errors.New("malformed image")
+
+// Metadata is what an image's EXIF says about how it was taken. Fields it doesn't mention are left zero.
+type Metadata struct {
+	// Orientation is the EXIF orientation from 1 to 8, where 1 is upright; 0 means it isn't set
+	Orientation int
+	TakenAt     time.Time
+	Make        string
+	Model       string
+}
+
+// Camera names the camera, e.g. "Apple iPhone 13"; models often repeat the make, which isn't repeated here
+func (m Metadata) Camera() string {
+	if m.Make == "" || strings.HasPrefix(strings.ToLower(m.Model), strings.ToLower(m.Make)) {
+		return m.Model
+	}
+	return strings.TrimSpace(m.Make + " " + m.Model)
+}
+
+// ReadMetadata reads the EXIF of a JPEG or PNG without changing it
+func ReadMetadata(data []byte, contentType string) (Metadata, error) {
+	_, metadata, err := scan(data, contentType)
+	return metadata, err
+}
+
+// Strip removes a JPEG's or PNG's EXIF, XMP, IPTC, comments and text chunks, keeping what affects how it's drawn,
+// such as its colour profile. An image that isn't upright has its orientation applied to the pixels, since the
+// tag that said how to turn it is gone; that re-encodes it, which the others are spared.
+func Strip(data []byte, contentType string) ([]byte, error) {
+	stripped, metadata, err := scan(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Orientation <= 1 || metadata.Orientation > 8 {
+		return stripped, nil
+	}
+
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		img, err := png.Decode(bytes.NewReader(stripped))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if err := png.Encode(&buf, orient(img, metadata.Orientation)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if err := jpeg.Encode(&buf, orient(img, metadata.Orientation), &jpeg.Options{Quality: RotatedJPEGQuality}); err != nil {
+		return nil, err
+	}
+	// The encoder writes no colour profile, so carry over the original's to keep wide gamut photos' colours
+	return withSegments(buf.Bytes(), iccSegments(stripped)), nil
+}
+
+// scan returns the image without its metadata along with what the metadata said
+func scan(data []byte, contentType string) ([]byte, Metadata, error) {
+	switch contentType {
+	case "image/jpeg":
+		return scanJPEG(data)
+	case "image/png":
+		return scanPNG(data)
+	default:
+		return nil, Metadata{}, ErrUnsupported
+	}
+}
+
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2
+	// APP14 is Adobe's, which says how to convert the colours of CMYK JPEGs
+	markerAPP14 = 0xEE
+)
+
+var (
+	exifPrefix = []byte("Exif\x00\x00")
+	iccPrefix  = []byte("ICC_PROFILE\x00")
+)
+
+// scanJPEG copies the segments a decoder needs and drops the rest: APP1 (EXIF and XMP), APP13 (IPTC), comments,
+// other vendors' APPn segments and anything after the end of the image, such as the extra images MPF appends
+func scanJPEG(data []byte) ([]byte, Metadata, error) {
+	var metadata Metadata
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, metadata, fmt.Errorf("%w: not a JPEG", ErrMalformed)
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, markerSOI)
+	i := 2
+	for {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return nil, metadata, fmt.Errorf("%w: no marker at byte %d", ErrMalformed, i)
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte before a marker
+			i++
+			continue
+		case marker == markerEOI:
+			return append(out, 0xFF, markerEOI), metadata, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out = append(out, 0xFF, marker)
+			i += 2
+			continue
+		}
+		if i+4 > len(data) {
+			return nil, metadata, fmt.Errorf("%w: truncated segment at byte %d", ErrMalformed, i)
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			return nil, metadata, fmt.Errorf("%w: segment at byte %d overruns the file", ErrMalformed, i)
+		}
+		payload := data[i+4 : end]
+
+		switch {
+		case marker == markerSOS:
+			// The scan's entropy-coded data runs until the next marker that isn't a stuffed 0xFF or a restart
+			for end < len(data) {
+				if data[end] == 0xFF && end+1 < len(data) && data[end+1] != 0x00 && (data[end+1] < 0xD0 || data[end+1] > 0xD7) {
+					break
+				}
+				end++
+			}
+			out = append(out, data[i:end]...)
+			if end >= len(data) {
+				// Truncated files still display, so finish them rather than turn them away
+				return append(out, 0xFF, markerEOI), metadata, nil
+			}
+		case marker == markerAPP1:
+			if bytes.HasPrefix(payload, exifPrefix) {
+				metadata = parseEXIF(payload[len(exifPrefix):])
+			}
+		case marker == markerAPP2:
+			// APP2 also carries MPF, whose offsets to the appended images no longer hold
+			if bytes.HasPrefix(payload, iccPrefix) {
+				out = append(out, data[i:end]...)
+			}
+		case marker == markerAPP0 || marker == markerAPP14:
+			out = append(out, data[i:end]...)
+		case marker > markerAPP0 && marker <= 0xEF, marker == 0xFE:
+			// Other APPn segments and comments
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+// iccSegments returns the colour profile segments of a JPEG scanJPEG has already checked
+func iccSegments(data []byte) []byte {
+	var segments []byte
+	for i := 2; i+4 <= len(data) && data[i+1] != markerSOS; {
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if data[i+1] == markerAPP2 && bytes.HasPrefix(data[i+4:end], iccPrefix) {
+			segments = append(segments, data[i:end]...)
+		}
+		i = end
+	}
+	return segments
+}
+
+// withSegments inserts segments into a JPEG straight after its start of image marker and any APP0 segment
+func withSegments(data, segments []byte) []byte {
+	if len(segments) == 0 {
+		return data
+	}
+	at := 2
+	if len(data) > 6 && data[3] == markerAPP0 {
+		at += 2 + int(binary.BigEndian.Uint16(data[4:]))
+	}
+	out := make([]byte, 0, len(data)+len(segments))
+	out = append(out, data[:at]...)
+	out = append(out, segments...)
+	return append(out, data[at:]...)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngKept are the ancillary PNG chunks that change how an image is drawn; the rest, text, XMP (an iTXt chunk),
+// tIME and EXIF among them, are dropped. Critical chunks are always kept.
+var pngKept = map[string]bool{
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "sBIT": true, "bKGD": true,
+	"pHYs": true, "hIST": true, "sPLT": true, "cICP": true, "mDCv": true, "cLLi": true,
+	"acTL": true, "fcTL": true, "fdAT": true,
+}
+
+func scanPNG(data []byte) ([]byte, Metadata, error) {
+	var metadata Metadata
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, metadata, fmt.Errorf("%w: not a PNG", ErrMalformed)
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	for i := len(pngSignature); ; {
+		if i+8 > len(data) {
+			return nil, metadata, fmt.Errorf("%w: truncated chunk at byte %d", ErrMalformed, i)
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, metadata, fmt.Errorf("%w: chunk at byte %d overruns the file", ErrMalformed, i)
+		}
+		chunkType := string(data[i+4 : i+8])
+		chunkData := data[i+8 : i+8+length]
+		if binary.BigEndian.Uint32(data[i+8+length:]) != crc32.ChecksumIEEE(data[i+4:i+8+length]) {
+			return nil, metadata, fmt.Errorf("%w: %s chunk fails its checksum", ErrMalformed, chunkType)
+		}
+
+		// An uppercase first letter marks a critical chunk
+		if (chunkType[0] >= 'A' && chunkType[0] <= 'Z') || pngKept[chunkType] {
+			out = append(out, data[i:end]...)
+		} else if chunkType == "eXIf" {
+			metadata = parseEXIF(chunkData)
+		}
+		if chunkType == "IEND" {
+			return out, metadata, nil
+		}
+		i = end
+	}
+}
+
+// EXIF tags read from the TIFF structure EXIF stores them in
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+)
+
+// exifTimeLayout is how EXIF writes dates, in the camera's local time
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// ifd holds the entries of one TIFF image file directory
+type ifd struct {
+	tiff    []byte
+	order   binary.ByteOrder
+	entries map[uint16][]byte
+	types   map[uint16]uint16
+}
+
+// parseEXIF reads the tags Metadata holds. EXIF is best effort: whatever it can't make sense of is left out.
+func parseEXIF(tiff []byte) Metadata {
+	var metadata Metadata
+	if len(tiff) < 8 {
+		return metadata
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return metadata
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return metadata
+	}
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:]))
+	if orientation, ok := ifd0.short(tagOrientation); ok {
+		metadata.Orientation = orientation
+	}
+	metadata.Make = ifd0.ascii(tagMake)
+	metadata.Model = ifd0.ascii(tagModel)
+
+	taken, offset := ifd0.ascii(tagDateTime), ""
+	if exifOffset, ok := ifd0.long(tagExifIFD); ok {
+		exif := readIFD(tiff, order, exifOffset)
+		if original := exif.ascii(tagDateTimeOriginal); original != "" {
+			taken, offset = original, exif.ascii(tagOffsetTimeOriginal)
+		}
+	}
+	// Without an offset the camera's clock zone is unknown, so the time is kept as if it were UTC
+	location := time.UTC
+	if zone, err := time.Parse("-07:00", offset); err == nil {
+		location = zone.Location()
+	}
+	if t, err := time.ParseInLocation(exifTimeLayout, taken, location); err == nil {
+		metadata.TakenAt = t
+	}
+	return metadata
+}
+
+// typeSizes are the sizes in bytes of the TIFF field types, by type number
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) ifd {
+	dir := ifd{tiff: tiff, order: order, entries: map[uint16][]byte{}, types: map[uint16]uint16{}}
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return dir
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		at := int(offset) + 2 + n*12
+		if at+12 > len(tiff) {
+			break
+		}
+		tag, fieldType := order.Uint16(tiff[at:]), order.Uint16(tiff[at+2:])
+		size := uint64(typeSizes[fieldType]) * uint64(order.Uint32(tiff[at+4:]))
+		value := tiff[at+8 : at+12]
+		if size > 4 {
+			start := uint64(order.Uint32(tiff[at+8:]))
+			if start+size > uint64(len(tiff)) {
+				continue
+			}
+			value = tiff[start : start+size]
+		}
+		dir.entries[tag] = value[:min(size, uint64(len(value)))]
+		dir.types[tag] = fieldType
+	}
+	return dir
+}
+
+func (d ifd) short(tag uint16) (int, bool) {
+	if d.types[tag] != 3 || len(d.entries[tag]) < 2 {
+		return 0, false
+	}
+	return int(d.order.Uint16(d.entries[tag])), true
+}
+
+func (d ifd) long(tag uint16) (uint32, bool) {
+	if d.types[tag] != 4 || len(d.entries[tag]) < 4 {
+		return 0, false
+	}
+	return d.order.Uint32(d.entries[tag]), true
+}
+
+func (d ifd) ascii(tag uint16) string {
+	if d.types[tag] != 2 {
+		return ""
+	}
+	value, _, _ := bytes.Cut(d.entries[tag], []byte{0})
+	return strings.TrimSpace(string(value))
+}
+
+// orient turns img upright given its EXIF orientation
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// at maps a pixel of the upright image to the one it comes from
+	var at func(x, y int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2:
+		at = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		at = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		at = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		dw, dh = h, w
+		at = func(x, y int) (int, int) { return y, x }
+	case 6:
+		dw, dh = h, w
+		at = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		dw, dh = h, w
+		at = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		dw, dh = h, w
+		at = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := at(x, y)
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
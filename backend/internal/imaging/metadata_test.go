@@ -0,0 +1,246 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exif builds a little-endian EXIF block with the tags an iPhone photo has
+func exif(orientation int) []byte {
+	type entry struct {
+		tag, fieldType uint16
+		value          []byte
+	}
+	ascii := func(s string) []byte { return append([]byte(s), 0) }
+	short := binary.LittleEndian.AppendUint16(nil, uint16(orientation))
+	ifd0 := []entry{
+		{tagMake, 2, ascii("Apple")},
+		{tagModel, 2, ascii("iPhone 13")},
+		{tagOrientation, 3, short},
+		{tagExifIFD, 4, nil},
+	}
+	exifIFD := []entry{
+		{tagDateTimeOriginal, 2, ascii("2024:05:31 18:04:05")},
+		{tagOffsetTimeOriginal, 2, ascii("+02:00")},
+		{0x8825, 4, []byte{0, 0, 0, 0}}, // GPS IFD pointer, which is what stripping is for
+	}
+
+	// Layout: header, IFD0, Exif IFD, then the values too big for their entries
+	ifdSize := func(entries []entry) int { return 2 + 12*len(entries) + 4 }
+	exifOffset := 8 + ifdSize(ifd0)
+	dataOffset := exifOffset + ifdSize(exifIFD)
+	var data []byte
+	write := func(entries []entry) []byte {
+		b := binary.LittleEndian.AppendUint16(nil, uint16(len(entries)))
+		for _, e := range entries {
+			value := e.value
+			if e.tag == tagExifIFD {
+				value = binary.LittleEndian.AppendUint32(nil, uint32(exifOffset))
+			}
+			count := len(value)
+			if e.fieldType == 3 || e.fieldType == 4 {
+				count = 1
+			}
+			b = binary.LittleEndian.AppendUint16(b, e.tag)
+			b = binary.LittleEndian.AppendUint16(b, e.fieldType)
+			b = binary.LittleEndian.AppendUint32(b, uint32(count))
+			if len(value) > 4 {
+				b = binary.LittleEndian.AppendUint32(b, uint32(dataOffset+len(data)))
+				data = append(data, value...)
+			} else {
+				b = append(b, append(value, make([]byte, 4-len(value))...)...)
+			}
+		}
+		return binary.LittleEndian.AppendUint32(b, 0)
+	}
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = append(tiff, write(ifd0)...)
+	tiff = append(tiff, write(exifIFD)...)
+	return append(tiff, data...)
+}
+
+// segment builds a JPEG segment
+func segment(marker byte, payload []byte) []byte {
+	b := []byte{0xFF, marker}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+// chunk builds a PNG chunk
+func chunk(chunkType string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(b, chunkType...)
+	b = append(b, data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+}
+
+// marked is a width by height blue image with a red top left corner
+func marked(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{B: 255, A: 255})
+		}
+	}
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	return img
+}
+
+var (
+	icc = segment(markerAPP2, append([]byte("ICC_PROFILE\x00\x01\x01"), "profile"...))
+	xmp = []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>secret</x:xmpmeta>")
+)
+
+func photoJPEG(t *testing.T, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, marked(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	var b []byte
+	b = append(b, encoded[:2]...)
+	b = append(b, segment(markerAPP1, append([]byte("Exif\x00\x00"), exif(orientation)...))...)
+	b = append(b, segment(markerAPP1, xmp)...)
+	b = append(b, icc...)
+	b = append(b, segment(0xED, []byte("Photoshop 3.0\x00IPTC"))...)
+	b = append(b, segment(0xFE, []byte("a comment"))...)
+	b = append(b, encoded[2:]...)
+	// Like a phone's MPF gain map, an image appended after the end carries its own EXIF
+	return append(b, 0xFF, markerSOI, 0xFF, markerAPP1, 0x00, 0x04, 'i', 'P')
+}
+
+func photoPNG(t *testing.T, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, marked(40, 20)); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	// The signature and IHDR are the first 33 bytes
+	var b []byte
+	b = append(b, encoded[:33]...)
+	b = append(b, chunk("eXIf", exif(orientation))...)
+	b = append(b, chunk("iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), xmp...))...)
+	b = append(b, chunk("tEXt", []byte("Comment\x00a comment"))...)
+	b = append(b, chunk("gAMA", []byte{0, 0, 0xB1, 0x8F})...)
+	return append(b, encoded[33:]...)
+}
+
+func TestReadMetadata(t *testing.T) {
+	expected := Metadata{
+		Orientation: 6,
+		TakenAt:     time.Date(2024, 5, 31, 16, 4, 5, 0, time.UTC),
+		Make:        "Apple",
+		Model:       "iPhone 13",
+	}
+	for contentType, data := range map[string][]byte{"image/jpeg": photoJPEG(t, 6), "image/png": photoPNG(t, 6)} {
+		t.Run(contentType, func(t *testing.T) {
+			metadata, err := ReadMetadata(data, contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, expected.Orientation, metadata.Orientation)
+			assert.True(t, expected.TakenAt.Equal(metadata.TakenAt), metadata.TakenAt)
+			assert.Equal(t, expected.Make, metadata.Make)
+			assert.Equal(t, expected.Model, metadata.Model)
+			assert.Equal(t, "Apple iPhone 13", metadata.Camera())
+		})
+	}
+}
+
+func TestCamera(t *testing.T) {
+	assert.Equal(t, "Canon EOS R6", Metadata{Make: "Canon", Model: "Canon EOS R6"}.Camera())
+	assert.Equal(t, "Google Pixel 8", Metadata{Make: "Google", Model: "Pixel 8"}.Camera())
+	assert.Equal(t, "Pixel 8", Metadata{Model: "Pixel 8"}.Camera())
+	assert.Equal(t, "", Metadata{}.Camera())
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentType   string
+		data          []byte
+		width, height int
+		// red is where the top left corner of the original ends up
+		red image.Point
+	}{
+		{name: "upright jpeg", contentType: "image/jpeg", data: photoJPEG(t, 1), width: 40, height: 20, red: image.Pt(1, 1)},
+		{name: "jpeg to turn clockwise", contentType: "image/jpeg", data: photoJPEG(t, 6), width: 20, height: 40, red: image.Pt(18, 1)},
+		{name: "upside down jpeg", contentType: "image/jpeg", data: photoJPEG(t, 3), width: 40, height: 20, red: image.Pt(38, 18)},
+		{name: "upright png", contentType: "image/png", data: photoPNG(t, 1), width: 40, height: 20, red: image.Pt(1, 1)},
+		{name: "png to turn anticlockwise", contentType: "image/png", data: photoPNG(t, 8), width: 20, height: 40, red: image.Pt(1, 38)},
+		{name: "mirrored png", contentType: "image/png", data: photoPNG(t, 2), width: 40, height: 20, red: image.Pt(38, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, err := Strip(tt.data, tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, secret := range []string{"Exif", "eXIf", "iPhone", "xmpmeta", "IPTC", "a comment"} {
+				assert.NotContains(t, string(stripped), secret)
+			}
+			metadata, err := ReadMetadata(stripped, tt.contentType)
+			assert.NoError(t, err)
+			assert.Equal(t, Metadata{}, metadata)
+			if tt.contentType == "image/jpeg" {
+				assert.Contains(t, string(stripped), "ICC_PROFILE")
+			}
+
+			img, _, err := image.Decode(bytes.NewReader(stripped))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.width, img.Bounds().Dx())
+			assert.Equal(t, tt.height, img.Bounds().Dy())
+			r, _, b, _ := img.At(tt.red.X, tt.red.Y).RGBA()
+			assert.Greater(t, r, b, "expected red at %v", tt.red)
+		})
+	}
+}
+
+func TestStripMalformed(t *testing.T) {
+	truncated := photoPNG(t, 1)
+	truncated = truncated[:len(truncated)-20]
+	corrupt := photoPNG(t, 1)
+	corrupt[40] ^= 0xFF
+
+	for name, data := range map[string][]byte{"truncated png": truncated, "corrupt chunk": corrupt} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Strip(data, "image/png")
+			assert.ErrorIs(t, err, ErrMalformed)
+		})
+	}
+	_, err := Strip([]byte("GIF89a"), "image/gif")
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = Strip([]byte("not a jpeg"), "image/jpeg")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestVariantsUpright(t *testing.T) {
+	defer func(widths []int) { Widths = widths }(Widths)
+	Widths = []int{10}
+
+	variants, err := Variants(bytes.NewReader(photoJPEG(t, 6)), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, variants, 2) {
+		// The 40 by 20 original is 20 by 40 once turned
+		assert.Equal(t, "w10", variants[0].Name)
+		assert.Equal(t, 20, variants[0].Height)
+	}
+}
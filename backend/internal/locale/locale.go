@@ -0,0 +1,74 @@
+package locale
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"golang.org/x/text/language"
+)
+
+// Default is the locale posts and members have until told otherwise
+const Default = "en"
+
+// Parse canonicalizes a BCP 47 tag such as "es" or "pt-BR", the form locales are stored and compared in
+func Parse(value string) (string, error) {
+	tag, err := language.Parse(value)
+	if err != nil || tag == language.Und {
+		return "", fmt.Errorf("locale must be a language tag such as en or es")
+	}
+	return tag.String(), nil
+}
+
+// Preferences lists the locales r asks for, most wanted first: ?lang= ahead of the Accept-Language header.
+// Unparseable values are skipped.
+func Preferences(r *http.Request) []string {
+	var tags []language.Tag
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if tag, err := language.Parse(lang); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	accepted, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	return expand(append(tags, accepted...))
+}
+
+// For is the preference list of a member who picked locale, for choosing what to email them
+func For(locale string) []string {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return []string{Default}
+	}
+	return expand([]language.Tag{tag})
+}
+
+// Choose picks the first preference that is available, or fallback when none of them are
+func Choose(preferences, available []string, fallback string) string {
+	for _, preference := range preferences {
+		if slices.Contains(available, preference) {
+			return preference
+		}
+	}
+	return fallback
+}
+
+// anyLanguage is what ParseAcceptLanguage makes of the "*" wildcard
+var anyLanguage = language.MustParseBase("mul")
+
+// expand follows each regional tag with its base language, so es-MX readers still get a Spanish translation,
+// and drops duplicates and the "*" wildcard
+func expand(tags []language.Tag) []string {
+	var locales []string
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		if tag == language.Und || base == anyLanguage {
+			continue
+		}
+		for _, locale := range []string{tag.String(), base.String()} {
+			if !slices.Contains(locales, locale) {
+				locales = append(locales, locale)
+			}
+		}
+	}
+	return locales
+}
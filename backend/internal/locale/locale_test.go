@@ -0,0 +1,49 @@
+package locale
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferences(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		acceptLanguage string
+		expected       []string
+	}{
+		{name: "nothing_asked", target: "/", expected: nil},
+		{name: "accept_language_by_quality", target: "/", acceptLanguage: "en;q=0.5, es-MX", expected: []string{"es-MX", "es", "en"}},
+		{name: "lang_wins", target: "/?lang=es", acceptLanguage: "en-US", expected: []string{"es", "en-US", "en"}},
+		{name: "wildcard_dropped", target: "/", acceptLanguage: "*", expected: nil},
+		{name: "garbage_skipped", target: "/?lang=%21%21", acceptLanguage: "fr", expected: []string{"fr"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			assert.Equal(t, tt.expected, Preferences(req))
+		})
+	}
+}
+
+func TestChoose(t *testing.T) {
+	available := []string{"en", "es"}
+	assert.Equal(t, "es", Choose([]string{"es-MX", "es", "en"}, available, "en"))
+	assert.Equal(t, "en", Choose([]string{"fr"}, available, "en"))
+	assert.Equal(t, "en", Choose(nil, available, "en"))
+	assert.Equal(t, []string{"pt-BR", "pt"}, For("pt-br"))
+}
+
+func TestParse(t *testing.T) {
+	parsed, err := Parse("ES")
+	assert.NoError(t, err)
+	assert.Equal(t, "es", parsed)
+	_, err = Parse("not a locale")
+	assert.Error(t, err)
+}
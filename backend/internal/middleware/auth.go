@@ -12,12 +12,15 @@ import (
 
 type AuthMiddleware struct {
 	authService *authorization.AuthService
+	mediaOrigin string
 	logger      logger.Logger
 }
 
-func NewAuthMiddleware(authService *authorization.AuthService, logger logger.Logger) *AuthMiddleware {
+// NewAuthMiddleware lets pages load images and video from mediaOrigin, the storage host media URLs point at
+func NewAuthMiddleware(authService *authorization.AuthService, mediaOrigin string, logger logger.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
 		authService: authService,
+		mediaOrigin: mediaOrigin,
 		logger:      logger,
 	}
 }
@@ -67,7 +70,8 @@ func (m *AuthMiddleware) EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 		}
 
@@ -81,12 +85,16 @@ func (m *AuthMiddleware) EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 }
 
 func (m *AuthMiddleware) SecurityHeaders(next http.HandlerFunc) http.HandlerFunc {
+	mediaSrc := "'self'"
+	if m.mediaOrigin != "" {
+		mediaSrc += " " + m.mediaOrigin
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Security headers
 		w.Header().Set("Content-Security-Policy",
 			"default-src 'self'; "+
-				"img-src 'self' https://kylerjacobsonmedia.blob.core.windows.net; "+
-				"media-src 'self' https://kylerjacobsonmedia.blob.core.windows.net;")
+				"img-src "+mediaSrc+"; "+
+				"media-src "+mediaSrc+";")
 		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
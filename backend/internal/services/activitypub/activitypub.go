@@ -0,0 +1,545 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ap_models "github.com/KylerJacobson/blog/backend/internal/api/types/activitypub"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	ap_repo "github.com/KylerJacobson/blog/backend/internal/db/activitypub"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/services/markdown"
+	"github.com/KylerJacobson/blog/backend/logger"
+	v5 "github.com/jackc/pgx/v5"
+)
+
+const (
+	// OutboxPageSize is how many activities one outbox page holds
+	OutboxPageSize = 20
+	// MaxDocumentSize caps what the blog reads from remote servers and accepts in its inbox
+	MaxDocumentSize = 1 << 20
+	// DescriptionLength matches what link previews show
+	DescriptionLength = 200
+
+	keyBits = 2048
+	accept  = `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+)
+
+var (
+	// ErrInvalidActivity means an inbox delivery isn't an activity the blog can act on
+	ErrInvalidActivity = errors.New("invalid activity")
+	// ErrNotFederated means the post doesn't exist or isn't public, which to the fediverse is the same thing
+	ErrNotFederated = errors.New("post is not federated")
+	// ErrInvalidCursor means an outbox page cursor couldn't be read
+	ErrInvalidCursor = errors.New("cursor is malformed")
+)
+
+// Profile is how the blog's actor presents itself
+type Profile struct {
+	// Username is the part before the @ in @username@host
+	Username string
+	Name     string
+	Summary  string
+}
+
+// Federator is the blog's single ActivityPub actor. It serves the actor's documents, keeps track of followers
+// from inbox deliveries, and delivers newly published public posts to them. Posts that are restricted or
+// shared only with some readers never leave the server.
+type Federator struct {
+	postsRepository       posts_repo.PostsRepository
+	activityPubRepository ap_repo.ActivityPubRepository
+	renderer              *markdown.Renderer
+	client                *http.Client
+	key                   *rsa.PrivateKey
+	publicKeyPem          string
+	siteURL               string
+	host                  string
+	profile               Profile
+	logger                logger.Logger
+}
+
+// New loads the actor's signing key, creating it on first start. siteURL is the public origin, e.g.
+// https://kylerjacobson.dev, which is also the host in the actor's @username@host handle.
+func New(postsRepo posts_repo.PostsRepository, apRepo ap_repo.ActivityPubRepository, client *http.Client, siteURL string, profile Profile, logger logger.Logger) (*Federator, error) {
+	site, err := url.Parse(siteURL)
+	if err != nil || site.Host == "" {
+		return nil, fmt.Errorf("site URL %q has no host", siteURL)
+	}
+	key, err := loadKey(apRepo)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyPem, err := encodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Federator{
+		postsRepository:       postsRepo,
+		activityPubRepository: apRepo,
+		renderer:              markdown.New(0),
+		client:                client,
+		key:                   key,
+		publicKeyPem:          publicKeyPem,
+		siteURL:               strings.TrimRight(siteURL, "/"),
+		host:                  site.Host,
+		profile:               profile,
+		logger:                logger,
+	}, nil
+}
+
+func loadKey(apRepo ap_repo.ActivityPubRepository) (*rsa.PrivateKey, error) {
+	stored, err := apRepo.GetActorKey()
+	if errors.Is(err, v5.ErrNoRows) {
+		stored, err = createKey(apRepo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodePrivateKey(stored)
+}
+
+func createKey(apRepo ap_repo.ActivityPubRepository) (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return "", err
+	}
+	err = apRepo.CreateActorKey(encodePrivateKey(key))
+	if err != nil {
+		return "", err
+	}
+	// Another instance may have created its own key in the meantime; everyone uses the stored one
+	return apRepo.GetActorKey()
+}
+
+func (f *Federator) ActorId() string {
+	return f.siteURL + "/ap/actor"
+}
+
+func (f *Federator) keyId() string {
+	return f.ActorId() + "#main-key"
+}
+
+func (f *Federator) followersId() string {
+	return f.siteURL + "/ap/followers"
+}
+
+func (f *Federator) outboxId() string {
+	return f.siteURL + "/ap/outbox"
+}
+
+func (f *Federator) objectId(postId int) string {
+	return fmt.Sprintf("%s/ap/posts/%d", f.siteURL, postId)
+}
+
+// Actor is the actor document remote servers fetch to learn the blog's inbox and key
+func (f *Federator) Actor() ap_models.Actor {
+	return ap_models.Actor{
+		Context:           ap_models.Context,
+		Id:                f.ActorId(),
+		Type:              "Service",
+		PreferredUsername: f.profile.Username,
+		Name:              f.profile.Name,
+		Summary:           f.profile.Summary,
+		URL:               f.siteURL,
+		Inbox:             f.siteURL + "/ap/inbox",
+		Outbox:            f.outboxId(),
+		Followers:         f.followersId(),
+		PublicKey: ap_models.PublicKey{
+			Id:           f.keyId(),
+			Owner:        f.ActorId(),
+			PublicKeyPem: f.publicKeyPem,
+		},
+	}
+}
+
+// WebFinger resolves acct:username@host, or the actor's own id, to the actor. ok is false for anything else.
+func (f *Federator) WebFinger(resource string) (finger ap_models.WebFinger, ok bool) {
+	subject := "acct:" + f.profile.Username + "@" + f.host
+	if !strings.EqualFold(resource, subject) && resource != f.ActorId() {
+		return finger, false
+	}
+	return ap_models.WebFinger{
+		Subject: subject,
+		Aliases: []string{f.ActorId()},
+		Links: []ap_models.WebFingerLink{
+			{Rel: "self", Type: ap_models.ContentType, Href: f.ActorId()},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: f.siteURL},
+		},
+	}, true
+}
+
+// Followers only reveals how many followers there are, not who they are
+func (f *Federator) Followers() (ap_models.OrderedCollection, error) {
+	count, err := f.activityPubRepository.CountFollowers()
+	if err != nil {
+		return ap_models.OrderedCollection{}, err
+	}
+	return ap_models.OrderedCollection{
+		Context:    ap_models.Context,
+		Id:         f.followersId(),
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	}, nil
+}
+
+// Outbox counts the public posts and points at the first page of them
+func (f *Federator) Outbox() (ap_models.OrderedCollection, error) {
+	count, err := f.postsRepository.CountPublicPosts()
+	if err != nil {
+		return ap_models.OrderedCollection{}, err
+	}
+	return ap_models.OrderedCollection{
+		Context:    ap_models.Context,
+		Id:         f.outboxId(),
+		Type:       "OrderedCollection",
+		TotalItems: count,
+		First:      f.outboxId() + "?page=true",
+	}, nil
+}
+
+// OutboxPage lists Create activities for public posts newest first, resuming after cursor when it is set
+func (f *Federator) OutboxPage(cursor string) (ap_models.OrderedCollectionPage, error) {
+	filter := post_models.PostFilter{Limit: OutboxPageSize}
+	pageId := f.outboxId() + "?page=true"
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return ap_models.OrderedCollectionPage{}, err
+		}
+		filter.After = after
+		pageId = f.outboxId() + "?cursor=" + url.QueryEscape(cursor)
+	}
+	// The zero viewer is an anonymous visitor, so only public posts come back
+	posts, err := f.postsRepository.GetPosts(filter)
+	if err != nil {
+		return ap_models.OrderedCollectionPage{}, err
+	}
+	page := ap_models.OrderedCollectionPage{
+		Context:      ap_models.Context,
+		Id:           pageId,
+		Type:         "OrderedCollectionPage",
+		PartOf:       f.outboxId(),
+		OrderedItems: []any{},
+	}
+	for i := range posts {
+		page.OrderedItems = append(page.OrderedItems, f.create(&posts[i]))
+	}
+	if len(posts) == OutboxPageSize {
+		last := posts[len(posts)-1]
+		page.Next = f.outboxId() + "?cursor=" + url.QueryEscape(encodeCursor(post_models.PostCursor{CreatedAt: last.CreatedAt, PostId: last.PostId}))
+	}
+	return page, nil
+}
+
+// Object returns the Article for a public post, or ErrNotFederated
+func (f *Federator) Object(postId int) (ap_models.Article, error) {
+	post, err := f.publicPost(postId)
+	if err != nil {
+		return ap_models.Article{}, err
+	}
+	article := f.article(post)
+	article.Context = ap_models.Context
+	return article, nil
+}
+
+// publicPost loads a post only if anyone may read it
+func (f *Federator) publicPost(postId int) (*post_models.Post, error) {
+	post, err := f.postsRepository.GetPostById(postId)
+	if err != nil {
+		if errors.Is(err, v5.ErrNoRows) {
+			return nil, ErrNotFederated
+		}
+		return nil, err
+	}
+	if post.Status != post_models.StatusPublished {
+		return nil, ErrNotFederated
+	}
+	public, err := f.postsRepository.CanView(postId, post_models.Viewer{})
+	if err != nil {
+		return nil, err
+	}
+	if !public {
+		return nil, ErrNotFederated
+	}
+	return post, nil
+}
+
+func (f *Federator) article(post *post_models.Post) ap_models.Article {
+	content := ""
+	if rendered, err := f.renderer.Render(post.Content); err == nil {
+		content = rendered.HTML
+	} else {
+		f.logger.Sugar().Warnf("error rendering post %d for federation : %v", post.PostId, err)
+	}
+	published := post.CreatedAt
+	if post.PublishAt != nil {
+		published = *post.PublishAt
+	}
+	article := ap_models.Article{
+		Id:           f.objectId(post.PostId),
+		Type:         "Article",
+		AttributedTo: f.ActorId(),
+		Name:         post.Title,
+		Summary:      f.renderer.Excerpt(post.Content, DescriptionLength),
+		Content:      content,
+		URL:          fmt.Sprintf("%s/post/%d", f.siteURL, post.PostId),
+		Published:    published.UTC(),
+		To:           []string{ap_models.Public},
+		Cc:           []string{f.followersId()},
+	}
+	for _, tag := range post.Tags {
+		// Hashtags can't contain hyphens
+		article.Tag = append(article.Tag, ap_models.Tag{Type: "Hashtag", Name: "#" + strings.ReplaceAll(tag, "-", "_")})
+	}
+	return article
+}
+
+func (f *Federator) create(post *post_models.Post) ap_models.Activity {
+	article := f.article(post)
+	return ap_models.Activity{
+		Id:        article.Id + "/activity",
+		Type:      "Create",
+		Actor:     f.ActorId(),
+		Published: &article.Published,
+		To:        article.To,
+		Cc:        article.Cc,
+		Object:    article,
+	}
+}
+
+// FederatePost delivers a newly published post to every follower in the background. Posts that aren't public
+// are skipped there, so callers can hand over every post they publish.
+func (f *Federator) FederatePost(postId int) {
+	go func() {
+		err := f.DeliverPost(postId)
+		if err != nil {
+			f.logger.Sugar().Errorf("error federating post %d : %v", postId, err)
+		}
+	}()
+}
+
+// DeliverPost sends a Create activity for a public post to each follower's inbox, sharing one delivery
+// between followers on the same server. It keeps going past failing inboxes and returns the last error.
+func (f *Federator) DeliverPost(postId int) error {
+	post, err := f.publicPost(postId)
+	if errors.Is(err, ErrNotFederated) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	followers, err := f.activityPubRepository.GetFollowers()
+	if err != nil {
+		return err
+	}
+	activity := f.create(post)
+	activity.Context = ap_models.Context
+
+	delivered := map[string]bool{}
+	var lastErr error
+	for _, follower := range followers {
+		inbox := follower.Inbox
+		if follower.SharedInbox != nil && *follower.SharedInbox != "" {
+			inbox = *follower.SharedInbox
+		}
+		if delivered[inbox] {
+			continue
+		}
+		delivered[inbox] = true
+		if err := f.deliver(inbox, activity); err != nil {
+			f.logger.Sugar().Warnf("error delivering post %d to %s : %v", postId, inbox, err)
+			lastErr = err
+		}
+	}
+	f.logger.Sugar().Infof("federated post %d to %d inboxes", postId, len(delivered))
+	return lastErr
+}
+
+// HandleActivity acts on a delivery to the inbox. The request has to be signed by the activity's actor.
+// Follow and Undo of a Follow change the followers; everything else is accepted and ignored.
+func (f *Federator) HandleActivity(r *http.Request, body []byte) error {
+	keyId, err := SignatureKeyId(r)
+	if err != nil {
+		return err
+	}
+	signer, err := f.fetchKeyOwner(keyId)
+	if err != nil {
+		return fmt.Errorf("%w: fetching key %s: %v", ErrBadSignature, keyId, err)
+	}
+	key, err := decodePublicKey(signer.PublicKey.PublicKeyPem)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if err := Verify(r, body, key, time.Now()); err != nil {
+		return err
+	}
+
+	var activity ap_models.IncomingActivity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+	if activity.Actor != signer.Id {
+		return fmt.Errorf("%w: signed by %s on behalf of %s", ErrBadSignature, signer.Id, activity.Actor)
+	}
+	switch activity.Type {
+	case "Follow":
+		return f.follow(signer, activity, body)
+	case "Undo":
+		return f.undo(activity)
+	}
+	return nil
+}
+
+func (f *Federator) follow(follower *ap_models.Actor, activity ap_models.IncomingActivity, body []byte) error {
+	var object string
+	if err := json.Unmarshal(activity.Object, &object); err != nil || object != f.ActorId() {
+		return fmt.Errorf("%w: only %s can be followed", ErrInvalidActivity, f.ActorId())
+	}
+	if follower.Inbox == "" {
+		return fmt.Errorf("%w: %s has no inbox", ErrInvalidActivity, follower.Id)
+	}
+	record := ap_models.Follower{ActorId: follower.Id, Inbox: follower.Inbox}
+	if follower.Endpoints != nil && follower.Endpoints.SharedInbox != "" {
+		record.SharedInbox = &follower.Endpoints.SharedInbox
+	}
+	if err := f.activityPubRepository.AddFollower(record); err != nil {
+		return err
+	}
+	f.logger.Sugar().Infof("%s followed the blog", follower.Id)
+
+	acceptance := ap_models.Activity{
+		Context: ap_models.Context,
+		Id:      fmt.Sprintf("%s#accepts/%d", f.ActorId(), time.Now().UnixNano()),
+		Type:    "Accept",
+		Actor:   f.ActorId(),
+		Object:  json.RawMessage(body),
+	}
+	go func() {
+		if err := f.deliver(follower.Inbox, acceptance); err != nil {
+			f.logger.Sugar().Warnf("error accepting the follow from %s : %v", follower.Id, err)
+		}
+	}()
+	return nil
+}
+
+func (f *Federator) undo(activity ap_models.IncomingActivity) error {
+	var undone ap_models.IncomingActivity
+	if err := json.Unmarshal(activity.Object, &undone); err != nil {
+		// Only an id; without the activity itself there's no telling what was undone
+		return nil
+	}
+	if undone.Type != "Follow" {
+		return nil
+	}
+	if undone.Actor != "" && undone.Actor != activity.Actor {
+		return fmt.Errorf("%w: %s can't undo a follow by %s", ErrInvalidActivity, activity.Actor, undone.Actor)
+	}
+	if err := f.activityPubRepository.RemoveFollower(activity.Actor); err != nil {
+		return err
+	}
+	f.logger.Sugar().Infof("%s unfollowed the blog", activity.Actor)
+	return nil
+}
+
+// fetchKeyOwner fetches the actor a keyId belongs to. Most servers serve the actor at the key's URL; the rest
+// serve the key alone and name its owner.
+func (f *Federator) fetchKeyOwner(keyId string) (*ap_models.Actor, error) {
+	var doc struct {
+		ap_models.Actor
+		Owner        string `json:"owner"`
+		PublicKeyPem string `json:"publicKeyPem"`
+	}
+	if err := f.fetch(keyId, &doc); err != nil {
+		return nil, err
+	}
+	actor := &doc.Actor
+	if doc.Owner != "" && doc.PublicKeyPem != "" {
+		actor = &ap_models.Actor{}
+		if err := f.fetch(doc.Owner, actor); err != nil {
+			return nil, err
+		}
+	}
+	if actor.PublicKey.Id != keyId {
+		return nil, fmt.Errorf("actor %s does not own key %s", actor.Id, keyId)
+	}
+	return actor, nil
+}
+
+// fetch GETs an ActivityPub document, signing the request for servers that only answer signed fetches
+func (f *Federator) fetch(id string, v any) error {
+	target, err := url.Parse(id)
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") {
+		return fmt.Errorf("%s is not an http(s) URL", id)
+	}
+	target.Fragment = ""
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	if err := Sign(req, nil, f.keyId(), f.key); err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s returned %s", id, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, MaxDocumentSize)).Decode(v)
+}
+
+// deliver POSTs a signed activity to an inbox
+func (f *Federator) deliver(inbox string, activity any) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ap_models.ContentType)
+	if err := Sign(req, body, f.keyId(), f.key); err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, MaxDocumentSize))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("inbox %s returned %s", inbox, resp.Status)
+	}
+	return nil
+}
+
+func encodeCursor(cursor post_models.PostCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(value string) (*post_models.PostCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor post_models.PostCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.PostId == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
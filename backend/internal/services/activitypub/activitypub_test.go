@@ -0,0 +1,345 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ap_models "github.com/KylerJacobson/blog/backend/internal/api/types/activitypub"
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	ap_repo "github.com/KylerJacobson/blog/backend/internal/db/activitypub"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	v5 "github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	posts  map[int]*post_models.Post
+	public map[int]bool
+}
+
+func (s *stubPostsRepository) GetPostById(postId int) (*post_models.Post, error) {
+	post, ok := s.posts[postId]
+	if !ok {
+		return nil, v5.ErrNoRows
+	}
+	return post, nil
+}
+
+func (s *stubPostsRepository) CanView(postId int, viewer post_models.Viewer) (bool, error) {
+	return s.public[postId], nil
+}
+
+type stubActivityPubRepository struct {
+	ap_repo.ActivityPubRepository
+	mu        sync.Mutex
+	key       string
+	followers []ap_models.Follower
+}
+
+func (s *stubActivityPubRepository) GetActorKey() (string, error) {
+	if s.key == "" {
+		return "", v5.ErrNoRows
+	}
+	return s.key, nil
+}
+
+func (s *stubActivityPubRepository) CreateActorKey(privateKeyPem string) error {
+	s.key = privateKeyPem
+	return nil
+}
+
+func (s *stubActivityPubRepository) GetFollowers() ([]ap_models.Follower, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ap_models.Follower{}, s.followers...), nil
+}
+
+func (s *stubActivityPubRepository) AddFollower(follower ap_models.Follower) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.followers = append(s.followers, follower)
+	return nil
+}
+
+func (s *stubActivityPubRepository) RemoveFollower(actorId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := []ap_models.Follower{}
+	for _, follower := range s.followers {
+		if follower.ActorId != actorId {
+			kept = append(kept, follower)
+		}
+	}
+	s.followers = kept
+	return nil
+}
+
+// fakeInbox is a remote server's inbox that only records deliveries signed by the blog
+type fakeInbox struct {
+	server    *httptest.Server
+	key       *rsa.PublicKey
+	delivered chan ap_models.Activity
+}
+
+func newFakeInbox(t *testing.T, key *rsa.PublicKey) *fakeInbox {
+	inbox := &fakeInbox{key: key, delivered: make(chan ap_models.Activity, 10)}
+	inbox.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(r, body, inbox.key, time.Now()); err != nil {
+			t.Errorf("delivery to %s has a bad signature: %v", r.URL.Path, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var activity ap_models.Activity
+		json.Unmarshal(body, &activity)
+		inbox.delivered <- activity
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(inbox.server.Close)
+	return inbox
+}
+
+// received drains what was delivered, waiting briefly for background deliveries
+func (i *fakeInbox) received(want int) []ap_models.Activity {
+	var activities []ap_models.Activity
+	timeout := time.After(2 * time.Second)
+	for len(activities) < want {
+		select {
+		case activity := <-i.delivered:
+			activities = append(activities, activity)
+		case <-timeout:
+			return activities
+		}
+	}
+	select {
+	case activity := <-i.delivered:
+		activities = append(activities, activity)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return activities
+}
+
+func newFederator(t *testing.T, postsRepo *stubPostsRepository, apRepo *stubActivityPubRepository) *Federator {
+	f, err := New(postsRepo, apRepo, http.DefaultClient, "https://blog.example/", Profile{Username: "blog", Name: "Blog"}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func samplePosts() *stubPostsRepository {
+	published := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &stubPostsRepository{
+		posts: map[int]*post_models.Post{
+			1: {PostId: 1, Title: "Public", Content: "Hello **fediverse**", Status: post_models.StatusPublished, PublishAt: &published, Tags: []string{"go", "self-hosting"}},
+			2: {PostId: 2, Title: "Restricted", Content: "Family only", Restricted: true, Status: post_models.StatusPublished},
+			3: {PostId: 3, Title: "Draft", Content: "Not yet", Status: post_models.StatusDraft},
+		},
+		public: map[int]bool{1: true, 3: true},
+	}
+}
+
+func TestDeliverPost(t *testing.T) {
+	apRepo := &stubActivityPubRepository{}
+	f := newFederator(t, samplePosts(), apRepo)
+	inbox := newFakeInbox(t, &f.key.PublicKey)
+	shared := inbox.server.URL + "/inbox"
+	apRepo.followers = []ap_models.Follower{
+		{ActorId: "https://a.example/users/ann", Inbox: inbox.server.URL + "/users/ann/inbox", SharedInbox: &shared},
+		{ActorId: "https://a.example/users/bob", Inbox: inbox.server.URL + "/users/bob/inbox", SharedInbox: &shared},
+		{ActorId: "https://b.example/users/cat", Inbox: inbox.server.URL + "/users/cat/inbox"},
+	}
+
+	tests := []struct {
+		name       string
+		postId     int
+		deliveries int
+	}{
+		{name: "public_post_reaches_each_server_once", postId: 1, deliveries: 2},
+		{name: "restricted_post_never_federates", postId: 2, deliveries: 0},
+		{name: "draft_never_federates", postId: 3, deliveries: 0},
+		{name: "missing_post", postId: 4, deliveries: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.DeliverPost(tt.postId)
+
+			assert.NoError(t, err)
+			activities := inbox.received(tt.deliveries)
+			assert.Len(t, activities, tt.deliveries)
+			for _, activity := range activities {
+				assert.Equal(t, "Create", activity.Type)
+				assert.Equal(t, "https://blog.example/ap/actor", activity.Actor)
+				article := activity.Object.(map[string]any)
+				assert.Equal(t, "https://blog.example/ap/posts/1", article["id"])
+				assert.Equal(t, "Public", article["name"])
+				assert.Contains(t, article["content"], "<strong>fediverse</strong>")
+				assert.Equal(t, []any{ap_models.Public}, article["to"])
+				assert.Equal(t, "2026-03-01T12:00:00Z", article["published"])
+			}
+		})
+	}
+}
+
+func TestObjectHidesPostsThatArentPublic(t *testing.T) {
+	f := newFederator(t, samplePosts(), &stubActivityPubRepository{})
+
+	article, err := f.Object(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ap_models.Tag{{Type: "Hashtag", Name: "#go"}, {Type: "Hashtag", Name: "#self_hosting"}}, article.Tag)
+
+	for _, postId := range []int{2, 3, 4} {
+		_, err := f.Object(postId)
+		assert.ErrorIs(t, err, ErrNotFederated, "post %d", postId)
+	}
+}
+
+// remoteActor is an account on another server, with its actor document and an inbox
+type remoteActor struct {
+	key   *rsa.PrivateKey
+	id    string
+	inbox *fakeInbox
+}
+
+func newRemoteActor(t *testing.T, blogKey *rsa.PublicKey) *remoteActor {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actor := &remoteActor{key: key, inbox: newFakeInbox(t, blogKey)}
+	publicKeyPem, _ := encodePublicKey(&key.PublicKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/ann" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", ap_models.ContentType)
+		json.NewEncoder(w).Encode(ap_models.Actor{
+			Id:                actor.id,
+			Type:              "Person",
+			PreferredUsername: "ann",
+			Inbox:             actor.inbox.server.URL + "/users/ann/inbox",
+			PublicKey:         ap_models.PublicKey{Id: actor.id + "#main-key", Owner: actor.id, PublicKeyPem: publicKeyPem},
+		})
+	}))
+	t.Cleanup(server.Close)
+	actor.id = server.URL + "/users/ann"
+	return actor
+}
+
+// deliver builds a request to the blog's inbox signed by the remote actor
+func (a *remoteActor) deliver(t *testing.T, activity map[string]any) (*http.Request, []byte) {
+	body, _ := json.Marshal(activity)
+	r := httptest.NewRequest(http.MethodPost, "https://blog.example/ap/inbox", bytes.NewReader(body))
+	if err := Sign(r, body, a.id+"#main-key", a.key); err != nil {
+		t.Fatal(err)
+	}
+	return r, body
+}
+
+func TestFollowAndUndo(t *testing.T) {
+	apRepo := &stubActivityPubRepository{}
+	f := newFederator(t, samplePosts(), apRepo)
+	ann := newRemoteActor(t, &f.key.PublicKey)
+	follow := map[string]any{"id": ann.id + "#follow", "type": "Follow", "actor": ann.id, "object": "https://blog.example/ap/actor"}
+
+	err := f.HandleActivity(ann.deliver(t, follow))
+	assert.NoError(t, err)
+	followers, _ := apRepo.GetFollowers()
+	assert.Len(t, followers, 1)
+	assert.Equal(t, ann.id, followers[0].ActorId)
+	accepted := ann.inbox.received(1)
+	if assert.Len(t, accepted, 1) {
+		assert.Equal(t, "Accept", accepted[0].Type)
+		assert.Equal(t, ann.id+"#follow", accepted[0].Object.(map[string]any)["id"])
+	}
+
+	err = f.HandleActivity(ann.deliver(t, map[string]any{"id": ann.id + "#undo", "type": "Undo", "actor": ann.id, "object": follow}))
+	assert.NoError(t, err)
+	followers, _ = apRepo.GetFollowers()
+	assert.Empty(t, followers)
+}
+
+func TestHandleActivityRejects(t *testing.T) {
+	apRepo := &stubActivityPubRepository{}
+	f := newFederator(t, samplePosts(), apRepo)
+	ann := newRemoteActor(t, &f.key.PublicKey)
+
+	tests := []struct {
+		name    string
+		request func() (*http.Request, []byte)
+		wantErr error
+	}{
+		{
+			name: "unsigned",
+			request: func() (*http.Request, []byte) {
+				body := []byte(`{"type":"Follow"}`)
+				return httptest.NewRequest(http.MethodPost, "https://blog.example/ap/inbox", bytes.NewReader(body)), body
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "tampered_body",
+			request: func() (*http.Request, []byte) {
+				r, body := ann.deliver(t, map[string]any{"type": "Follow", "actor": ann.id, "object": "https://blog.example/ap/actor"})
+				return r, bytes.Replace(body, []byte("Follow"), []byte("Block"), 1)
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "signed_for_someone_else",
+			request: func() (*http.Request, []byte) {
+				return ann.deliver(t, map[string]any{"type": "Follow", "actor": "https://a.example/users/bob", "object": "https://blog.example/ap/actor"})
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "stale_date",
+			request: func() (*http.Request, []byte) {
+				r, body := ann.deliver(t, map[string]any{"type": "Follow", "actor": ann.id, "object": "https://blog.example/ap/actor"})
+				r.Header.Set("Date", time.Now().Add(-2*MaxClockSkew).UTC().Format(http.TimeFormat))
+				return r, body
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "follow_of_another_actor",
+			request: func() (*http.Request, []byte) {
+				return ann.deliver(t, map[string]any{"type": "Follow", "actor": ann.id, "object": "https://blog.example/users/someone"})
+			},
+			wantErr: ErrInvalidActivity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.HandleActivity(tt.request())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			followers, _ := apRepo.GetFollowers()
+			assert.Empty(t, followers)
+		})
+	}
+}
+
+func TestWebFinger(t *testing.T) {
+	f := newFederator(t, samplePosts(), &stubActivityPubRepository{})
+
+	finger, ok := f.WebFinger("acct:blog@blog.example")
+	assert.True(t, ok)
+	assert.Equal(t, "https://blog.example/ap/actor", finger.Links[0].Href)
+
+	_, ok = f.WebFinger("acct:someone@blog.example")
+	assert.False(t, ok)
+	assert.True(t, strings.HasPrefix(f.Actor().PublicKey.PublicKeyPem, "-----BEGIN PUBLIC KEY-----"))
+}
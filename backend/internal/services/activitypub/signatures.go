@@ -0,0 +1,184 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how far a signed request's Date may be from now before it is treated as a replay
+const MaxClockSkew = time.Hour
+
+// ErrBadSignature wraps every reason a request's HTTP signature is rejected
+var ErrBadSignature = errors.New("bad signature")
+
+// Sign adds Date, Digest and an HTTP signature (draft-cavage-http-signatures, as Mastodon speaks it) to r.
+// body must be what r sends; GET requests pass nil and go without a Digest.
+func Sign(r *http.Request, body []byte, keyId string, key *rsa.PrivateKey) error {
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		r.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+	hash := sha256.Sum256([]byte(signingString(r, headers)))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyId, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// SignatureKeyId returns the keyId r claims to be signed with, so the caller can fetch the key to Verify with
+func SignatureKeyId(r *http.Request) (string, error) {
+	params, err := signatureParams(r)
+	if err != nil {
+		return "", err
+	}
+	return params["keyId"], nil
+}
+
+// Verify checks r's HTTP signature against key. The signature has to cover the request target, host and date,
+// and for requests with a body the digest, which has to match body.
+func Verify(r *http.Request, body []byte, key *rsa.PublicKey, now time.Time) error {
+	params, err := signatureParams(r)
+	if err != nil {
+		return err
+	}
+	switch params["algorithm"] {
+	// hs2019 leaves the algorithm to the key, and actor keys are RSA
+	case "", "rsa-sha256", "hs2019":
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %s", ErrBadSignature, params["algorithm"])
+	}
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		required = append(required, "digest")
+	}
+	for _, header := range required {
+		if !slices.Contains(headers, header) {
+			return fmt.Errorf("%w: %s is not signed", ErrBadSignature, header)
+		}
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: unreadable date", ErrBadSignature)
+	}
+	if date.Before(now.Add(-MaxClockSkew)) || date.After(now.Add(MaxClockSkew)) {
+		return fmt.Errorf("%w: date is too far from now", ErrBadSignature)
+	}
+	if body != nil && r.Header.Get("Digest") != digest(body) {
+		return fmt.Errorf("%w: digest does not match the body", ErrBadSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64", ErrBadSignature)
+	}
+	hash := sha256.Sum256([]byte(signingString(r, headers)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return fmt.Errorf("%w: signature does not match", ErrBadSignature)
+	}
+	return nil
+}
+
+// signatureParams splits the Signature header into its key="value" parameters
+func signatureParams(r *http.Request) (map[string]string, error) {
+	header := r.Header.Get("Signature")
+	if header == "" {
+		return nil, fmt.Errorf("%w: request is not signed", ErrBadSignature)
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed parameter %q", ErrBadSignature, part)
+		}
+		params[key] = strings.Trim(value, `"`)
+	}
+	if params["keyId"] == "" || params["signature"] == "" {
+		return nil, fmt.Errorf("%w: keyId and signature are required", ErrBadSignature)
+	}
+	return params, nil
+}
+
+func signingString(r *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, header := range headers {
+		var value string
+		switch header {
+		case "(request-target)":
+			value = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		default:
+			value = strings.Join(r.Header.Values(header), ", ")
+		}
+		lines[i] = header + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func encodePrivateKey(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func decodePrivateKey(value string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func encodePublicKey(key *rsa.PublicKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})), nil
+}
+
+// decodePublicKey reads the PKIX keys Mastodon publishes as well as older PKCS #1 ones
+func decodePublicKey(value string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return key, nil
+}
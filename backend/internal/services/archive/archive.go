@@ -0,0 +1,344 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	archive_models "github.com/KylerJacobson/blog/backend/internal/api/types/archive"
+	archive_repo "github.com/KylerJacobson/blog/backend/internal/db/archive"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+const (
+	// FormatVersion is the archive layout this build writes and the only one it restores
+	FormatVersion = 1
+	// SchemaVersion is the number of the newest migration; bump it with every migration so archives are only
+	// restored into the schema they were taken from
+	SchemaVersion = 18
+
+	ManifestPath = "manifest.json"
+)
+
+var (
+	// ErrInvalidArchive wraps every reason an archive fails validation
+	ErrInvalidArchive = errors.New("invalid archive")
+	// ErrNotEmpty means the database a restore targets already has data
+	ErrNotEmpty = errors.New("database is not empty")
+)
+
+// Blobs reads and writes media bytes by blob name
+type Blobs interface {
+	DownloadBlob(blobName string) (io.ReadCloser, error)
+	UploadBlob(r io.Reader, blobName string) error
+}
+
+// Options choose what an export includes besides the database rows
+type Options struct {
+	// Passwords keeps the users' password hashes; without them restored users can't sign in
+	Passwords bool
+	// Blobs adds the media files themselves, not just their metadata
+	Blobs bool
+}
+
+// Archiver writes the blog to a zip archive and rebuilds a fresh database from one. An archive holds
+// data/<table>.json for every table, blobs/<blob name> for media files, and manifest.json describing both.
+type Archiver struct {
+	archiveRepository archive_repo.ArchiveRepository
+	blobs             Blobs
+	logger            logger.Logger
+}
+
+func New(archiveRepo archive_repo.ArchiveRepository, blobs Blobs, logger logger.Logger) *Archiver {
+	return &Archiver{
+		archiveRepository: archiveRepo,
+		blobs:             blobs,
+		logger:            logger,
+	}
+}
+
+// Export writes an archive to w. The manifest is written last, so an archive cut short has none and fails
+// validation.
+func (a *Archiver) Export(w io.Writer, opts Options) (*archive_models.Manifest, error) {
+	tables, err := a.archiveRepository.ExportTables(opts.Passwords)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &archive_models.Manifest{
+		FormatVersion:     FormatVersion,
+		SchemaVersion:     SchemaVersion,
+		CreatedAt:         time.Now().UTC(),
+		IncludesPasswords: opts.Passwords,
+		IncludesBlobs:     opts.Blobs,
+		Tables:            []archive_models.ManifestFile{},
+		Blobs:             []archive_models.ManifestFile{},
+	}
+
+	zw := zip.NewWriter(w)
+	media := map[string]json.RawMessage{}
+	for _, table := range tables {
+		file, err := writeFile(zw, tablePath(table.Name), zip.Deflate, bytes.NewReader(table.Rows))
+		if err != nil {
+			return nil, fmt.Errorf("writing table %s: %w", table.Name, err)
+		}
+		file.Name, file.Rows = table.Name, table.Count
+		manifest.Tables = append(manifest.Tables, file)
+		if slices.Contains(blobTables, table.Name) {
+			media[table.Name] = table.Rows
+		}
+	}
+	if opts.Blobs {
+		names, err := blobNames(media)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			file, err := a.exportBlob(zw, name)
+			if err != nil {
+				return nil, fmt.Errorf("writing blob %s: %w", name, err)
+			}
+			manifest.Blobs = append(manifest.Blobs, file)
+		}
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writeFile(zw, ManifestPath, zip.Deflate, bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return manifest, zw.Close()
+}
+
+func (a *Archiver) exportBlob(zw *zip.Writer, name string) (archive_models.ManifestFile, error) {
+	body, err := a.blobs.DownloadBlob(name)
+	if err != nil {
+		return archive_models.ManifestFile{}, err
+	}
+	defer body.Close()
+	// Media is already compressed, so it is stored as is
+	file, err := writeFile(zw, blobPath(name), zip.Store, body)
+	file.Name = name
+	return file, err
+}
+
+// Validate checks an archive against its manifest: the format and schema versions, that every table this
+// build restores is present, and the size, checksum and row count of every file.
+func (a *Archiver) Validate(zr *zip.Reader) (*archive_models.Manifest, error) {
+	manifest, _, err := validate(zr)
+	return manifest, err
+}
+
+// Restore validates an archive and loads it into an empty database, uploading its media files first so a
+// failed restore can simply be run again.
+func (a *Archiver) Restore(zr *zip.Reader) (*archive_models.Manifest, error) {
+	manifest, tables, err := validate(zr)
+	if err != nil {
+		return nil, err
+	}
+	empty, err := a.archiveRepository.IsEmpty()
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, ErrNotEmpty
+	}
+	files := entries(zr)
+	for _, blob := range manifest.Blobs {
+		err := a.restoreBlob(files[blobPath(blob.Name)], blob.Name)
+		if err != nil {
+			return nil, fmt.Errorf("restoring blob %s: %w", blob.Name, err)
+		}
+	}
+	err = a.archiveRepository.RestoreTables(tables, manifest.IncludesPasswords)
+	if err != nil {
+		return nil, err
+	}
+	a.logger.Sugar().Infof("restored archive from %s with %d tables and %d blobs", manifest.CreatedAt.Format(time.RFC3339), len(tables), len(manifest.Blobs))
+	return manifest, nil
+}
+
+func (a *Archiver) restoreBlob(file *zip.File, name string) error {
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return a.blobs.UploadBlob(r, name)
+}
+
+func validate(zr *zip.Reader) (*archive_models.Manifest, []archive_models.Table, error) {
+	files := entries(zr)
+	if len(files) != len(zr.File) {
+		return nil, nil, fmt.Errorf("%w: duplicate file names", ErrInvalidArchive)
+	}
+	manifestFile, ok := files[ManifestPath]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrInvalidArchive, ManifestPath)
+	}
+	b, err := readFile(manifestFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest archive_models.Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidArchive, err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, nil, fmt.Errorf("%w: format version %d, expected %d", ErrInvalidArchive, manifest.FormatVersion, FormatVersion)
+	}
+	if manifest.SchemaVersion != SchemaVersion {
+		return nil, nil, fmt.Errorf("%w: schema version %d, expected %d", ErrInvalidArchive, manifest.SchemaVersion, SchemaVersion)
+	}
+
+	names := make([]string, len(manifest.Tables))
+	for i, table := range manifest.Tables {
+		names[i] = table.Name
+	}
+	if !slices.Equal(names, archive_repo.TableNames()) {
+		return nil, nil, fmt.Errorf("%w: tables %v, expected %v", ErrInvalidArchive, names, archive_repo.TableNames())
+	}
+	tables := make([]archive_models.Table, 0, len(manifest.Tables))
+	media := map[string]json.RawMessage{}
+	for _, entry := range manifest.Tables {
+		if entry.Path != tablePath(entry.Name) {
+			return nil, nil, fmt.Errorf("%w: table %s is stored at %s", ErrInvalidArchive, entry.Name, entry.Path)
+		}
+		rows, err := checkFile(files, entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		var parsed []json.RawMessage
+		if err := json.Unmarshal(rows, &parsed); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s is not a JSON array: %v", ErrInvalidArchive, entry.Path, err)
+		}
+		if len(parsed) != entry.Rows {
+			return nil, nil, fmt.Errorf("%w: %s has %d rows, manifest says %d", ErrInvalidArchive, entry.Path, len(parsed), entry.Rows)
+		}
+		tables = append(tables, archive_models.Table{Name: entry.Name, Rows: rows, Count: entry.Rows})
+		if slices.Contains(blobTables, entry.Name) {
+			media[entry.Name] = rows
+		}
+	}
+
+	stored := map[string]bool{}
+	for _, entry := range manifest.Blobs {
+		if entry.Path != blobPath(entry.Name) {
+			return nil, nil, fmt.Errorf("%w: blob %s is stored at %s", ErrInvalidArchive, entry.Name, entry.Path)
+		}
+		if _, err := checkFile(files, entry); err != nil {
+			return nil, nil, err
+		}
+		stored[entry.Name] = true
+	}
+	if manifest.IncludesBlobs {
+		names, err := blobNames(media)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, name := range names {
+			if !stored[name] {
+				return nil, nil, fmt.Errorf("%w: media %s has no blob", ErrInvalidArchive, name)
+			}
+		}
+	}
+	return &manifest, tables, nil
+}
+
+// checkFile reads the file entry describes and compares it with the manifest
+func checkFile(files map[string]*zip.File, entry archive_models.ManifestFile) ([]byte, error) {
+	file, ok := files[entry.Path]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidArchive, entry.Path)
+	}
+	b, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) != entry.Size {
+		return nil, fmt.Errorf("%w: %s is %d bytes, manifest says %d", ErrInvalidArchive, entry.Path, len(b), entry.Size)
+	}
+	sum := sha256.Sum256(b)
+	if hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return nil, fmt.Errorf("%w: %s does not match its checksum", ErrInvalidArchive, entry.Path)
+	}
+	return b, nil
+}
+
+// writeFile copies r into a new archive file and describes it for the manifest
+func writeFile(zw *zip.Writer, path string, method uint16, r io.Reader) (archive_models.ManifestFile, error) {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: method, Modified: time.Now()})
+	if err != nil {
+		return archive_models.ManifestFile{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), r)
+	if err != nil {
+		return archive_models.ManifestFile{}, err
+	}
+	return archive_models.ManifestFile{Path: path, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func readFile(file *zip.File) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: opening %s: %v", ErrInvalidArchive, file.Name, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading %s: %v", ErrInvalidArchive, file.Name, err)
+	}
+	return b, nil
+}
+
+// blobTables are the tables whose rows refer to blobs through their blob_name column
+var blobTables = []string{"media", "media_variants"}
+
+// blobNames lists the blobs the exported rows of blobTables refer to
+func blobNames(media map[string]json.RawMessage) ([]string, error) {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, table := range blobTables {
+		if media[table] == nil {
+			continue
+		}
+		var rows []struct {
+			BlobName string `json:"blob_name"`
+		}
+		if err := json.Unmarshal(media[table], &rows); err != nil {
+			return nil, fmt.Errorf("%w: unreadable %s table: %v", ErrInvalidArchive, table, err)
+		}
+		for _, row := range rows {
+			if !seen[row.BlobName] {
+				seen[row.BlobName] = true
+				names = append(names, row.BlobName)
+			}
+		}
+	}
+	return names, nil
+}
+
+func entries(zr *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, file := range zr.File {
+		files[file.Name] = file
+	}
+	return files
+}
+
+func tablePath(name string) string {
+	return "data/" + name + ".json"
+}
+
+func blobPath(name string) string {
+	return "blobs/" + name
+}
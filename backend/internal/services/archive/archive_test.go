@@ -0,0 +1,262 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	archive_models "github.com/KylerJacobson/blog/backend/internal/api/types/archive"
+	archive_repo "github.com/KylerJacobson/blog/backend/internal/db/archive"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubArchiveRepository struct {
+	tables   []archive_models.Table
+	empty    bool
+	restored []archive_models.Table
+}
+
+func (s *stubArchiveRepository) ExportTables(includePasswords bool) ([]archive_models.Table, error) {
+	return s.tables, nil
+}
+
+func (s *stubArchiveRepository) IsEmpty() (bool, error) {
+	return s.empty, nil
+}
+
+func (s *stubArchiveRepository) RestoreTables(tables []archive_models.Table, includesPasswords bool) error {
+	s.restored = tables
+	return nil
+}
+
+type memoryBlobs map[string][]byte
+
+func (m memoryBlobs) DownloadBlob(blobName string) (io.ReadCloser, error) {
+	b, ok := m[blobName]
+	if !ok {
+		return nil, errors.New("no such blob")
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (m memoryBlobs) UploadBlob(r io.Reader, blobName string) error {
+	b, err := io.ReadAll(r)
+	m[blobName] = b
+	return err
+}
+
+func sampleTables() []archive_models.Table {
+	tables := []archive_models.Table{}
+	for _, name := range archive_repo.TableNames() {
+		rows := `[]`
+		count := 0
+		switch name {
+		case "users":
+			rows, count = `[{"id":1,"email":"a@example.com"}]`, 1
+		case "media":
+			rows, count = `[{"post_id":3,"blob_name":"3/cat.png"},{"post_id":3,"blob_name":"3/dog.png"}]`, 2
+		case "media_variants":
+			rows, count = `[{"media_id":1,"name":"thumb","blob_name":"3/cat_thumb.png"}]`, 1
+		}
+		tables = append(tables, archive_models.Table{Name: name, Rows: json.RawMessage(rows), Count: count})
+	}
+	return tables
+}
+
+func exportSample(t *testing.T, opts Options) []byte {
+	blobs := memoryBlobs{"3/cat.png": []byte("cat"), "3/dog.png": []byte("dog"), "3/cat_thumb.png": []byte("kitten")}
+	archiver := New(&stubArchiveRepository{tables: sampleTables()}, blobs, zap.NewNop())
+	var buf bytes.Buffer
+	_, err := archiver.Export(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openZip(t *testing.T, b []byte) *zip.Reader {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestExportRestoreRoundTrip(t *testing.T) {
+	b := exportSample(t, Options{Blobs: true})
+
+	repo := &stubArchiveRepository{empty: true}
+	blobs := memoryBlobs{}
+	manifest, err := New(repo, blobs, zap.NewNop()).Restore(openZip(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, FormatVersion, manifest.FormatVersion)
+	assert.False(t, manifest.IncludesPasswords)
+	assert.Equal(t, sampleTables(), repo.restored)
+	assert.Equal(t, memoryBlobs{"3/cat.png": []byte("cat"), "3/dog.png": []byte("dog"), "3/cat_thumb.png": []byte("kitten")}, blobs)
+}
+
+func TestRestoreRefusesDatabaseWithData(t *testing.T) {
+	b := exportSample(t, Options{Blobs: true})
+
+	repo := &stubArchiveRepository{empty: false}
+	blobs := memoryBlobs{}
+	_, err := New(repo, blobs, zap.NewNop()).Restore(openZip(t, b))
+
+	assert.ErrorIs(t, err, ErrNotEmpty)
+	assert.Nil(t, repo.restored)
+	assert.Empty(t, blobs)
+}
+
+// rewrite copies an archive, letting edit change or drop (by returning nil) each file
+func rewrite(t *testing.T, b []byte, edit func(name string, data []byte) []byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range openZip(t, b).File {
+		data, err := readFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = edit(file.Name, data)
+		if data == nil {
+			continue
+		}
+		w, err := zw.Create(file.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func editManifest(edit func(m *archive_models.Manifest)) func(string, []byte) []byte {
+	return func(name string, data []byte) []byte {
+		if name != ManifestPath {
+			return data
+		}
+		var m archive_models.Manifest
+		json.Unmarshal(data, &m)
+		edit(&m)
+		out, _ := json.Marshal(m)
+		return out
+	}
+}
+
+func TestValidate(t *testing.T) {
+	withBlobs := exportSample(t, Options{Blobs: true})
+	withoutBlobs := exportSample(t, Options{})
+
+	tests := []struct {
+		name    string
+		archive []byte
+		edit    func(name string, data []byte) []byte
+		wantErr string
+	}{
+		{
+			name:    "valid_with_blobs",
+			archive: withBlobs,
+		},
+		{
+			name:    "valid_without_blobs",
+			archive: withoutBlobs,
+		},
+		{
+			name:    "missing_manifest",
+			archive: withBlobs,
+			edit: func(name string, data []byte) []byte {
+				if name == ManifestPath {
+					return nil
+				}
+				return data
+			},
+			wantErr: "no manifest.json",
+		},
+		{
+			name:    "newer_format",
+			archive: withBlobs,
+			edit:    editManifest(func(m *archive_models.Manifest) { m.FormatVersion = FormatVersion + 1 }),
+			wantErr: "format version",
+		},
+		{
+			name:    "other_schema",
+			archive: withBlobs,
+			edit:    editManifest(func(m *archive_models.Manifest) { m.SchemaVersion = SchemaVersion - 1 }),
+			wantErr: "schema version",
+		},
+		{
+			name:    "missing_table",
+			archive: withBlobs,
+			edit:    editManifest(func(m *archive_models.Manifest) { m.Tables = m.Tables[1:] }),
+			wantErr: "tables",
+		},
+		{
+			name:    "tampered_table",
+			archive: withBlobs,
+			edit: func(name string, data []byte) []byte {
+				if name == "data/users.json" {
+					return bytes.Replace(data, []byte("a@example.com"), []byte("b@example.com"), 1)
+				}
+				return data
+			},
+			wantErr: "checksum",
+		},
+		{
+			name:    "wrong_row_count",
+			archive: withBlobs,
+			edit:    editManifest(func(m *archive_models.Manifest) { m.Tables[0].Rows = 2 }),
+			wantErr: "has 1 rows, manifest says 2",
+		},
+		{
+			name:    "missing_blob",
+			archive: withBlobs,
+			edit: func(name string, data []byte) []byte {
+				if name == "blobs/3/dog.png" {
+					return nil
+				}
+				return data
+			},
+			wantErr: "blobs/3/dog.png is missing",
+		},
+		{
+			name:    "blob_left_out_of_manifest",
+			archive: withBlobs,
+			edit:    editManifest(func(m *archive_models.Manifest) { m.Blobs = m.Blobs[:1] }),
+			wantErr: "media 3/dog.png has no blob",
+		},
+		{
+			name:    "blob_outside_blobs_directory",
+			archive: withBlobs,
+			edit:    editManifest(func(m *archive_models.Manifest) { m.Blobs[0].Path = "data/users.json" }),
+			wantErr: "is stored at",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.archive
+			if tt.edit != nil {
+				b = rewrite(t, b, tt.edit)
+			}
+			archiver := New(&stubArchiveRepository{}, memoryBlobs{}, zap.NewNop())
+
+			_, err := archiver.Validate(openZip(t, b))
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidArchive)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package digest
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	users_repo "github.com/KylerJacobson/blog/backend/internal/db/users"
+	"github.com/KylerJacobson/blog/backend/internal/locale"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// MaxPosts caps how many posts one digest lists
+const MaxPosts = 50
+
+// periods is how long each digest frequency waits between digests
+var periods = map[string]time.Duration{
+	user_models.FrequencyDaily:  24 * time.Hour,
+	user_models.FrequencyWeekly: 7 * 24 * time.Hour,
+}
+
+// Sender emails a digest
+type Sender interface {
+	Digest(user user_models.User, digest []post_models.Post, frequency string) error
+}
+
+// Digester periodically emails daily and weekly subscribers the posts published since their last digest
+type Digester struct {
+	usersRepository users_repo.UsersRepository
+	postsRepository posts_repo.PostsRepository
+	sender          Sender
+	interval        time.Duration
+	logger          logger.Logger
+}
+
+func New(usersRepo users_repo.UsersRepository, postsRepo posts_repo.PostsRepository, sender Sender, interval time.Duration, logger logger.Logger) *Digester {
+	return &Digester{
+		usersRepository: usersRepo,
+		postsRepository: postsRepo,
+		sender:          sender,
+		interval:        interval,
+		logger:          logger,
+	}
+}
+
+// Run sends due digests every interval until ctx is cancelled
+func (d *Digester) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	d.SendDue(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.SendDue(now)
+		}
+	}
+}
+
+// SendDue sends a digest to every subscriber whose period has passed at now. A digest counts as due up to one
+// interval early, so digests don't slip a tick later every period.
+func (d *Digester) SendDue(now time.Time) {
+	subscribers, err := d.usersRepository.GetDigestSubscribers()
+	if err != nil {
+		d.logger.Sugar().Errorf("error getting digest subscribers: %v", err)
+		return
+	}
+	for _, subscriber := range subscribers {
+		period, ok := periods[subscriber.NotificationFrequency]
+		if !ok {
+			continue
+		}
+		since := now.Add(-period)
+		if subscriber.LastDigestAt != nil {
+			if now.Sub(*subscriber.LastDigestAt) <= period-d.interval {
+				continue
+			}
+			since = *subscriber.LastDigestAt
+		}
+		err := d.send(subscriber, since, now)
+		if err != nil {
+			d.logger.Sugar().Errorf("error sending the digest to user %s: %v", subscriber.Id, err)
+		}
+	}
+}
+
+// send emails the posts the subscriber may read that went live after since, in their language where translated.
+// Nothing is sent when there are none, but the period still restarts; a failed email leaves it for the next run.
+func (d *Digester) send(subscriber user_models.DigestSubscriber, since, now time.Time) error {
+	userId, err := strconv.Atoi(subscriber.Id)
+	if err != nil {
+		return err
+	}
+	posts, err := d.postsRepository.GetPosts(post_models.PostFilter{
+		Limit:          MaxPosts,
+		Viewer:         authorization.NewViewer(userId, subscriber.Role),
+		Locales:        locale.For(subscriber.Locale),
+		PublishedAfter: &since,
+		PublishedUntil: &now,
+	})
+	if err != nil {
+		return err
+	}
+	if len(posts) > 0 {
+		err = d.sender.Digest(subscriber.User, posts, subscriber.NotificationFrequency)
+		if err != nil {
+			return err
+		}
+		d.logger.Sugar().Infof("sent a %s digest of %d posts to user %d", subscriber.NotificationFrequency, len(posts), userId)
+	}
+	return d.usersRepository.SetLastDigestAt(userId, now)
+}
@@ -0,0 +1,162 @@
+package digest
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	user_models "github.com/KylerJacobson/blog/backend/internal/api/types/users"
+	"github.com/KylerJacobson/blog/backend/internal/authorization"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	users_repo "github.com/KylerJacobson/blog/backend/internal/db/users"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubUsersRepository struct {
+	users_repo.UsersRepository
+	subscribers []user_models.DigestSubscriber
+	sentAt      map[int]time.Time
+}
+
+func (s *stubUsersRepository) GetDigestSubscribers() ([]user_models.DigestSubscriber, error) {
+	return s.subscribers, nil
+}
+
+func (s *stubUsersRepository) SetLastDigestAt(userId int, at time.Time) error {
+	s.sentAt[userId] = at
+	return nil
+}
+
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	posts   []post_models.Post
+	filters []post_models.PostFilter
+}
+
+func (s *stubPostsRepository) GetPosts(filter post_models.PostFilter) ([]post_models.Post, error) {
+	s.filters = append(s.filters, filter)
+	var posts []post_models.Post
+	for _, post := range s.posts {
+		if post.Restricted && !filter.Viewer.Privileged {
+			continue
+		}
+		if post.PublishAt == nil || !post.PublishAt.After(*filter.PublishedAfter) || post.PublishAt.After(*filter.PublishedUntil) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+type stubSender struct {
+	sent map[string][]post_models.Post
+	err  error
+}
+
+func (s *stubSender) Digest(user user_models.User, digest []post_models.Post, frequency string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent[user.Id] = digest
+	return nil
+}
+
+func subscriber(id, role int, frequency string, lastDigestAt *time.Time) user_models.DigestSubscriber {
+	user := user_models.User{Id: strconv.Itoa(id), Role: role, NotificationFrequency: frequency}
+	return user_models.DigestSubscriber{User: user, LastDigestAt: lastDigestAt}
+}
+
+func TestSendDue(t *testing.T) {
+	now := time.Date(2024, 6, 8, 9, 0, 0, 0, time.UTC)
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+	anHourAgo := now.Add(-time.Hour)
+	// A digest is due up to one interval early
+	almostADayAgo := now.Add(-23*time.Hour - time.Minute)
+	publishedAt := func(ago time.Duration) *time.Time {
+		at := now.Add(-ago)
+		return &at
+	}
+	posts := []post_models.Post{
+		{PostId: 1, Title: "public", PublishAt: publishedAt(2 * time.Hour)},
+		{PostId: 2, Title: "family only", Restricted: true, PublishAt: publishedAt(3 * time.Hour)},
+		{PostId: 3, Title: "last month", PublishAt: publishedAt(30 * 24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name          string
+		subscriber    user_models.DigestSubscriber
+		noPosts       bool
+		sendErr       error
+		expectedPosts []int
+		expectedMark  bool
+	}{
+		{
+			name:          "weekly_privileged_sees_restricted",
+			subscriber:    subscriber(1, authorization.RolePrivileged, user_models.FrequencyWeekly, &lastWeek),
+			expectedPosts: []int{1, 2},
+			expectedMark:  true,
+		},
+		{
+			name:          "daily_non_privileged",
+			subscriber:    subscriber(2, authorization.RoleNonPrivileged, user_models.FrequencyDaily, &yesterday),
+			expectedPosts: []int{1},
+			expectedMark:  true,
+		},
+		{
+			name:          "first_digest_covers_one_period",
+			subscriber:    subscriber(3, authorization.RoleNonPrivileged, user_models.FrequencyDaily, nil),
+			expectedPosts: []int{1},
+			expectedMark:  true,
+		},
+		{
+			name:         "not_due",
+			subscriber:   subscriber(4, authorization.RoleNonPrivileged, user_models.FrequencyDaily, &anHourAgo),
+			expectedMark: false,
+		},
+		{
+			name:          "due_within_an_interval",
+			subscriber:    subscriber(5, authorization.RoleNonPrivileged, user_models.FrequencyDaily, &almostADayAgo),
+			expectedPosts: []int{1},
+			expectedMark:  true,
+		},
+		{
+			name:         "nothing_new_restarts_the_period",
+			subscriber:   subscriber(6, authorization.RoleNonPrivileged, user_models.FrequencyWeekly, &lastWeek),
+			noPosts:      true,
+			expectedMark: true,
+		},
+		{
+			name:         "failed_email_is_retried",
+			subscriber:   subscriber(7, authorization.RolePrivileged, user_models.FrequencyWeekly, &lastWeek),
+			sendErr:      errors.New("sendgrid unavailable"),
+			expectedMark: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usersRepo := &stubUsersRepository{subscribers: []user_models.DigestSubscriber{tt.subscriber}, sentAt: map[int]time.Time{}}
+			sender := &stubSender{sent: map[string][]post_models.Post{}, err: tt.sendErr}
+
+			postsRepo := &stubPostsRepository{posts: posts}
+			if tt.noPosts {
+				postsRepo.posts = nil
+			}
+
+			New(usersRepo, postsRepo, sender, time.Hour, zap.NewNop()).SendDue(now)
+
+			var sentIds []int
+			for _, post := range sender.sent[tt.subscriber.Id] {
+				sentIds = append(sentIds, post.PostId)
+			}
+			assert.Equal(t, tt.expectedPosts, sentIds)
+			userId, _ := strconv.Atoi(tt.subscriber.Id)
+			_, marked := usersRepo.sentAt[userId]
+			assert.Equal(t, tt.expectedMark, marked)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package emailer
 
 import (
 	"fmt"
+	"html"
+	"strings"
 
 	"github.com/KylerJacobson/blog/backend/internal/api/types/posts"
 	"github.com/KylerJacobson/blog/backend/internal/api/types/users"
@@ -45,6 +47,33 @@ func (s *EmailerService) NewPostEmail(user users.User, post posts.PostRequestBod
 	return nil
 }
 
+// DigestEmail sums up the posts published since the member's last digest in one email. frequency is the
+// member's daily or weekly setting and only changes the wording.
+func (s *EmailerService) DigestEmail(user users.User, digest []posts.Post, frequency string) error {
+	var plain, list strings.Builder
+	for _, post := range digest {
+		link := fmt.Sprintf("https://kylerjacobson.dev/post/%d", post.PostId)
+		fmt.Fprintf(&plain, "- %s: %s\n", post.Title, link)
+		fmt.Fprintf(&list, "<li><a href=\"%s\">%s</a></li>", link, html.EscapeString(post.Title))
+	}
+	email := Email{
+		FromName:    s.fromName,
+		FromEmail:   s.fromEmail,
+		ToName:      user.FirstName + " " + user.LastName,
+		ToEmail:     user.Email,
+		Subject:     fmt.Sprintf("Your %s digest from kylerjacobson.dev", frequency),
+		PlainText:   fmt.Sprintf("New posts on kylerjacobson.dev:\n%s", plain.String()),
+		HTMLContent: fmt.Sprintf("Hey %s, here's what's new on kylerjacobson.dev:<ul>%s</ul>", html.EscapeString(user.FirstName), list.String()),
+	}
+
+	err := s.client.Send(email)
+	if err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+
+	return nil
+}
+
 func (s *EmailerService) NewUserNotificationEmail(user users.User) error {
 	email := Email{
 		FromName:    s.fromName,
@@ -24,4 +24,5 @@ type EmailClient interface {
 type Emailer interface {
 	NewPostEmail(user users.User, post posts.PostRequestBody) error
 	NewUserNotificationEmail(user users.User) error
+	DigestEmail(user users.User, digest []posts.Post, frequency string) error
 }
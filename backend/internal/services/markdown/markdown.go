@@ -0,0 +1,200 @@
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// DefaultCacheSize is how many rendered posts a Renderer keeps before evicting the least recently used
+const DefaultCacheSize = 256
+
+// Heading is one entry of a table of contents; Id is the anchor the rendered heading carries
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Id    string `json:"id"`
+}
+
+type Rendered struct {
+	HTML string
+	TOC  []Heading
+}
+
+// Renderer turns post Markdown into sanitized HTML. It is safe for concurrent use.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type cacheEntry struct {
+	key      string
+	rendered Rendered
+}
+
+func New(cacheSize int) *Renderer {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle("github"),
+				highlighting.WithFormatOptions(html.TabWidth(4)),
+			),
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+
+	// UGC covers the usual Markdown output; headings keep their anchors and highlighted code keeps its colours
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("id").OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("tabindex").OnElements("pre")
+	policy.AllowStyles("color", "background-color", "font-weight", "font-style", "text-decoration", "display").OnElements("pre", "span")
+
+	return &Renderer{
+		markdown: md,
+		policy:   policy,
+		capacity: cacheSize,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Render converts source to HTML and collects its headings
+func (r *Renderer) Render(source string) (Rendered, error) {
+	src := []byte(source)
+	doc := r.markdown.Parser().Parse(text.NewReader(src))
+
+	var toc []Heading
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		entry := Heading{Level: heading.Level, Text: plainText(heading, src)}
+		if id, ok := heading.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				entry.Id = string(b)
+			}
+		}
+		toc = append(toc, entry)
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := r.markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return Rendered{}, err
+	}
+	return Rendered{HTML: r.policy.Sanitize(buf.String()), TOC: toc}, nil
+}
+
+// RenderCached is Render memoized under key, which must change whenever source does
+func (r *Renderer) RenderCached(key, source string) (Rendered, error) {
+	r.mu.Lock()
+	if el, ok := r.entries[key]; ok {
+		r.order.MoveToFront(el)
+		rendered := el.Value.(*cacheEntry).rendered
+		r.mu.Unlock()
+		return rendered, nil
+	}
+	r.mu.Unlock()
+
+	rendered, err := r.Render(source)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[key]; !ok && r.capacity > 0 {
+		r.entries[key] = r.order.PushFront(&cacheEntry{key: key, rendered: rendered})
+		if r.order.Len() > r.capacity {
+			oldest := r.order.Back()
+			r.order.Remove(oldest)
+			delete(r.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return rendered, nil
+}
+
+// Excerpt returns the opening prose of source as plain text, cut at a word boundary to at most maxRunes runes.
+// Headings, code and raw HTML are skipped so the result reads like a summary.
+func (r *Renderer) Excerpt(source string, maxRunes int) string {
+	src := []byte(source)
+	doc := r.markdown.Parser().Parse(text.NewReader(src))
+
+	var words []string
+	length := 0
+	truncated := false
+	overflow := ""
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || (n.Kind() != ast.KindParagraph && n.Kind() != ast.KindTextBlock) {
+			return ast.WalkContinue, nil
+		}
+		for _, word := range strings.Fields(plainText(n, src)) {
+			if length > 0 {
+				length++
+			}
+			length += utf8.RuneCountInString(word)
+			if length > maxRunes {
+				truncated = true
+				overflow = word
+				return ast.WalkStop, nil
+			}
+			words = append(words, word)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	if !truncated {
+		return strings.Join(words, " ")
+	}
+
+	// Drop whole words until the ellipsis fits, cutting mid-word only when the first word alone is too long
+	first := overflow
+	if len(words) > 0 {
+		first = words[0]
+	}
+	for len(words) > 0 && utf8.RuneCountInString(strings.Join(words, " "))+1 > maxRunes {
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 {
+		return string([]rune(first)[:maxRunes-1]) + "…"
+	}
+	return strings.Join(words, " ") + "…"
+}
+
+func plainText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch t := c.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		default:
+			buf.WriteString(plainText(c, source))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,60 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	renderer := New(DefaultCacheSize)
+
+	rendered, err := renderer.Render("# Getting *Started*\n\nSome text.\n\n## Install Go\n\n```go\nfunc main() {}\n```\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Heading{
+		{Level: 1, Text: "Getting Started", Id: "getting-started"},
+		{Level: 2, Text: "Install Go", Id: "install-go"},
+	}, rendered.TOC)
+	assert.Contains(t, rendered.HTML, `<h1 id="getting-started">`)
+	assert.Contains(t, rendered.HTML, `<h2 id="install-go">`)
+	assert.Contains(t, rendered.HTML, `<span style="`, "code should be highlighted")
+	assert.Contains(t, rendered.HTML, "main")
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	renderer := New(DefaultCacheSize)
+
+	rendered, err := renderer.Render("Hi <script>alert(1)</script>\n\n[click](javascript:alert(1))\n\n<img src=x onerror=alert(1)>")
+
+	assert.NoError(t, err)
+	assert.NotContains(t, rendered.HTML, "<script")
+	assert.NotContains(t, rendered.HTML, "javascript:")
+	assert.NotContains(t, rendered.HTML, "onerror")
+}
+
+func TestRenderCached(t *testing.T) {
+	renderer := New(2)
+
+	first, err := renderer.RenderCached("1@a", "# One")
+	assert.NoError(t, err)
+	// Same key returns the cached output even if the source were to differ
+	cached, _ := renderer.RenderCached("1@a", "# Changed")
+	assert.Equal(t, first, cached)
+
+	renderer.RenderCached("2@a", "# Two")
+	renderer.RenderCached("3@a", "# Three")
+	assert.Len(t, renderer.entries, 2)
+	evicted, _ := renderer.RenderCached("1@a", "# Changed")
+	assert.True(t, strings.Contains(evicted.HTML, "Changed"), "least recently used entry should have been evicted")
+}
+
+func TestExcerpt(t *testing.T) {
+	renderer := New(DefaultCacheSize)
+	source := "# Title\n\nFirst *paragraph* with a [link](https://example.dev).\n\n```go\nfunc main() {}\n```\n\nSecond paragraph."
+
+	assert.Equal(t, "First paragraph with a link. Second paragraph.", renderer.Excerpt(source, 200))
+	assert.Equal(t, "First paragraph…", renderer.Excerpt(source, 20))
+	assert.Equal(t, "Supercalifragilis…", renderer.Excerpt("## Intro\n\nSupercalifragilisticexpialidocious", 18))
+}
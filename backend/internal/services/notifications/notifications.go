@@ -19,3 +19,8 @@ func NewNotificationsService(emailer emailer.Emailer) *Notifier {
 func (s *Notifier) NewPost(user users.User, post posts.PostRequestBody) error {
 	return s.emailer.NewPostEmail(user, post)
 }
+
+// Digest emails a member a summary of several new posts at once
+func (s *Notifier) Digest(user users.User, digest []posts.Post, frequency string) error {
+	return s.emailer.DigestEmail(user, digest, frequency)
+}
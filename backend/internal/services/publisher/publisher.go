@@ -0,0 +1,71 @@
+package publisher
+
+import (
+	"context"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// NotifyFunc announces a freshly published post to subscribers
+type NotifyFunc func(postId int, post post_models.PostRequestBody) error
+
+// Publisher periodically flips scheduled posts to published once their publish_at has passed
+type Publisher struct {
+	postsRepository posts_repo.PostsRepository
+	notify          NotifyFunc
+	interval        time.Duration
+	logger          logger.Logger
+}
+
+func New(postsRepo posts_repo.PostsRepository, notify NotifyFunc, interval time.Duration, logger logger.Logger) *Publisher {
+	return &Publisher{
+		postsRepository: postsRepo,
+		notify:          notify,
+		interval:        interval,
+		logger:          logger,
+	}
+}
+
+// Run publishes due posts every interval until ctx is cancelled
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.PublishDue(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.PublishDue(now)
+		}
+	}
+}
+
+// PublishDue publishes every scheduled post due at now and notifies subscribers about each one
+func (p *Publisher) PublishDue(now time.Time) {
+	posts, err := p.postsRepository.PublishDuePosts(now)
+	if err != nil {
+		p.logger.Sugar().Errorf("error publishing scheduled posts: %v", err)
+		return
+	}
+	for _, post := range posts {
+		p.logger.Sugar().Infof("published scheduled post %d (%s)", post.PostId, post.Title)
+		err := p.notify(post.PostId, post_models.PostRequestBody{
+			Title:      post.Title,
+			Content:    post.Content,
+			Restricted: post.Restricted,
+			Slug:       post.Slug,
+			Status:     post.Status,
+			PublishAt:  post.PublishAt,
+			Locale:     post.Locale,
+			Tags:       post.Tags,
+		})
+		if err != nil {
+			p.logger.Sugar().Errorf("error notifying users of scheduled post %d: %v", post.PostId, err)
+		}
+	}
+}
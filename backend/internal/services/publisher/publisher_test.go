@@ -0,0 +1,61 @@
+package publisher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	post_models "github.com/KylerJacobson/blog/backend/internal/api/types/posts"
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	due []post_models.Post
+	err error
+	at  time.Time
+}
+
+func (s *stubPostsRepository) PublishDuePosts(now time.Time) ([]post_models.Post, error) {
+	s.at = now
+	return s.due, s.err
+}
+
+func TestPublishDueNotifiesEachPost(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	repo := &stubPostsRepository{due: []post_models.Post{
+		{PostId: 1, Title: "first", Status: post_models.StatusPublished},
+		{PostId: 2, Title: "second", Restricted: true, Status: post_models.StatusPublished},
+	}}
+	var notifiedIds []int
+	var notified []post_models.PostRequestBody
+	notify := func(postId int, post post_models.PostRequestBody) error {
+		notifiedIds = append(notifiedIds, postId)
+		notified = append(notified, post)
+		// A failed notification must not stop the others
+		return errors.New("sendgrid unavailable")
+	}
+
+	New(repo, notify, time.Minute, zap.NewNop()).PublishDue(now)
+
+	assert.Equal(t, now, repo.at)
+	assert.Equal(t, []int{1, 2}, notifiedIds)
+	assert.Len(t, notified, 2)
+	assert.Equal(t, "first", notified[0].Title)
+	assert.True(t, notified[1].Restricted)
+}
+
+func TestPublishDueSkipsNotificationsOnError(t *testing.T) {
+	repo := &stubPostsRepository{err: errors.New("connection refused")}
+	called := false
+	notify := func(postId int, post post_models.PostRequestBody) error {
+		called = true
+		return nil
+	}
+
+	New(repo, notify, time.Minute, zap.NewNop()).PublishDue(time.Now())
+
+	assert.False(t, called)
+}
@@ -0,0 +1,62 @@
+package purger
+
+import (
+	"context"
+	"time"
+
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// DefaultRetention is how long deleted posts stay in the trash when no retention is configured
+const DefaultRetention = 30 * 24 * time.Hour
+
+// Purger periodically empties posts out of the trash once they have been there longer than the retention
+type Purger struct {
+	postsRepository posts_repo.PostsRepository
+	blobs           storage.BlobStore
+	retention       time.Duration
+	interval        time.Duration
+	logger          logger.Logger
+}
+
+func New(postsRepo posts_repo.PostsRepository, blobs storage.BlobStore, retention, interval time.Duration, logger logger.Logger) *Purger {
+	return &Purger{
+		postsRepository: postsRepo,
+		blobs:           blobs,
+		retention:       retention,
+		interval:        interval,
+		logger:          logger,
+	}
+}
+
+// Run purges expired posts every interval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.PurgeExpired(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.PurgeExpired(now)
+		}
+	}
+}
+
+// PurgeExpired permanently deletes every post that was trashed more than the retention before now, along with
+// its media in storage
+func (p *Purger) PurgeExpired(now time.Time) {
+	purged, blobNames, err := p.postsRepository.PurgeTrashedBefore(now.Add(-p.retention))
+	if err != nil {
+		p.logger.Sugar().Errorf("error purging expired posts from the trash: %v", err)
+		return
+	}
+	storage.DeleteBlobs(p.blobs, blobNames, p.logger)
+	if purged > 0 {
+		p.logger.Sugar().Infof("purged %d posts from the trash", purged)
+	}
+}
@@ -0,0 +1,45 @@
+package purger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	posts_repo "github.com/KylerJacobson/blog/backend/internal/db/posts"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubPostsRepository struct {
+	posts_repo.PostsRepository
+	cutoffs   []time.Time
+	blobNames []string
+}
+
+func (s *stubPostsRepository) PurgeTrashedBefore(cutoff time.Time) (int, []string, error) {
+	s.cutoffs = append(s.cutoffs, cutoff)
+	return 1, s.blobNames, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	blobs := storage.NewMemoryStore()
+	for _, blobName := range []string{"blog-media/3_photo.png", "blog-media/3_photo_thumb.png", "blog-media/4_kept.png"} {
+		if err := blobs.UploadBlob(strings.NewReader("data"), blobName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The variant was never uploaded, which must not stop the rest from going
+	repo := &stubPostsRepository{blobNames: []string{"blog-media/3_photo.png", "blog-media/3_photo_w320.png", "blog-media/3_photo_thumb.png"}}
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+
+	New(repo, blobs, 7*24*time.Hour, time.Hour, zap.NewNop()).PurgeExpired(now)
+
+	assert.Equal(t, []time.Time{time.Date(2024, 5, 24, 12, 0, 0, 0, time.UTC)}, repo.cutoffs)
+	for _, blobName := range repo.blobNames {
+		_, err := blobs.StatBlob(blobName)
+		assert.ErrorIs(t, err, storage.ErrNotFound, blobName)
+	}
+	_, err := blobs.StatBlob("blog-media/4_kept.png")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// container holds every blob the blog stores
+const container = "media"
+
+// AzureStore keeps blobs in the media container of an Azure storage account
+type AzureStore struct {
+	client *azblob.Client
+	logger logger.Logger
+}
+
+func NewAzureStore(connectionString string, logger logger.Logger) (*AzureStore, error) {
+	if connectionString == "" {
+		return nil, errors.New("AZURE_STORAGE_CONNECTION_STRING not set")
+	}
+	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
+	if err != nil {
+		logger.Sugar().Errorf("error creating the client from the connection string: %v", err)
+		return nil, err
+	}
+	return &AzureStore{
+		client: client,
+		logger: logger,
+	}, nil
+}
+
+// UploadBlob streams r into the media container under blobName
+func (s *AzureStore) UploadBlob(r io.Reader, blobName string) error {
+	_, err := s.client.UploadStream(context.Background(), container, blobName, r, nil)
+	if err != nil {
+		return fmt.Errorf("error uploading to blob: %v", err)
+	}
+	return nil
+}
+
+func (s *AzureStore) DownloadBlob(blobName string) (io.ReadCloser, error) {
+	resp, err := s.client.DownloadStream(context.Background(), container, blobName, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error downloading blob %s: %v", blobName, err)
+	}
+	return resp.Body, nil
+}
+
+// OpenBlob reads the blob through ranged downloads, starting a new one from wherever a Seek moves to
+func (s *AzureStore) OpenBlob(blobName string) (io.ReadSeekCloser, error) {
+	info, err := s.StatBlob(blobName)
+	if err != nil {
+		return nil, err
+	}
+	return &azureBlobReader{store: s, name: blobName, size: info.Size}, nil
+}
+
+type azureBlobReader struct {
+	store  *AzureStore
+	name   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (b *azureBlobReader) Read(p []byte) (int, error) {
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+	if b.body == nil {
+		resp, err := b.store.client.DownloadStream(context.Background(), container, b.name, &azblob.DownloadStreamOptions{
+			Range: blob.HTTPRange{Offset: b.offset},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error downloading blob %s: %v", b.name, err)
+		}
+		b.body = resp.Body
+	}
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+	return n, err
+}
+
+func (b *azureBlobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the blob")
+	}
+	if offset != b.offset {
+		b.Close()
+		b.offset = offset
+	}
+	return offset, nil
+}
+
+func (b *azureBlobReader) Close() error {
+	if b.body == nil {
+		return nil
+	}
+	err := b.body.Close()
+	b.body = nil
+	return err
+}
+
+func (s *AzureStore) DeleteBlob(blobName string) error {
+	_, err := s.client.DeleteBlob(context.Background(), container, blobName, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error deleting blob %s: %v", blobName, err)
+	}
+	return nil
+}
+
+func (s *AzureStore) GetUrlForBlob(blobName string, expiry time.Duration) (string, error) {
+	blobClient := s.client.ServiceClient().NewContainerClient(container).NewBlobClient(blobName)
+	permission := sas.BlobPermissions{Read: true}
+	// Start a little early so a URL that only lasts minutes isn't rejected by a storage clock running behind
+	now := time.Now()
+	start := now.Add(-time.Minute)
+	options := blob.GetSASURLOptions{StartTime: &start}
+	url, err := blobClient.GetSASURL(permission, now.Add(expiry), &options)
+	if err != nil {
+		s.logger.Sugar().Errorf("error getting the sas URL for blob %s with error :%v", blobName, err)
+		return "", err
+	}
+	return url, nil
+}
+
+func (s *AzureStore) StatBlob(blobName string) (*BlobInfo, error) {
+	blobClient := s.client.ServiceClient().NewContainerClient(container).NewBlobClient(blobName)
+	props, err := blobClient.GetProperties(context.Background(), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting the properties of blob %s: %v", blobName, err)
+	}
+	info := &BlobInfo{Name: blobName}
+	if props.ContentLength != nil {
+		info.Size = *props.ContentLength
+	}
+	if props.ContentType != nil {
+		info.ContentType = *props.ContentType
+	}
+	if props.LastModified != nil {
+		info.ModTime = *props.LastModified
+	}
+	return info, nil
+}
+
+func (s *AzureStore) Origin() string {
+	u, err := url.Parse(s.client.URL())
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
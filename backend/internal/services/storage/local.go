@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/KylerJacobson/blog/backend/internal/httperr"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// LocalPath is where ServeBlob is mounted; a blob's URL is LocalPath followed by its name
+const LocalPath = "/api/blobs/"
+
+// LocalStore keeps blobs as files under a directory and hands out URLs signed with an HMAC of the blob name and
+// expiry, which ServeBlob checks before serving the file
+type LocalStore struct {
+	dir     string
+	baseURL string
+	secret  []byte
+	logger  logger.Logger
+}
+
+// NewLocalStore stores blobs under dir, creating it if needed. URLs start with baseURL; without a secret a random
+// one is used, so URLs only work until the process restarts
+func NewLocalStore(dir, baseURL string, secret []byte, logger logger.Logger) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the storage directory %s: %v", dir, err)
+	}
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &LocalStore{
+		dir:     dir,
+		baseURL: baseURL,
+		secret:  secret,
+		logger:  logger,
+	}, nil
+}
+
+// path maps blobName to its file, rejecting names that would escape the storage directory
+func (s *LocalStore) path(blobName string) (string, error) {
+	if blobName == "" || path.Clean("/"+blobName) != "/"+blobName {
+		return "", fmt.Errorf("invalid blob name %q", blobName)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(blobName)), nil
+}
+
+// UploadBlob writes to a temporary file first so readers never see a partial blob
+func (s *LocalStore) UploadBlob(r io.Reader, blobName string) error {
+	name, err := s.path(blobName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("error creating the directory for blob %s: %v", blobName, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("error creating blob %s: %v", blobName, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing blob %s: %v", blobName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing blob %s: %v", blobName, err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("error saving blob %s: %v", blobName, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) DownloadBlob(blobName string) (io.ReadCloser, error) {
+	return s.OpenBlob(blobName)
+}
+
+func (s *LocalStore) OpenBlob(blobName string) (io.ReadSeekCloser, error) {
+	name, err := s.path(blobName)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error opening blob %s: %v", blobName, err)
+	}
+	return file, nil
+}
+
+func (s *LocalStore) DeleteBlob(blobName string) error {
+	name, err := s.path(blobName)
+	if err != nil {
+		return ErrNotFound
+	}
+	if err := os.Remove(name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error deleting blob %s: %v", blobName, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) GetUrlForBlob(blobName string, expiry time.Duration) (string, error) {
+	if _, err := s.path(blobName); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {s.sign(blobName, expires)}}
+	return s.baseURL + LocalPath + (&url.URL{Path: blobName}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+func (s *LocalStore) StatBlob(blobName string) (*BlobInfo, error) {
+	name, err := s.path(blobName)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error reading blob %s: %v", blobName, err)
+	}
+	return &BlobInfo{
+		Name:        blobName,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(name)),
+		ModTime:     fi.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) Origin() string {
+	u, err := url.Parse(s.baseURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (s *LocalStore) sign(blobName, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(blobName + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ServeBlob serves GET LocalPath{name...} for URLs from GetUrlForBlob that haven't expired
+func (s *LocalStore) ServeBlob(w http.ResponseWriter, r *http.Request) {
+	blobName := r.PathValue("name")
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(s.sign(blobName, expires))) {
+		httperr.Write(w, httperr.Forbidden("invalid signature", ""))
+		return
+	}
+	if time.Now().Unix() > unix {
+		httperr.Write(w, httperr.Forbidden("link expired", ""))
+		return
+	}
+
+	name, err := s.path(blobName)
+	if err != nil {
+		httperr.Write(w, httperr.NotFound("blob not found", ""))
+		return
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			httperr.Write(w, httperr.NotFound("blob not found", ""))
+			return
+		}
+		s.logger.Sugar().Errorf("error opening blob %s: %v", blobName, err)
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		s.logger.Sugar().Errorf("error reading blob %s: %v", blobName, err)
+		httperr.Write(w, httperr.Internal("internal server error", ""))
+		return
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", fi.ModTime(), file)
+}
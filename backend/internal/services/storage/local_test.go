@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestStore(t *testing.T) *LocalStore {
+	store, err := NewLocalStore(t.TempDir(), "http://localhost:8080", []byte("secret"), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestLocalStore(t *testing.T) {
+	store := newTestStore(t)
+
+	err := store.UploadBlob(strings.NewReader("hello"), "blog-media/1_a.png")
+	assert.NoError(t, err)
+
+	info, err := store.StatBlob("blog-media/1_a.png")
+	assert.NoError(t, err)
+	if assert.NotNil(t, info) {
+		assert.Equal(t, int64(5), info.Size)
+		assert.Equal(t, "image/png", info.ContentType)
+	}
+
+	body, err := store.DownloadBlob("blog-media/1_a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "hello", string(data))
+
+	assert.NoError(t, store.DeleteBlob("blog-media/1_a.png"))
+	_, err = store.StatBlob("blog-media/1_a.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.DeleteBlob("blog-media/1_a.png"), ErrNotFound)
+
+	for _, name := range []string{"", "../escape.png", "blog-media/../../escape.png", "/abs.png"} {
+		assert.Error(t, store.UploadBlob(strings.NewReader("x"), name), name)
+	}
+}
+
+func TestServeBlob(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.UploadBlob(strings.NewReader("hello world"), "blog-media/1_a b.png"); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := store.GetUrlForBlob("blog-media/1_a b.png", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(signed, "http://localhost:8080"+LocalPath+"blog-media/1_a%20b.png?"))
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	tests := []struct {
+		name           string
+		url            func(u *url.URL)
+		rangeHeader    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "signed", url: func(u *url.URL) {}, expectedStatus: http.StatusOK, expectedBody: "hello world"},
+		{name: "range", url: func(u *url.URL) {}, rangeHeader: "bytes=6-", expectedStatus: http.StatusPartialContent, expectedBody: "world"},
+		{name: "tampered signature", url: func(u *url.URL) {
+			q := u.Query()
+			q.Set("signature", "AAAA")
+			u.RawQuery = q.Encode()
+		}, expectedStatus: http.StatusUnauthorized},
+		{name: "other blob", url: func(u *url.URL) {
+			u.Path = LocalPath + "blog-media/2_b.png"
+		}, expectedStatus: http.StatusUnauthorized},
+		{name: "expired", url: func(u *url.URL) {
+			q := u.Query()
+			q.Set("expires", expired)
+			q.Set("signature", store.sign("blog-media/1_a b.png", expired))
+			u.RawQuery = q.Encode()
+		}, expectedStatus: http.StatusUnauthorized},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+LocalPath+"{name...}", store.ServeBlob)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.url(u)
+			req := httptest.NewRequest(http.MethodGet, u.String(), nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+				assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps blobs in memory so handlers can be tested without real storage
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: map[string]memoryBlob{}}
+}
+
+func (s *MemoryStore) UploadBlob(r io.Reader, blobName string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading blob %s: %v", blobName, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[blobName] = memoryBlob{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStore) DownloadBlob(blobName string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blob, ok := s.blobs[blobName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(blob.data)), nil
+}
+
+func (s *MemoryStore) OpenBlob(blobName string) (io.ReadSeekCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blob, ok := s.blobs[blobName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return nopSeekCloser{bytes.NewReader(blob.data)}, nil
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) DeleteBlob(blobName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blobs[blobName]; !ok {
+		return ErrNotFound
+	}
+	delete(s.blobs, blobName)
+	return nil
+}
+
+// GetUrlForBlob returns a memory:// URL; nothing serves it, but it identifies the blob
+func (s *MemoryStore) GetUrlForBlob(blobName string, expiry time.Duration) (string, error) {
+	return "memory://" + blobName, nil
+}
+
+func (s *MemoryStore) StatBlob(blobName string) (*BlobInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blob, ok := s.blobs[blobName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &BlobInfo{
+		Name:        blobName,
+		Size:        int64(len(blob.data)),
+		ContentType: mime.TypeByExtension(path.Ext(blobName)),
+		ModTime:     blob.modTime,
+	}, nil
+}
+
+// Origin is empty because memory:// URLs are never loaded
+func (s *MemoryStore) Origin() string {
+	return ""
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/KylerJacobson/blog/backend/logger"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3URLExpiry is the longest a SigV4 presigned URL can be valid for; longer expiries are cut down to it
+const S3URLExpiry = 7 * 24 * time.Hour
+
+// S3Config points an S3Store at a bucket on any S3-compatible service, e.g. MinIO, R2 or AWS
+type S3Config struct {
+	// Endpoint is the service's host[:port] without a scheme, e.g. localhost:9000 or s3.us-east-1.amazonaws.com
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Insecure talks plain HTTP, for a local MinIO
+	Insecure bool
+	// PathStyle addresses the bucket as endpoint/bucket rather than bucket.endpoint, as MinIO and R2 expect
+	PathStyle bool
+}
+
+// S3Store keeps blobs in a bucket on an S3-compatible service
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	origin string
+	logger logger.Logger
+}
+
+func NewS3Store(config S3Config, logger logger.Logger) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET must be set")
+	}
+	lookup := minio.BucketLookupDNS
+	if config.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+		Secure:       !config.Insecure,
+		Region:       config.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		logger.Sugar().Errorf("error creating the S3 client for %s: %v", config.Endpoint, err)
+		return nil, err
+	}
+
+	scheme := "https://"
+	if config.Insecure {
+		scheme = "http://"
+	}
+	origin := scheme + config.Endpoint
+	if !config.PathStyle {
+		origin = scheme + config.Bucket + "." + config.Endpoint
+	}
+	return &S3Store{
+		client: client,
+		bucket: config.Bucket,
+		origin: origin,
+		logger: logger,
+	}, nil
+}
+
+func isNoSuchKey(err error) bool {
+	return minio.ToErrorResponse(err).Code == minio.NoSuchKey
+}
+
+// UploadBlob streams r into the bucket under blobName; an unknown size makes the client upload it in parts
+func (s *S3Store) UploadBlob(r io.Reader, blobName string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, blobName, r, -1, minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("error uploading blob %s: %v", blobName, err)
+	}
+	return nil
+}
+
+func (s *S3Store) DownloadBlob(blobName string) (io.ReadCloser, error) {
+	return s.OpenBlob(blobName)
+}
+
+// OpenBlob returns the object itself, which seeks by starting a ranged GET from the new offset
+func (s *S3Store) OpenBlob(blobName string) (io.ReadSeekCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, blobName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error downloading blob %s: %v", blobName, err)
+	}
+	// GetObject doesn't send the request until the object is read, so stat it to report a missing blob now
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNoSuchKey(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error downloading blob %s: %v", blobName, err)
+	}
+	return object, nil
+}
+
+// DeleteBlob checks the blob exists first, since S3 reports success for deleting a missing key
+func (s *S3Store) DeleteBlob(blobName string) error {
+	if _, err := s.StatBlob(blobName); err != nil {
+		return err
+	}
+	err := s.client.RemoveObject(context.Background(), s.bucket, blobName, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("error deleting blob %s: %v", blobName, err)
+	}
+	return nil
+}
+
+func (s *S3Store) GetUrlForBlob(blobName string, expiry time.Duration) (string, error) {
+	url, err := s.client.PresignedGetObject(context.Background(), s.bucket, blobName, min(expiry, S3URLExpiry), nil)
+	if err != nil {
+		s.logger.Sugar().Errorf("error presigning the URL for blob %s: %v", blobName, err)
+		return "", err
+	}
+	return url.String(), nil
+}
+
+func (s *S3Store) StatBlob(blobName string) (*BlobInfo, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, blobName, minio.StatObjectOptions{})
+	if err != nil {
+		if isNoSuchKey(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting the properties of blob %s: %v", blobName, err)
+	}
+	return &BlobInfo{
+		Name:        blobName,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModTime:     info.LastModified,
+	}, nil
+}
+
+func (s *S3Store) Origin() string {
+	return s.origin
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestS3StoreOrigin(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         S3Config
+		expectedOrigin string
+	}{
+		{name: "path style", config: S3Config{Endpoint: "localhost:9000", Bucket: "media", Insecure: true, PathStyle: true}, expectedOrigin: "http://localhost:9000"},
+		{name: "virtual hosted", config: S3Config{Endpoint: "s3.us-east-1.amazonaws.com", Bucket: "media", Region: "us-east-1"}, expectedOrigin: "https://media.s3.us-east-1.amazonaws.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewS3Store(tt.config, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expectedOrigin, store.Origin())
+		})
+	}
+}
+
+// TestS3Store runs against a real S3-compatible service, e.g.
+// docker run -p 9000:9000 minio/minio server /data, then
+// S3_TEST_ENDPOINT=localhost:9000 S3_TEST_ACCESS_KEY_ID=minioadmin S3_TEST_SECRET_ACCESS_KEY=minioadmin go test
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+	store, err := NewS3Store(S3Config{
+		Endpoint:        endpoint,
+		Bucket:          "blog-storage-test",
+		AccessKeyID:     os.Getenv("S3_TEST_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_TEST_SECRET_ACCESS_KEY"),
+		Insecure:        true,
+		PathStyle:       true,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exists, err := store.client.BucketExists(ctx, store.bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		if err := store.client.MakeBucket(ctx, store.bucket, minio.MakeBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blobName := "blog-media/1_" + strings.ReplaceAll(t.Name(), "/", "_") + ".png"
+	assert.NoError(t, store.UploadBlob(strings.NewReader("hello"), blobName))
+
+	info, err := store.StatBlob(blobName)
+	assert.NoError(t, err)
+	if assert.NotNil(t, info) {
+		assert.Equal(t, int64(5), info.Size)
+	}
+
+	body, err := store.DownloadBlob(blobName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "hello", string(data))
+
+	url, err := store.GetUrlForBlob(blobName, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(url, store.Origin()+"/"))
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(data))
+
+	assert.NoError(t, store.DeleteBlob(blobName))
+	_, err = store.StatBlob(blobName)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.DownloadBlob(blobName)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.DeleteBlob(blobName), ErrNotFound)
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// ErrNotFound means no blob is stored under the name
+var ErrNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob without reading it
+type BlobInfo struct {
+	Name        string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// BlobStore keeps media bytes by blob name, e.g. blog-media/12_photo.jpg
+type BlobStore interface {
+	UploadBlob(r io.Reader, blobName string) error
+	// DownloadBlob opens blobName for reading; the caller closes it
+	DownloadBlob(blobName string) (io.ReadCloser, error)
+	// OpenBlob opens blobName for random access so byte ranges can be served; the caller closes it
+	OpenBlob(blobName string) (io.ReadSeekCloser, error)
+	DeleteBlob(blobName string) error
+	// GetUrlForBlob returns a URL anyone holding it can read blobName from until expiry passes
+	GetUrlForBlob(blobName string, expiry time.Duration) (string, error)
+	StatBlob(blobName string) (*BlobInfo, error)
+	// Origin is the scheme and host GetUrlForBlob URLs point at, which the CSP has to allow
+	Origin() string
+}
+
+// DeleteBlobs deletes blobNames after the rows pointing at them are gone. A blob that fails to delete is only
+// orphaned storage by then, so failures are logged rather than returned, and ones already missing are ignored.
+func DeleteBlobs(blobs BlobStore, blobNames []string, logger logger.Logger) {
+	for _, blobName := range blobNames {
+		err := blobs.DeleteBlob(blobName)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			logger.Sugar().Errorf("error deleting blob %s: %v", blobName, err)
+		}
+	}
+}
+
+// FromEnv builds the store STORAGE_BACKEND selects: "azure" (the default) reads AZURE_STORAGE_CONNECTION_STRING,
+// "s3" reads the S3_* variables, and "local" keeps blobs under STORAGE_DIR and signs URLs served from baseURL with
+// STORAGE_URL_SECRET
+func FromEnv(baseURL string, logger logger.Logger) (BlobStore, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "azure":
+		return NewAzureStore(os.Getenv("AZURE_STORAGE_CONNECTION_STRING"), logger)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			Insecure:        os.Getenv("S3_INSECURE") == "true",
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+		}, logger)
+	case "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		secret := os.Getenv("STORAGE_URL_SECRET")
+		if secret == "" {
+			// Fine for development, but every URL handed out so far stops working on restart
+			logger.Sugar().Warnf("STORAGE_URL_SECRET not set, signing local blob URLs with a random key")
+		}
+		return NewLocalStore(dir, strings.TrimSuffix(baseURL, "/"), []byte(secret), logger)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q, expected azure, s3 or local", backend)
+	}
+}
@@ -0,0 +1,141 @@
+package variants
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
+	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	"github.com/KylerJacobson/blog/backend/internal/imaging"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/KylerJacobson/blog/backend/logger"
+)
+
+// BatchSize is how many attachments are read from the database at a time
+const BatchSize = 10
+
+// Blobs reads originals and writes their variants
+type Blobs interface {
+	DownloadBlob(blobName string) (io.ReadCloser, error)
+	UploadBlob(r io.Reader, blobName string) error
+}
+
+// Processor makes the resized variants of uploaded images in the background, so uploads return as soon as the
+// originals are stored. It works through every image still waiting when woken and every interval, which also
+// picks up uploads from before it ran and ones that failed on a storage error.
+type Processor struct {
+	mediaRepository media_repo.MediaRepository
+	blobs           Blobs
+	interval        time.Duration
+	wake            chan struct{}
+	logger          logger.Logger
+}
+
+func New(mediaRepo media_repo.MediaRepository, blobs Blobs, interval time.Duration, logger logger.Logger) *Processor {
+	return &Processor{
+		mediaRepository: mediaRepo,
+		blobs:           blobs,
+		interval:        interval,
+		wake:            make(chan struct{}, 1),
+		logger:          logger,
+	}
+}
+
+// Wake has Run process waiting images now rather than at the next interval. It never blocks.
+func (p *Processor) Wake() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes waiting images every interval and whenever woken until ctx is cancelled
+func (p *Processor) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.ProcessPending()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.ProcessPending()
+		case <-p.wake:
+			p.ProcessPending()
+		}
+	}
+}
+
+// ProcessPending makes variants for every image waiting for them. It stops early after a failure, leaving the
+// rest for the next run.
+func (p *Processor) ProcessPending() {
+	for {
+		media, err := p.mediaRepository.GetUnprocessedMedia(imaging.ContentTypes, BatchSize)
+		if err != nil {
+			p.logger.Sugar().Errorf("error getting media waiting for variants: %v", err)
+			return
+		}
+		for _, attachment := range media {
+			if err := p.process(attachment); err != nil {
+				p.logger.Sugar().Errorf("error making variants of media %d: %v", attachment.MediaId, err)
+				return
+			}
+		}
+		if len(media) < BatchSize {
+			return
+		}
+	}
+}
+
+func (p *Processor) process(attachment media_models.Post) error {
+	body, err := p.blobs.DownloadBlob(attachment.BlobName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			p.logger.Sugar().Warnf("blob %s of media %d is missing, skipping its variants", attachment.BlobName, attachment.MediaId)
+			return p.mediaRepository.SaveVariants(attachment.MediaId, nil)
+		}
+		return err
+	}
+	// Read it all first so a failed download is retried rather than taken for an undecodable image
+	original, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	images, err := imaging.Variants(bytes.NewReader(original), attachment.ContentType)
+	if err != nil {
+		// Trying again won't decode it any better, so mark it done with the original as its only rendition
+		p.logger.Sugar().Warnf("skipping variants of media %d: %v", attachment.MediaId, err)
+		return p.mediaRepository.SaveVariants(attachment.MediaId, nil)
+	}
+
+	variants := make([]media_models.Variant, 0, len(images))
+	for _, rendition := range images {
+		variant := media_models.Variant{
+			MediaId:     attachment.MediaId,
+			Name:        rendition.Name,
+			BlobName:    BlobName(attachment.BlobName, rendition.Name),
+			Width:       rendition.Width,
+			Height:      rendition.Height,
+			ContentType: rendition.ContentType,
+		}
+		if err := p.blobs.UploadBlob(bytes.NewReader(rendition.Data), variant.BlobName); err != nil {
+			return err
+		}
+		variants = append(variants, variant)
+	}
+	return p.mediaRepository.SaveVariants(attachment.MediaId, variants)
+}
+
+// BlobName is where the variant called name of the original blob is stored, next to the original:
+// blog-media/12_photo.jpg has its 640 pixel variant at blog-media/12_photo_w640.jpg
+func BlobName(original, name string) string {
+	ext := path.Ext(original)
+	return strings.TrimSuffix(original, ext) + "_" + name + ext
+}
@@ -0,0 +1,126 @@
+package variants
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	media_models "github.com/KylerJacobson/blog/backend/internal/api/types/media"
+	media_repo "github.com/KylerJacobson/blog/backend/internal/db/media"
+	"github.com/KylerJacobson/blog/backend/internal/services/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// stubMediaRepository hands out the pending attachments that have no saved variants yet
+type stubMediaRepository struct {
+	media_repo.MediaRepository
+	pending []media_models.Post
+	saved   map[int][]media_models.Variant
+}
+
+func (s *stubMediaRepository) GetUnprocessedMedia(contentTypes []string, limit int) ([]media_models.Post, error) {
+	var media []media_models.Post
+	for _, attachment := range s.pending {
+		if _, ok := s.saved[attachment.MediaId]; !ok && len(media) < limit {
+			media = append(media, attachment)
+		}
+	}
+	return media, nil
+}
+
+func (s *stubMediaRepository) SaveVariants(mediaId int, variants []media_models.Variant) error {
+	s.saved[mediaId] = variants
+	return nil
+}
+
+// failingBlobs fails to download one blob, as if storage were unreachable
+type failingBlobs struct {
+	*storage.MemoryStore
+	failing string
+}
+
+func (f failingBlobs) DownloadBlob(blobName string) (io.ReadCloser, error) {
+	if blobName == f.failing {
+		return nil, errors.New("connection reset")
+	}
+	return f.MemoryStore.DownloadBlob(blobName)
+}
+
+func encodedPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessPending(t *testing.T) {
+	blobs := storage.NewMemoryStore()
+	uploads := map[string][]byte{
+		"blog-media/1_photo.png":  encodedPNG(t, 800, 600),
+		"blog-media/1_broken.png": []byte("not a png"),
+		"blog-media/2_photo.png":  encodedPNG(t, 100, 100),
+	}
+	for blobName, data := range uploads {
+		if err := blobs.UploadBlob(bytes.NewReader(data), blobName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := &stubMediaRepository{
+		pending: []media_models.Post{
+			{MediaId: 1, BlobName: "blog-media/1_photo.png", ContentType: "image/png"},
+			{MediaId: 2, BlobName: "blog-media/1_broken.png", ContentType: "image/png"},
+			{MediaId: 3, BlobName: "blog-media/1_gone.png", ContentType: "image/png"},
+			{MediaId: 4, BlobName: "blog-media/2_photo.png", ContentType: "image/png"},
+		},
+		saved: map[int][]media_models.Variant{},
+	}
+
+	New(repo, blobs, 0, zap.NewNop()).ProcessPending()
+
+	names := map[int][]string{}
+	for mediaId, variants := range repo.saved {
+		names[mediaId] = []string{}
+		for _, variant := range variants {
+			names[mediaId] = append(names[mediaId], variant.Name)
+			_, err := blobs.StatBlob(variant.BlobName)
+			assert.NoError(t, err)
+		}
+	}
+	assert.Equal(t, map[int][]string{
+		1: {"w320", "w640", "thumb"},
+		2: {},
+		3: {},
+		4: {"thumb"},
+	}, names)
+	assert.Equal(t, "blog-media/1_photo_w640.png", repo.saved[1][1].BlobName)
+	assert.Equal(t, 480, repo.saved[1][1].Height)
+}
+
+func TestProcessPendingStopsOnStorageError(t *testing.T) {
+	blobs := failingBlobs{MemoryStore: storage.NewMemoryStore(), failing: "blog-media/1_photo.png"}
+	if err := blobs.UploadBlob(bytes.NewReader(encodedPNG(t, 50, 50)), "blog-media/2_photo.png"); err != nil {
+		t.Fatal(err)
+	}
+	repo := &stubMediaRepository{
+		pending: []media_models.Post{
+			{MediaId: 1, BlobName: "blog-media/1_photo.png", ContentType: "image/png"},
+			{MediaId: 2, BlobName: "blog-media/2_photo.png", ContentType: "image/png"},
+		},
+		saved: map[int][]media_models.Variant{},
+	}
+
+	New(repo, blobs, 0, zap.NewNop()).ProcessPending()
+
+	// Neither is marked processed, so the next run tries both again
+	assert.Empty(t, repo.saved)
+}
+
+func TestBlobName(t *testing.T) {
+	assert.Equal(t, "blog-media/12_photo_w640.jpg", BlobName("blog-media/12_photo.jpg", "w640"))
+	assert.Equal(t, "blog-media/12_photo_thumb", BlobName("blog-media/12_photo", "thumb"))
+}
@@ -0,0 +1,35 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "simple_title", input: "Hello World", expected: "hello-world"},
+		{name: "punctuation_collapses", input: "  Go 1.22: What's New?! ", expected: "go-1-22-what-s-new"},
+		{name: "accents_are_folded", input: "Año Nuevo en Café", expected: "ano-nuevo-en-cafe"},
+		{name: "non_latin_is_dropped", input: "日本 trip", expected: "trip"},
+		{name: "empty", input: "!!!", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Make(tt.input))
+		})
+	}
+}
+
+func TestMakeTruncatesOnWordBoundary(t *testing.T) {
+	s := Make(strings.Repeat("word ", 40))
+	assert.LessOrEqual(t, len(s), MaxLength)
+	assert.False(t, strings.HasSuffix(s, "-"))
+	assert.True(t, strings.HasSuffix(s, "word"))
+}
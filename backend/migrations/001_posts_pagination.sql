@@ -0,0 +1,2 @@
+-- Keyset pagination for GET /api/posts walks (created_at, post_id) newest first
+CREATE INDEX IF NOT EXISTS posts_created_at_post_id_idx ON posts (created_at DESC, post_id DESC);
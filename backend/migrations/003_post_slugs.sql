@@ -0,0 +1,51 @@
+-- Human-readable permalinks; old slugs keep resolving through post_slug_redirects
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug text;
+
+-- Backfilled slugs are made the way slug.Make does it: accents are folded to their base letters, everything else
+-- that isn't an ASCII letter or digit becomes a hyphen, and slugs longer than 80 characters are cut back to the last
+-- hyphen in the second half
+UPDATE posts p SET slug = coalesce(nullif(rtrim(left(s.slug, CASE
+        WHEN length(s.slug) > 80 AND 80 - strpos(reverse(left(s.slug, 80)), '-') > 40
+        THEN 80 - strpos(reverse(left(s.slug, 80)), '-')
+        ELSE 80
+    END), '-'), ''), 'post')
+FROM (
+    SELECT post_id, trim(BOTH '-' FROM regexp_replace(
+        regexp_replace(normalize(lower(title), NFD), '[\u0300-\u036f]', '', 'g'), '[^a-z0-9]+', '-', 'g'
+    )) AS slug
+    FROM posts WHERE slug IS NULL
+) s
+WHERE s.post_id = p.post_id;
+
+-- Older duplicates keep their slug and newer ones take the first free -2, -3, ... suffix, as uniqueSlug does
+DO $$
+DECLARE
+    dup record;
+    candidate text;
+    n integer;
+BEGIN
+    FOR dup IN
+        SELECT post_id, slug FROM (
+            SELECT post_id, slug, row_number() OVER (PARTITION BY slug ORDER BY created_at, post_id) AS rank FROM posts
+        ) ranked
+        WHERE rank > 1 ORDER BY slug, rank
+    LOOP
+        n := 2;
+        candidate := dup.slug || '-' || n;
+        WHILE EXISTS (SELECT 1 FROM posts WHERE slug = candidate) LOOP
+            n := n + 1;
+            candidate := dup.slug || '-' || n;
+        END LOOP;
+        UPDATE posts SET slug = candidate WHERE post_id = dup.post_id;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE posts ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS posts_slug_idx ON posts (slug);
+
+CREATE TABLE IF NOT EXISTS post_slug_redirects (
+    slug       text PRIMARY KEY,
+    post_id    integer NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
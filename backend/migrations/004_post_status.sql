@@ -0,0 +1,9 @@
+-- Draft / scheduled / published workflow; existing posts are already live
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at timestamptz;
+
+UPDATE posts SET publish_at = created_at WHERE status = 'published' AND publish_at IS NULL;
+
+-- The background publisher polls for due scheduled posts
+CREATE INDEX IF NOT EXISTS posts_scheduled_publish_at_idx ON posts (publish_at) WHERE status = 'scheduled';
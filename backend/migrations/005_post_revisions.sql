@@ -0,0 +1,20 @@
+-- Every saved version of a post, newest revision last
+CREATE TABLE IF NOT EXISTS post_revisions (
+    revision_id serial PRIMARY KEY,
+    post_id     integer NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+    title       text NOT NULL,
+    content     text NOT NULL,
+    restricted  boolean NOT NULL,
+    slug        text NOT NULL,
+    status      text NOT NULL,
+    user_id     integer NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS post_revisions_post_id_idx ON post_revisions (post_id, revision_id);
+
+-- Seed history with the current state of posts written before revisions existed
+INSERT INTO post_revisions (post_id, title, content, restricted, slug, status, user_id, created_at)
+SELECT p.post_id, p.title, p.content, p.restricted, p.slug, p.status, p.user_id, p.updated_at
+FROM posts p
+WHERE NOT EXISTS (SELECT 1 FROM post_revisions r WHERE r.post_id = p.post_id);
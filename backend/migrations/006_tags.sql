@@ -0,0 +1,14 @@
+-- Free-form tags; names are stored normalized (lowercase, hyphen separated) so "Go" and "go" are one tag
+CREATE TABLE IF NOT EXISTS tags (
+    tag_id     serial PRIMARY KEY,
+    name       text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id integer NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+    tag_id  integer NOT NULL REFERENCES tags (tag_id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS post_tags_tag_id_idx ON post_tags (tag_id);
@@ -0,0 +1,14 @@
+-- Reader comments; replies point at their parent and go away with it
+CREATE TABLE IF NOT EXISTS comments (
+    comment_id serial PRIMARY KEY,
+    post_id    integer NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+    parent_id  integer REFERENCES comments (comment_id) ON DELETE CASCADE,
+    user_id    integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body       text NOT NULL,
+    status     text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS comments_post_id_idx ON comments (post_id, created_at);
+CREATE INDEX IF NOT EXISTS comments_pending_idx ON comments (created_at) WHERE status = 'pending';
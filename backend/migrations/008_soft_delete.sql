@@ -0,0 +1,5 @@
+-- Deleting a post moves it and its media to the trash; rows are purged for good after the retention period
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS posts_deleted_at_idx ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
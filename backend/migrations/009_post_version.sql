@@ -0,0 +1,2 @@
+-- Row version for optimistic concurrency; every update bumps it and PUT /api/posts/{id} must name the version it read
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,26 @@
+-- Member groups and per-post grants. A post that is neither restricted nor granted to anyone is public;
+-- grants open a post to the listed users and to every member of the listed groups.
+CREATE TABLE IF NOT EXISTS groups (
+    group_id   serial PRIMARY KEY,
+    name       text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id integer NOT NULL REFERENCES groups (group_id) ON DELETE CASCADE,
+    user_id  integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_members_user_id_idx ON group_members (user_id);
+
+CREATE TABLE IF NOT EXISTS post_grants (
+    grant_id serial PRIMARY KEY,
+    post_id  integer NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+    group_id integer REFERENCES groups (group_id) ON DELETE CASCADE,
+    user_id  integer REFERENCES users (id) ON DELETE CASCADE,
+    CHECK ((group_id IS NULL) <> (user_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS post_grants_group_idx ON post_grants (post_id, group_id) WHERE group_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS post_grants_user_idx ON post_grants (post_id, user_id) WHERE user_id IS NOT NULL;
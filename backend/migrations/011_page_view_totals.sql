@@ -0,0 +1,9 @@
+-- Daily page view totals carried over by a restored archive. Archives keep analytics only as aggregates,
+-- so restored history lives here instead of in page_views.
+CREATE TABLE IF NOT EXISTS page_view_totals (
+    day      date    NOT NULL,
+    path     text    NOT NULL,
+    views    integer NOT NULL,
+    visitors integer NOT NULL,
+    PRIMARY KEY (day, path)
+);
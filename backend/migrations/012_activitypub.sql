@@ -0,0 +1,14 @@
+-- ActivityPub federation: the key the blog's actor signs deliveries with, and the fediverse accounts following it
+CREATE TABLE IF NOT EXISTS activitypub_keys (
+    key_id      text PRIMARY KEY,
+    private_key text NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS followers (
+    follower_id  serial PRIMARY KEY,
+    actor_id     text NOT NULL UNIQUE,
+    inbox        text NOT NULL,
+    shared_inbox text,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+-- How often a member hears about new posts: an email per post, a daily or weekly digest, or nothing.
+-- email_notification stays as the on/off view of it for older clients.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_frequency text NOT NULL DEFAULT 'immediate'
+    CHECK (notification_frequency IN ('immediate', 'daily', 'weekly', 'off'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_digest_at timestamptz;
+
+UPDATE users SET notification_frequency = 'off' WHERE NOT email_notification;
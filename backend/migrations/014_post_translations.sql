@@ -0,0 +1,46 @@
+-- Posts in more than one language. posts.locale is the language a post was written in and post_translations
+-- holds the same post in other languages, each with its own slug.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS locale text NOT NULL DEFAULT 'en';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale text NOT NULL DEFAULT 'en';
+
+-- The text search configuration for a locale; languages Postgres has no stemmer for are searched word for word
+CREATE OR REPLACE FUNCTION locale_search_config(locale text) RETURNS regconfig
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT CASE split_part(lower(locale), '-', 1)
+        WHEN 'en' THEN 'english'
+        WHEN 'es' THEN 'spanish'
+        WHEN 'fr' THEN 'french'
+        WHEN 'de' THEN 'german'
+        WHEN 'it' THEN 'italian'
+        WHEN 'pt' THEN 'portuguese'
+        WHEN 'nl' THEN 'dutch'
+        ELSE 'simple'
+    END::regconfig
+$$;
+
+-- Posts written in something other than English are stemmed in their own language
+DROP INDEX IF EXISTS posts_search_vector_idx;
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE posts ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector(locale_search_config(locale), coalesce(title, '')), 'A') ||
+        setweight(to_tsvector(locale_search_config(locale), coalesce(content, '')), 'B')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS posts_search_vector_idx ON posts USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS post_translations (
+    post_id       integer NOT NULL REFERENCES posts (post_id) ON DELETE CASCADE,
+    locale        text NOT NULL,
+    title         text NOT NULL,
+    content       text NOT NULL,
+    slug          text NOT NULL UNIQUE,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector(locale_search_config(locale), coalesce(title, '')), 'A') ||
+        setweight(to_tsvector(locale_search_config(locale), coalesce(content, '')), 'B')
+    ) STORED,
+    PRIMARY KEY (post_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS post_translations_search_vector_idx ON post_translations USING GIN (search_vector);
@@ -0,0 +1,4 @@
+-- Give every attachment its own id so it can be served through the media proxy
+ALTER TABLE media ADD COLUMN IF NOT EXISTS media_id serial;
+
+CREATE UNIQUE INDEX IF NOT EXISTS media_media_id_idx ON media (media_id);
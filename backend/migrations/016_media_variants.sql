@@ -0,0 +1,16 @@
+-- Resized renditions of image attachments, generated in the background after upload. variants_processed_at
+-- marks attachments the generator is done with, including ones it couldn't decode.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS variants_processed_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS media_variants (
+    media_id     integer NOT NULL REFERENCES media (media_id) ON DELETE CASCADE,
+    name         text NOT NULL,
+    blob_name    text NOT NULL,
+    width        integer NOT NULL,
+    height       integer NOT NULL,
+    content_type text NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (media_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS media_unprocessed_idx ON media (media_id) WHERE variants_processed_at IS NULL;
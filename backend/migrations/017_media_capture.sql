@@ -0,0 +1,4 @@
+-- What a photo's EXIF said about how it was taken, kept when the EXIF itself is stripped from the upload. Only
+-- admins see it.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS captured_at timestamptz;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS camera text;
@@ -0,0 +1,5 @@
+-- A post shared with users or groups stays shared when the last of them is deleted, instead of becoming public
+-- because post_grants cascaded away. Only replacing its grants with an empty list makes it public again.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS shared boolean NOT NULL DEFAULT false;
+
+UPDATE posts SET shared = true WHERE EXISTS (SELECT 1 FROM post_grants g WHERE g.post_id = posts.post_id);
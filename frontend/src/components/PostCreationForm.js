@@ -16,6 +16,7 @@ const PostCreationFrom = () => {
         restricted: true,
     });
     const [media, setMedia] = useState([]);
+    const [etag, setEtag] = useState();
     const [showOverlay, setShowOverlay] = useState(false);
     const fileInputRef = useRef(null);
 
@@ -31,7 +32,11 @@ const PostCreationFrom = () => {
         const getPost = async () => {
             if (postId) {
                 try {
-                    const { data } = await axios.get(`/api/posts/${postId}`);
+                    const { data, headers } = await axios.get(`/api/posts/${postId}`, {
+                        // Edit the post as written, not a translation picked from the browser's languages
+                        params: { translate: false },
+                    });
+                    setEtag(headers.etag);
                     setValues({
                         title: data.title,
                         content: data.content,
@@ -65,9 +70,11 @@ const PostCreationFrom = () => {
     const createPost = async (postData) => {
         try {
             if (postId) {
-                const response = await axios.put(`/api/posts/${postId}`, {
-                    postData,
-                });
+                const response = await axios.put(
+                    `/api/posts/${postId}`,
+                    { postData },
+                    { headers: { "If-Match": etag } }
+                );
                 if (response.status === 200) {
                     navigate("/");
                 }
@@ -81,10 +88,18 @@ const PostCreationFrom = () => {
                 }
             }
         } catch (error) {
+            if (error.response?.status === 412) {
+                setError(
+                    "This post was changed in another tab. Reload to get the latest version before saving."
+                );
+                return;
+            }
             console.error("There was an error submitting the form", error);
         }
     };
     const [files, setFiles] = useState([]);
+    // Photos are stripped of EXIF, including their location, unless this is ticked
+    const [keepMetadata, setKeepMetadata] = useState(false);
     const handleFileChange = (event) => {
         if (
             event.target.files[0] &&
@@ -108,6 +123,7 @@ const PostCreationFrom = () => {
                 formData.append("restricted", restricted);
             }
             formData.append("postId", postId);
+            formData.append("keepMetadata", keepMetadata);
             try {
                 const response = await axios.post("/api/media", formData, {
                     headers: {
@@ -255,6 +271,21 @@ const PostCreationFrom = () => {
                                 return (
                                     <li>
                                         {file.name}{" "}
+                                        {(file.camera || file.capturedAt) && (
+                                            <span className="text-gray-500">
+                                                (
+                                                {[
+                                                    file.camera,
+                                                    file.capturedAt &&
+                                                        new Date(
+                                                            file.capturedAt
+                                                        ).toLocaleString(),
+                                                ]
+                                                    .filter(Boolean)
+                                                    .join(", ")}
+                                                ){" "}
+                                            </span>
+                                        )}
                                         <button
                                             onClick={() => removeFile(file.postId)}
                                             style={{ color: "red" }}
@@ -273,6 +304,18 @@ const PostCreationFrom = () => {
                             ref={fileInputRef}
                         />
                     </div>
+                    <div>
+                        <input
+                            type="checkbox"
+                            id="keepMetadata"
+                            checked={keepMetadata}
+                            onChange={(e) => setKeepMetadata(e.target.checked)}
+                            className="mr-2"
+                        />
+                        <label htmlFor="keepMetadata" className="text-gray-600">
+                            Keep photo metadata (camera, date and location)
+                        </label>
+                    </div>
 
                     <div>
                         <ul>
@@ -16,7 +16,7 @@ function Home() {
                 const response = await axios.get("/api/posts", {
                     withCredentials: true,
                 });
-                setPosts(response.data);
+                setPosts(response.data.posts);
             } catch (error) {
                 console.error("There was an error submitting the form", error);
             }
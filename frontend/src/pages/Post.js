@@ -21,7 +21,9 @@ function Post() {
     useEffect(() => {
         const getPost = async () => {
             try {
+                const lang = new URLSearchParams(window.location.search).get("lang");
                 const response = await axios.get(`/api/posts/${postId}`, {
+                    params: lang ? { lang } : {},
                     withCredentials: true,
                 });
                 setPost(response.data);
@@ -95,7 +97,17 @@ function Post() {
                     ? media.map((item, index) => (
                           <div key={index}>
                               {item.contentType.startsWith("image/") && (
-                                  <img src={item.url} alt="images for post" />
+                                  <img
+                                      src={item.url}
+                                      srcSet={item.srcset || undefined}
+                                      sizes={
+                                          item.srcset
+                                              ? "(max-width: 800px) 100vw, 800px"
+                                              : undefined
+                                      }
+                                      loading="lazy"
+                                      alt="images for post"
+                                  />
                               )}
                               {item.contentType === "video/mp4" && (
                                   <video controls>